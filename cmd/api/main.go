@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -23,10 +24,15 @@ import (
 	"gorm.io/gorm"
 
 	"game-store-api/internal/handlers"
+	"game-store-api/internal/jobs"
+	"game-store-api/internal/mailer"
 	"game-store-api/internal/middleware"
+	"game-store-api/internal/migrations"
 	"game-store-api/internal/models"
+	"game-store-api/internal/pricing"
 	"game-store-api/internal/repository"
 	"game-store-api/internal/service"
+	"game-store-api/internal/storage"
 	"game-store-api/internal/worker"
 )
 
@@ -49,7 +55,7 @@ func main() {
 		os.Getenv("DB_PORT"),
 	)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		slog.Error("Failed to connect to database", "error", err)
 		os.Exit(1)
@@ -57,11 +63,21 @@ func main() {
 	slog.Info("Database connected successfully")
 
 	// Run migrations
-	err = db.AutoMigrate(&models.User{}, &models.Product{}, &models.Order{}, &models.OrderItem{}, &models.CartItem{})
+	err = db.AutoMigrate(&models.User{}, &models.Product{}, &models.Order{}, &models.OrderItem{}, &models.CartItem{}, &models.IdempotencyKey{}, &models.Payment{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.ActionToken{}, &models.OutboxEvent{}, &models.OrderStatusHistory{}, &models.GameKey{}, &models.Coupon{}, &models.CouponRedemption{}, &models.CartCoupon{}, &models.StockReservation{}, &models.Category{}, &models.Platform{}, &models.Publisher{}, &models.ProductImage{}, &models.Review{}, &models.WishlistItem{}, &models.WishlistAlert{})
 	if err != nil {
 		slog.Error("Failed to migrate database", "error", err)
 	}
 
+	if err := migrations.Run(db, migrations.All); err != nil {
+		slog.Error("Failed to run one-off migrations", "error", err)
+	}
+
+	// Full-text search index backing GET /products?q=
+	err = db.Exec("CREATE INDEX IF NOT EXISTS idx_products_search ON products USING GIN (to_tsvector('english', name || ' ' || description))").Error
+	if err != nil {
+		slog.Error("Failed to create product search index", "error", err)
+	}
+
 	// Connect to Redis
 	redisClient := redis.NewClient(&redis.Options{
 		Addr:     os.Getenv("REDIS_ADDR"),
@@ -76,9 +92,50 @@ func main() {
 		slog.Info("Redis connected successfully")
 	}
 
-	// Start background worker
+	// Start background workers
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workerPools []*jobs.Pool
+
+	concurrency := 4
+	if v := os.Getenv("WORKER_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			slog.Warn("Invalid WORKER_CONCURRENCY, using default", "value", v, "default", concurrency)
+		} else {
+			concurrency = n
+		}
+	}
+
+	jobQueues := map[string]*jobs.Queue{}
 	if redisClient != nil {
-		go worker.StartEmailWorker(redisClient)
+		jobStore := jobs.NewRedisStore(redisClient)
+		emailJobs := jobs.NewQueue("email", jobStore, jobs.Options{})
+		jobQueues[emailJobs.Name()] = emailJobs
+		eventJobs := jobs.NewQueue("events", jobStore, jobs.Options{})
+		jobQueues[eventJobs.Name()] = eventJobs
+
+		if err := worker.MigrateLegacyEmailQueue(context.Background(), redisClient, emailJobs); err != nil {
+			slog.Error("Failed to migrate legacy email queue", "error", err)
+		}
+
+		emailMailer, err := mailer.FromEnv()
+		if err != nil {
+			slog.Error("Failed to configure mailer", "error", err)
+			os.Exit(1)
+		}
+
+		baseURL := os.Getenv("APP_BASE_URL")
+		if baseURL == "" {
+			baseURL = "http://localhost:8080"
+		}
+		emailTemplates, err := mailer.LoadTemplates(baseURL)
+		if err != nil {
+			slog.Error("Failed to load email templates", "error", err)
+			os.Exit(1)
+		}
+
+		workerPools = append(workerPools, worker.StartEmailWorker(workerCtx, emailJobs, concurrency, emailMailer, emailTemplates))
 	}
 
 	// Connect to payment service
@@ -117,25 +174,112 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Load exchange rates
+	ratesFile := os.Getenv("EXCHANGE_RATES_FILE")
+	if ratesFile == "" {
+		ratesFile = "config/exchange_rates.json"
+	}
+	rates, err := pricing.LoadExchangeRates(ratesFile)
+	if err != nil {
+		slog.Warn("Failed to load exchange rates, only the base currency is available", "file", ratesFile, "error", err)
+		rates = pricing.NewExchangeRates(models.DefaultCurrency, nil)
+	}
+
+	// Sales tax as a fraction of the discounted subtotal, e.g. 0.2 for 20%
+	taxRate := 0.0
+	if v := os.Getenv("TAX_RATE"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 || rate >= 1 {
+			slog.Warn("Invalid TAX_RATE, charging no tax", "value", v)
+		} else {
+			taxRate = rate
+		}
+	}
+
+	// How long stock put in a cart is held for the customer
+	reservationTTL := 15 * time.Minute
+	if v := os.Getenv("CART_RESERVATION_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl <= 0 {
+			slog.Warn("Invalid CART_RESERVATION_TTL, holding cart stock for 15m", "value", v)
+		} else {
+			reservationTTL = ttl
+		}
+	}
+
+	// Where uploaded product images are kept
+	mediaStorage, err := storage.FromEnv()
+	if err != nil {
+		slog.Error("Failed to configure storage", "error", err)
+		os.Exit(1)
+	}
+
 	// Dependency injection
+	var emailQueue service.EmailQueue
+	if emailJobs, ok := jobQueues["email"]; ok {
+		emailQueue = worker.NewEmailQueue(emailJobs)
+	}
+
 	userRepo := repository.NewUserRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	actionTokenRepo := repository.NewActionTokenRepository(db)
+	tokenDenylist := repository.NewTokenDenylist(db, redisClient)
 	productRepo := repository.NewProductRepository(db)
 	orderRepo := repository.NewOrderRepository(db)
 	cartRepo := repository.NewCartRepository(db)
+	paymentRepo := repository.NewPaymentRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db, redisClient, 24*time.Hour, 5*time.Minute)
+	outboxRepo := repository.NewOutboxRepository(db)
+	keyRepo := repository.NewGameKeyRepository(db)
+	couponRepo := repository.NewCouponRepository(db)
+	reservationRepo := repository.NewReservationRepository(db)
+	taxonomyRepo := repository.NewTaxonomyRepository(db)
+	imageRepo := repository.NewProductImageRepository(db)
+	reviewRepo := repository.NewReviewRepository(db)
+	wishlistRepo := repository.NewWishlistRepository(db)
+
+	authService := service.NewAuthService(userRepo, refreshTokenRepo, actionTokenRepo, outboxRepo, tokenDenylist, emailQueue, db)
+	productService := service.NewProductService(productRepo, outboxRepo, keyRepo, reservationRepo, taxonomyRepo, imageRepo, mediaStorage, db, rates)
+	if err := productService.RefreshBasePrices(); err != nil {
+		slog.Error("Failed to refresh product base prices", "error", err)
+	}
+	cartService := service.NewCartService(cartRepo, productRepo, couponRepo, reservationRepo, db, rates, taxRate, reservationTTL)
+	orderService := service.NewOrderService(orderRepo, userRepo, productRepo, cartRepo, paymentRepo, outboxRepo, keyRepo, couponRepo, reservationRepo, paymentClient, db, rates, taxRate)
+	idempotencyService := service.NewIdempotencyService(idempotencyRepo)
+	paymentService := service.NewPaymentService(paymentRepo)
+	couponService := service.NewCouponService(couponRepo, productRepo, taxonomyRepo, db)
+	taxonomyService := service.NewTaxonomyService(taxonomyRepo, db)
+	reviewService := service.NewReviewService(reviewRepo, productRepo, db)
+	wishlistService := service.NewWishlistService(wishlistRepo, productRepo, reservationRepo, emailQueue, rates)
+
+	// Publish outbox events to Redis and handle them. Without Redis they wait
+	// in the outbox until it is back.
+	if eventJobs, ok := jobQueues["events"]; ok {
+		go worker.NewOutboxRelay(outboxRepo, eventJobs).Run(workerCtx, time.Second)
+		workerPools = append(workerPools, eventJobs.Start(workerCtx, concurrency, worker.EventHandlers(emailQueue, authService, wishlistService, orderService)))
+	}
 
-	authService := service.NewAuthService(userRepo, redisClient)
-	productService := service.NewProductService(productRepo)
-	cartService := service.NewCartService(cartRepo, productRepo)
-	orderService := service.NewOrderService(orderRepo, productRepo, cartRepo, paymentClient, db)
+	go worker.NewReservationSweeper(reservationRepo).Run(workerCtx, time.Minute)
 
 	authHandler := handlers.NewAuthHandler(authService)
 	productHandler := handlers.NewProductHandler(productService)
 	cartHandler := handlers.NewCartHandler(cartService)
-	orderHandler := handlers.NewOrderHandler(orderService)
+	orderHandler := handlers.NewOrderHandler(orderService, idempotencyService)
+	paymentHandler := handlers.NewPaymentHandler(paymentService)
+	jobHandler := handlers.NewJobHandler(jobQueues)
+	couponHandler := handlers.NewCouponHandler(couponService)
+	categoryHandler := handlers.NewTaxonomyHandler(taxonomyService, repository.Categories)
+	platformHandler := handlers.NewTaxonomyHandler(taxonomyService, repository.Platforms)
+	publisherHandler := handlers.NewTaxonomyHandler(taxonomyService, repository.Publishers)
+	reviewHandler := handlers.NewReviewHandler(reviewService)
+	wishlistHandler := handlers.NewWishlistHandler(wishlistService)
 
 	// Setup router
 	r := gin.Default()
 	r.Static("/static", "./static")
+	if local, ok := mediaStorage.(*storage.LocalStorage); ok && strings.HasPrefix(local.BaseURL, "/") {
+		r.Static(local.BaseURL, local.Dir)
+	}
 	r.GET("/", func(c *gin.Context) {
 		c.File("./static/index.html")
 	})
@@ -144,20 +288,87 @@ func main() {
 	{
 		v1.POST("/auth/register", authHandler.Register)
 		v1.POST("/auth/login", authHandler.Login)
+		v1.POST("/auth/refresh", authHandler.Refresh)
+		v1.POST("/auth/verify-email", authHandler.VerifyEmail)
+		v1.POST("/auth/resend-verification", authHandler.ResendVerification)
+		v1.POST("/auth/forgot-password", authHandler.ForgotPassword)
+		v1.POST("/auth/reset-password", authHandler.ResetPassword)
 
-		v1.GET("/products", productHandler.GetAllProducts)
+		v1.GET("/products", productHandler.GetProducts)
 		v1.GET("/products/:product_id", productHandler.GetProduct)
+		v1.GET("/products/:product_id/reviews", reviewHandler.ListReviews)
+		v1.GET("/categories", categoryHandler.ListTerms)
+		v1.GET("/platforms", platformHandler.ListTerms)
+		v1.GET("/publishers", publisherHandler.ListTerms)
 
 		protected := v1.Group("/")
-		protected.Use(middleware.AuthMiddleware())
+		protected.Use(middleware.AuthMiddleware(authService))
 		{
+			protected.POST("/auth/logout", authHandler.Logout)
+			protected.POST("/auth/logout-all", authHandler.LogoutAll)
+
 			protected.POST("/products", middleware.AdminOnly(), productHandler.CreateProduct)
+			protected.PUT("/products/:product_id", middleware.AdminOnly(), productHandler.UpdateProduct)
+			protected.PATCH("/products/:product_id", middleware.AdminOnly(), productHandler.PatchProduct)
+			protected.DELETE("/products/:product_id", middleware.AdminOnly(), productHandler.DeleteProduct)
+			protected.POST("/products/:product_id/restore", middleware.AdminOnly(), productHandler.RestoreProduct)
+			protected.POST("/products/:product_id/keys", middleware.AdminOnly(), productHandler.UploadKeys)
+			protected.POST("/products/:product_id/images", middleware.AdminOnly(), productHandler.UploadImage)
+			protected.DELETE("/products/:product_id/images/:image_id", middleware.AdminOnly(), productHandler.DeleteImage)
+
+			protected.POST("/products/:product_id/reviews", reviewHandler.CreateReview)
+			protected.PUT("/products/:product_id/reviews/:review_id", reviewHandler.UpdateReview)
+			protected.DELETE("/products/:product_id/reviews/:review_id", reviewHandler.DeleteReview)
+
+			for path, taxonomyHandler := range map[string]*handlers.TaxonomyHandler{
+				"/categories": categoryHandler,
+				"/platforms":  platformHandler,
+				"/publishers": publisherHandler,
+			} {
+				protected.POST(path, middleware.AdminOnly(), taxonomyHandler.CreateTerm)
+				protected.PUT(path+"/:term_id", middleware.AdminOnly(), taxonomyHandler.UpdateTerm)
+				protected.DELETE(path+"/:term_id", middleware.AdminOnly(), taxonomyHandler.DeleteTerm)
+			}
 
 			protected.GET("/cart", cartHandler.GetCart)
 			protected.POST("/cart", cartHandler.AddToCart)
 			protected.DELETE("/cart/:product_id", cartHandler.RemoveFromCart)
+			protected.POST("/cart/coupon", cartHandler.ApplyCoupon)
+			protected.DELETE("/cart/coupon", cartHandler.RemoveCoupon)
+
+			protected.GET("/wishlist", wishlistHandler.GetWishlist)
+			protected.POST("/wishlist", wishlistHandler.AddToWishlist)
+			protected.DELETE("/wishlist/:product_id", wishlistHandler.RemoveFromWishlist)
 
 			protected.POST("/cart/checkout", orderHandler.Checkout)
+
+			protected.GET("/orders", orderHandler.GetOrders)
+			protected.GET("/orders/:order_id", orderHandler.GetOrder)
+			protected.POST("/orders/:order_id/cancel", orderHandler.CancelOrder)
+
+			admin := protected.Group("/admin")
+			admin.Use(middleware.AdminOnly())
+			{
+				admin.GET("/orders", orderHandler.ListAllOrders)
+				admin.GET("/orders/:order_id", orderHandler.GetOrderDetails)
+				admin.POST("/orders/:order_id/status", orderHandler.UpdateOrderStatus)
+				admin.GET("/orders/:order_id/payment", orderHandler.GetOrderPayment)
+				admin.POST("/orders/:order_id/refund", orderHandler.RefundOrder)
+				admin.POST("/orders/:order_id/void", orderHandler.VoidOrder)
+
+				admin.GET("/payments/:transaction_id", paymentHandler.GetPayment)
+
+				admin.GET("/queues/:queue/dead", jobHandler.GetDeadJobs)
+				admin.POST("/queues/:queue/dead/:job_id/requeue", jobHandler.RequeueDeadJob)
+
+				admin.GET("/coupons", couponHandler.ListCoupons)
+				admin.POST("/coupons", couponHandler.CreateCoupon)
+				admin.DELETE("/coupons/:coupon_id", couponHandler.DeleteCoupon)
+
+				admin.GET("/reviews", reviewHandler.ListAllReviews)
+				admin.POST("/reviews/:review_id/hide", reviewHandler.HideReview)
+				admin.POST("/reviews/:review_id/unhide", reviewHandler.UnhideReview)
+			}
 		}
 	}
 
@@ -186,6 +397,15 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		slog.Error("Server forced to shutdown", "error", err)
 	}
+
+	// Stop taking new jobs and let running ones finish
+	slog.Info("Stopping workers...")
+	stopWorkers()
+	for _, pool := range workerPools {
+		if !pool.Wait(30 * time.Second) {
+			slog.Warn("Workers did not finish in time, their jobs will be retried after the visibility timeout")
+		}
+	}
 	slog.Info("Server exited properly")
 }
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"time"
 
 	"game-store-api/internal/models"
 
@@ -37,18 +38,41 @@ func main() {
 	slog.Info("Starting Database Seed...")
 
 	slog.Info("Cleaning old data...")
+	db.Exec("DELETE FROM cart_coupons")
+	db.Exec("DELETE FROM coupon_redemptions")
+	db.Exec("DELETE FROM coupon_products")
+	db.Exec("DELETE FROM coupon_categories")
+	db.Exec("DELETE FROM coupons")
+	db.Exec("DELETE FROM game_keys")
 	db.Exec("DELETE FROM order_items")
+	db.Exec("DELETE FROM order_status_histories")
+	db.Exec("DELETE FROM payments")
 	db.Exec("DELETE FROM orders")
 	db.Exec("DELETE FROM cart_items")
+	db.Exec("DELETE FROM stock_reservations")
+	db.Exec("DELETE FROM reviews")
+	db.Exec("DELETE FROM wishlist_alerts")
+	db.Exec("DELETE FROM wishlist_items")
+	db.Exec("DELETE FROM product_images")
+	db.Exec("DELETE FROM product_categories")
+	db.Exec("DELETE FROM product_platforms")
+	db.Exec("DELETE FROM product_publishers")
 	db.Exec("DELETE FROM products")
+	db.Exec("DELETE FROM categories")
+	db.Exec("DELETE FROM platforms")
+	db.Exec("DELETE FROM publishers")
+	db.Exec("DELETE FROM refresh_tokens")
+	db.Exec("DELETE FROM action_tokens")
+	db.Exec("DELETE FROM outbox_events")
 	db.Exec("DELETE FROM users")
 
 	hashedPass, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
 
+	verifiedAt := time.Now()
 	users := []models.User{
-		{Email: "admin@gamestore.com", Password: string(hashedPass), Role: "admin"},
-		{Email: "player1@test.com", Password: string(hashedPass), Role: "user"},
-		{Email: "player2@test.com", Password: string(hashedPass), Role: "user"},
+		{Email: "admin@gamestore.com", Password: string(hashedPass), Role: "admin", EmailVerifiedAt: &verifiedAt},
+		{Email: "player1@test.com", Password: string(hashedPass), Role: "user", EmailVerifiedAt: &verifiedAt},
+		{Email: "player2@test.com", Password: string(hashedPass), Role: "user", EmailVerifiedAt: &verifiedAt},
 	}
 
 	if err := db.Create(&users).Error; err != nil {
@@ -57,6 +81,33 @@ func main() {
 	}
 	slog.Info("Users seeded (password: password123)")
 
+	rpg := models.Category{Term: models.Term{Name: "RPG", Slug: "rpg"}}
+	action := models.Category{Term: models.Term{Name: "Action", Slug: "action"}}
+	simulation := models.Category{Term: models.Term{Name: "Simulation", Slug: "simulation"}}
+	sandbox := models.Category{Term: models.Term{Name: "Sandbox", Slug: "sandbox"}}
+	pc := models.Platform{Term: models.Term{Name: "PC", Slug: "pc"}}
+	ps5 := models.Platform{Term: models.Term{Name: "PlayStation 5", Slug: "ps5"}}
+	nintendoSwitch := models.Platform{Term: models.Term{Name: "Nintendo Switch", Slug: "switch"}}
+	bandai := models.Publisher{Term: models.Term{Name: "Bandai Namco", Slug: "bandai-namco"}}
+	teamCherry := models.Publisher{Term: models.Term{Name: "Team Cherry", Slug: "team-cherry"}}
+	cdProjekt := models.Publisher{Term: models.Term{Name: "CD Projekt", Slug: "cd-projekt"}}
+	concernedApe := models.Publisher{Term: models.Term{Name: "ConcernedApe", Slug: "concernedape"}}
+	coffeeStain := models.Publisher{Term: models.Term{Name: "Coffee Stain", Slug: "coffee-stain"}}
+	mojang := models.Publisher{Term: models.Term{Name: "Mojang", Slug: "mojang"}}
+	for _, term := range []any{&rpg, &action, &simulation, &sandbox, &pc, &ps5, &nintendoSwitch,
+		&bandai, &teamCherry, &cdProjekt, &concernedApe, &coffeeStain, &mojang} {
+		if err := db.Create(term).Error; err != nil {
+			slog.Error("Failed to create categories, platforms and publishers", "error", err)
+			os.Exit(1)
+		}
+	}
+	slog.Info("Categories, platforms and publishers seeded")
+
+	released := func(year int, month time.Month, day int) *time.Time {
+		date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		return &date
+	}
+
 	products := []models.Product{
 		{
 
@@ -65,6 +116,12 @@ func main() {
 			Price:       5999, // $59.99
 			Stock:       50,
 			SKU:         "ELD-001",
+			Developer:   "FromSoftware",
+			ReleaseDate: released(2022, time.February, 25),
+			AgeRating:   "PEGI 16",
+			Categories:  []models.Category{rpg, action},
+			Platforms:   []models.Platform{pc, ps5},
+			Publishers:  []models.Publisher{bandai},
 		},
 		{
 			Name:        "Hollow Knight",
@@ -72,6 +129,12 @@ func main() {
 			Price:       1499, // $14.99
 			Stock:       100,
 			SKU:         "HK-002",
+			Developer:   "Team Cherry",
+			ReleaseDate: released(2017, time.February, 24),
+			AgeRating:   "PEGI 7",
+			Categories:  []models.Category{action},
+			Platforms:   []models.Platform{pc, nintendoSwitch},
+			Publishers:  []models.Publisher{teamCherry},
 		},
 		{
 			Name:        "Cyberpunk 2077",
@@ -79,13 +142,26 @@ func main() {
 			Price:       2999,
 			Stock:       25,
 			SKU:         "CP-2077",
+			Developer:   "CD Projekt Red",
+			ReleaseDate: released(2020, time.December, 10),
+			AgeRating:   "PEGI 18",
+			Categories:  []models.Category{rpg, action},
+			Platforms:   []models.Platform{pc, ps5},
+			Publishers:  []models.Publisher{cdProjekt},
 		},
 		{
 			Name:        "Stardew Valley",
 			Description: "You've inherited your grandfather's old farm plot in Stardew Valley. Armed with hand-me-down tools and a few coins, you set out to begin your new life.",
 			Price:       1499,
-			Stock:       200,
+			Stock:       200, // Digital: one key each, seeded below
 			SKU:         "SDV-004",
+			Digital:     true,
+			Developer:   "ConcernedApe",
+			ReleaseDate: released(2016, time.February, 26),
+			AgeRating:   "PEGI 7",
+			Categories:  []models.Category{rpg, simulation},
+			Platforms:   []models.Platform{pc, nintendoSwitch},
+			Publishers:  []models.Publisher{concernedApe},
 		},
 		{
 			Name:        "Satisfactory",
@@ -93,6 +169,12 @@ func main() {
 			Price:       6999,
 			Stock:       10, // Low stock to test "Sold Out"
 			SKU:         "GOW-RAG",
+			Developer:   "Coffee Stain Studios",
+			ReleaseDate: released(2024, time.September, 10),
+			AgeRating:   "PEGI 12",
+			Categories:  []models.Category{simulation, sandbox},
+			Platforms:   []models.Platform{pc},
+			Publishers:  []models.Publisher{coffeeStain},
 		},
 		{
 			Name:        "Minecraft",
@@ -100,6 +182,12 @@ func main() {
 			Price:       2999,
 			Stock:       500,
 			SKU:         "MC-001",
+			Developer:   "Mojang Studios",
+			ReleaseDate: released(2011, time.November, 18),
+			AgeRating:   "PEGI 7",
+			Categories:  []models.Category{sandbox},
+			Platforms:   []models.Platform{pc, ps5, nintendoSwitch},
+			Publishers:  []models.Publisher{mojang},
 		},
 		{
 			Name:        "Half-Life 3",
@@ -110,11 +198,42 @@ func main() {
 		},
 	}
 
+	// Seeded prices are in USD, the base currency of the exchange rates
+	for i := range products {
+		products[i].BasePrice = products[i].Price
+	}
+
 	if err := db.Create(&products).Error; err != nil {
 		slog.Error("Failed to create products", "error", err)
 		os.Exit(1)
 	}
 	slog.Info("Products seeded")
 
+	for _, product := range products {
+		if !product.Digital {
+			continue
+		}
+		keys := make([]models.GameKey, product.Stock)
+		for i := range keys {
+			keys[i] = models.GameKey{ProductID: product.ID, Code: fmt.Sprintf("%s-%05d", product.SKU, i+1)}
+		}
+		if err := db.CreateInBatches(keys, 500).Error; err != nil {
+			slog.Error("Failed to create game keys", "error", err)
+			os.Exit(1)
+		}
+	}
+	slog.Info("Game keys seeded")
+
+	coupons := []models.Coupon{
+		{Code: "WELCOME10", Description: "10% off your first order", Type: models.CouponTypePercentage, Value: 10, Currency: "USD", MaxUsesPerUser: 1},
+		{Code: "BIGSPENDER", Type: models.CouponTypeFixed, Value: 1500, Currency: "USD", MinSpend: 10000}, // $15 off $100
+		{Code: "RPGWEEK", Description: "20% off RPGs", Type: models.CouponTypePercentage, Value: 20, Currency: "USD", Categories: []models.Category{rpg}},
+	}
+	if err := db.Create(&coupons).Error; err != nil {
+		slog.Error("Failed to create coupons", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Coupons seeded")
+
 	slog.Info("Seeding Complete!")
 }
@@ -6,10 +6,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"game-store-api/internal/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -63,3 +65,272 @@ func TestRegisterDuplicateEmail(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
+
+func registerAndLogin(t *testing.T, r *gin.Engine, email string) map[string]interface{} {
+	payload, _ := json.Marshal(map[string]string{"email": email, "password": "mysecretpassword"})
+
+	req1, _ := http.NewRequest("POST", "/api/v1/auth/register", bytes.NewBuffer(payload))
+	w1 := httptest.NewRecorder()
+	r.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusCreated, w1.Code)
+
+	req2, _ := http.NewRequest("POST", "/api/v1/auth/login", bytes.NewBuffer(payload))
+	w2 := httptest.NewRecorder()
+	r.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+
+	var tokens map[string]interface{}
+	json.Unmarshal(w2.Body.Bytes(), &tokens)
+	return tokens
+}
+
+func authorizedRequest(r *gin.Engine, method, path, token, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func refreshTokens(r *gin.Engine, refreshToken string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]string{"refresh_token": refreshToken})
+	req, _ := http.NewRequest("POST", "/api/v1/auth/refresh", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestLoginIssuesShortLivedAccessAndRefreshTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	tokens := registerAndLogin(t, r, "test@example.com")
+	assert.NotEmpty(t, tokens["token"])
+	assert.NotEmpty(t, tokens["refresh_token"])
+	assert.Equal(t, float64(15*60), tokens["expires_in"])
+
+	// Only a hash of the refresh token is stored
+	var stored models.RefreshToken
+	deps.DB.First(&stored)
+	assert.NotEqual(t, tokens["refresh_token"], stored.TokenHash)
+	assert.Len(t, stored.TokenHash, 64)
+
+	assert.Equal(t, http.StatusOK, authorizedRequest(r, "GET", "/api/v1/cart", tokens["token"].(string), "").Code)
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	tokens := registerAndLogin(t, r, "test@example.com")
+	oldRefresh := tokens["refresh_token"].(string)
+
+	w1 := refreshTokens(r, oldRefresh)
+	assert.Equal(t, http.StatusOK, w1.Code)
+	var rotated map[string]interface{}
+	json.Unmarshal(w1.Body.Bytes(), &rotated)
+	assert.NotEqual(t, oldRefresh, rotated["refresh_token"])
+	assert.Equal(t, http.StatusOK, authorizedRequest(r, "GET", "/api/v1/cart", rotated["token"].(string), "").Code)
+
+	// Replaying the old refresh token is treated as theft: it fails and the
+	// rotated one stops working too
+	assert.Equal(t, http.StatusUnauthorized, refreshTokens(r, oldRefresh).Code)
+	assert.Equal(t, http.StatusUnauthorized, refreshTokens(r, rotated["refresh_token"].(string)).Code)
+
+	assert.Equal(t, http.StatusUnauthorized, refreshTokens(r, "not-a-token").Code)
+}
+
+func TestLogoutRevokesAccessAndRefreshToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	tokens := registerAndLogin(t, r, "test@example.com")
+	accessToken := tokens["token"].(string)
+	body := `{"refresh_token":"` + tokens["refresh_token"].(string) + `"}`
+
+	assert.Equal(t, http.StatusOK, authorizedRequest(r, "POST", "/api/v1/auth/logout", accessToken, body).Code)
+
+	w := authorizedRequest(r, "GET", "/api/v1/cart", accessToken, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "revoked")
+	assert.Equal(t, http.StatusUnauthorized, refreshTokens(r, tokens["refresh_token"].(string)).Code)
+
+	// Other sessions are untouched
+	other := GenerateTestToken(1, "user")
+	assert.Equal(t, http.StatusOK, authorizedRequest(r, "GET", "/api/v1/cart", other, "").Code)
+}
+
+func TestLogoutAllSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	first := registerAndLogin(t, r, "test@example.com")
+	payload, _ := json.Marshal(map[string]string{"email": "test@example.com", "password": "mysecretpassword"})
+	req, _ := http.NewRequest("POST", "/api/v1/auth/login", bytes.NewBuffer(payload))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var second map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &second)
+
+	someoneElse := registerAndLogin(t, r, "other@example.com")
+
+	assert.Equal(t, http.StatusOK, authorizedRequest(r, "POST", "/api/v1/auth/logout-all", first["token"].(string), "").Code)
+
+	for _, session := range []map[string]interface{}{first, second} {
+		assert.Equal(t, http.StatusUnauthorized, authorizedRequest(r, "GET", "/api/v1/cart", session["token"].(string), "").Code)
+		assert.Equal(t, http.StatusUnauthorized, refreshTokens(r, session["refresh_token"].(string)).Code)
+	}
+	assert.Equal(t, http.StatusOK, authorizedRequest(r, "GET", "/api/v1/cart", someoneElse["token"].(string), "").Code)
+}
+
+func TestTokenWithoutIDIsRejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	claims := jwt.MapClaims{
+		"sub":  float64(1),
+		"role": "user",
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	}
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test_secret_key"))
+
+	assert.Equal(t, http.StatusUnauthorized, authorizedRequest(r, "GET", "/api/v1/cart", token, "").Code)
+}
+
+func postJSON(r *gin.Engine, path string, payload interface{}) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", path, bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestEmailVerificationRequiredForCheckout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	tokens := registerAndLogin(t, r, "test@example.com")
+	accessToken := tokens["token"].(string)
+
+	assert.Nil(t, deps.Emails.Last("verify_email"), "Emails go out once the registration event is handled")
+	deps.DeliverEvents()
+	task := deps.Emails.Last("verify_email")
+	if !assert.NotNil(t, task, "Registration should queue a verification email") {
+		return
+	}
+	assert.Equal(t, "test@example.com", task["email"])
+	assert.NotNil(t, deps.Emails.Last("welcome_email"))
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	var user models.User
+	deps.DB.Where("email = ?", "test@example.com").First(&user)
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 1})
+
+	// Unverified accounts can't check out
+	w1 := authorizedRequest(r, "POST", "/api/v1/cart/checkout", accessToken, "")
+	assert.Equal(t, http.StatusForbidden, w1.Code)
+	assert.Empty(t, deps.Payment.Charges)
+
+	// A verification token can't reset a password or act as an access token
+	assert.Equal(t, http.StatusBadRequest, postJSON(r, "/api/v1/auth/reset-password", map[string]string{"token": task["token"], "password": "x"}).Code)
+	assert.Equal(t, http.StatusUnauthorized, authorizedRequest(r, "GET", "/api/v1/cart", task["token"], "").Code)
+
+	assert.Equal(t, http.StatusOK, postJSON(r, "/api/v1/auth/verify-email", map[string]string{"token": task["token"]}).Code)
+	assert.Equal(t, http.StatusBadRequest, postJSON(r, "/api/v1/auth/verify-email", map[string]string{"token": task["token"]}).Code, "Tokens are single-use")
+
+	deps.DB.First(&user, user.ID)
+	assert.True(t, user.EmailVerified())
+
+	w2 := authorizedRequest(r, "POST", "/api/v1/cart/checkout", accessToken, "")
+	assert.Equal(t, http.StatusCreated, w2.Code)
+
+	// Verified accounts don't get more verification emails
+	sent := len(deps.Emails.Tasks)
+	assert.Equal(t, http.StatusAccepted, postJSON(r, "/api/v1/auth/resend-verification", map[string]string{"email": "test@example.com"}).Code)
+	assert.Len(t, deps.Emails.Tasks, sent)
+}
+
+func TestPasswordReset(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	tokens := registerAndLogin(t, r, "test@example.com")
+
+	assert.Equal(t, http.StatusAccepted, postJSON(r, "/api/v1/auth/forgot-password", map[string]string{"email": "test@example.com"}).Code)
+	task := deps.Emails.Last("password_reset")
+	if !assert.NotNil(t, task) {
+		return
+	}
+	assert.NotEmpty(t, task["expires_at"])
+
+	w := postJSON(r, "/api/v1/auth/reset-password", map[string]string{"token": task["token"], "password": "brandnewpassword"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusBadRequest, postJSON(r, "/api/v1/auth/reset-password", map[string]string{"token": task["token"], "password": "again"}).Code, "Tokens are single-use")
+
+	// Existing sessions end with the old password
+	assert.Equal(t, http.StatusUnauthorized, refreshTokens(r, tokens["refresh_token"].(string)).Code)
+	assert.Equal(t, http.StatusBadRequest, postJSON(r, "/api/v1/auth/login", map[string]string{"email": "test@example.com", "password": "mysecretpassword"}).Code)
+	w = postJSON(r, "/api/v1/auth/login", map[string]string{"email": "test@example.com", "password": "brandnewpassword"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// A session started right after the reset is not caught by it
+	var session map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &session)
+	assert.Equal(t, http.StatusOK, authorizedRequest(r, "GET", "/api/v1/cart", session["token"].(string), "").Code)
+
+	// Unknown addresses get the same answer and no email
+	sent := len(deps.Emails.Tasks)
+	assert.Equal(t, http.StatusAccepted, postJSON(r, "/api/v1/auth/forgot-password", map[string]string{"email": "nobody@example.com"}).Code)
+	assert.Len(t, deps.Emails.Tasks, sent)
+}
+
+func TestPasswordResetRejectsExpiredToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	registerAndLogin(t, r, "test@example.com")
+	postJSON(r, "/api/v1/auth/forgot-password", map[string]string{"email": "test@example.com"})
+
+	var record models.ActionToken
+	deps.DB.Where("purpose = ?", models.ActionPasswordReset).First(&record)
+
+	claims := jwt.MapClaims{
+		"sub":     float64(record.UserID),
+		"purpose": models.ActionPasswordReset,
+		"jti":     record.TokenID,
+		"exp":     time.Now().Add(-time.Minute).Unix(),
+	}
+	expired, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test_secret_key:" + models.ActionPasswordReset))
+
+	w := postJSON(r, "/api/v1/auth/reset-password", map[string]string{"token": expired, "password": "brandnewpassword"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestActionEmailsAreRateLimited(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	registerAndLogin(t, r, "test@example.com")
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusAccepted, postJSON(r, "/api/v1/auth/forgot-password", map[string]string{"email": "test@example.com"}).Code)
+	}
+
+	var resets int
+	for _, task := range deps.Emails.Tasks {
+		if task["type"] == "password_reset" {
+			resets++
+		}
+	}
+	assert.Equal(t, 3, resets)
+}
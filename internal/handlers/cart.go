@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"game-store-api/internal/service"
 	"net/http"
 	"strconv"
@@ -30,21 +31,52 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 	userID := c.MustGet("userID").(uint)
 
 	if err := h.service.AddToCart(userID, input.ProductID, input.Quantity); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondCartError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Added to cart"})
 }
 
+// GetCart shows the cart priced in the requested currency (see
+// requestedCurrency): every line with its subtotal and warnings, then the
+// subtotal, discounts, tax and the total checkout would charge.
 func (h *CartHandler) GetCart(c *gin.Context) {
 	userID := c.MustGet("userID").(uint)
-	items, err := h.service.GetCart(userID)
+	cart, err := h.service.GetCart(userID, requestedCurrency(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondCartError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, items)
+	c.JSON(http.StatusOK, cart)
+}
+
+// ApplyCoupon puts a coupon on the cart and answers with the discounted cart.
+func (h *CartHandler) ApplyCoupon(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	cart, err := h.service.ApplyCoupon(userID, input.Code, requestedCurrency(c))
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cart)
+}
+
+func (h *CartHandler) RemoveCoupon(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	if err := h.service.RemoveCoupon(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove coupon"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Coupon removed"})
 }
 
 func (h *CartHandler) RemoveFromCart(c *gin.Context) {
@@ -63,3 +95,22 @@ func (h *CartHandler) RemoveFromCart(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Item removed"})
 }
+
+func respondCartError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUnsupportedCurrency):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, service.ErrNotEnoughStock):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrCouponNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+	case errors.Is(err, service.ErrCouponNotStarted), errors.Is(err, service.ErrCouponExpired),
+		errors.Is(err, service.ErrCouponUsedUp), errors.Is(err, service.ErrCouponUserLimit),
+		errors.Is(err, service.ErrCouponMinSpend), errors.Is(err, service.ErrCouponNotApplicable):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -3,13 +3,16 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"game-store-api/internal/models"
+	"game-store-api/internal/service"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCartLifecycle(t *testing.T) {
@@ -111,3 +114,117 @@ func TestRemoveItemEndpoint(t *testing.T) {
 	assert.Equal(t, int64(0), count)
 
 }
+
+func TestGetCartInRequestedCurrency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Test", Price: 5000, Stock: 10, SKU: "TEST-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "test@example.com", Password: "password123"}
+	deps.DB.Create(&user)
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 1})
+
+	req, _ := http.NewRequest("GET", "/api/v1/cart?currency=GBP", nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestToken(user.ID, "user"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var cart service.CartView
+	json.Unmarshal(w.Body.Bytes(), &cart)
+	if assert.Len(t, cart.Items, 1) {
+		assert.Equal(t, "GBP", cart.Items[0].Product.Currency)
+		assert.Equal(t, models.Money(4000), cart.Items[0].Product.Price)
+	}
+	assert.Equal(t, "GBP", cart.Currency)
+	assert.Equal(t, models.Money(4000), cart.Total)
+}
+
+func TestCartPricingBreakdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependenciesWithTax(0.2)
+	r := SetupRouter(deps)
+
+	zelda := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	mario := models.Product{Name: "Mario", Price: 2000, Stock: 2, SKU: "MAR-1"}
+	kirby := models.Product{Name: "Kirby", Price: 3000, Stock: 1, SKU: "KIR-1"}
+	metroid := models.Product{Name: "Metroid", Price: 4000, Stock: 5, SKU: "MET-1"}
+	for _, product := range []*models.Product{&zelda, &mario, &kirby, &metroid} {
+		deps.DB.Create(product)
+	}
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	token := GenerateTestToken(user.ID, "user")
+
+	for _, add := range []struct {
+		product  models.Product
+		quantity int
+	}{{zelda, 2}, {mario, 2}, {kirby, 1}, {metroid, 1}} {
+		body := fmt.Sprintf(`{"product_id":%d,"quantity":%d}`, add.product.ID, add.quantity)
+		require.Equal(t, http.StatusOK, authorizedRequest(r, "POST", "/api/v1/cart", token, body).Code)
+	}
+	// Stock sold elsewhere after the items went in the cart
+	deps.DB.Model(&mario).Update("stock", 1)
+	deps.DB.Model(&kirby).Update("stock", 0)
+	deps.DB.Model(&zelda).Update("price", 5500)
+	deps.DB.Delete(&metroid)
+	deps.DB.Create(&models.Coupon{Code: "TENOFF", Type: models.CouponTypePercentage, Value: 10, Currency: "USD"})
+	require.Equal(t, http.StatusOK, authorizedRequest(r, "POST", "/api/v1/cart/coupon", token, `{"code":"TENOFF"}`).Code)
+
+	w := authorizedRequest(r, "GET", "/api/v1/cart", token, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var cart service.CartView
+	json.Unmarshal(w.Body.Bytes(), &cart)
+
+	require.Len(t, cart.Items, 4)
+	warningCodes := func(line service.CartLine) []string {
+		var codes []string
+		for _, warning := range line.Warnings {
+			codes = append(codes, warning.Code)
+		}
+		return codes
+	}
+	assert.Equal(t, models.Money(5500), cart.Items[0].UnitPrice)
+	assert.Equal(t, models.Money(11000), cart.Items[0].LineSubtotal)
+	assert.Equal(t, []string{service.WarningPriceChanged}, warningCodes(cart.Items[0]))
+	assert.Contains(t, cart.Items[0].Warnings[0].Message, "from 60.00 to 55.00 USD")
+	assert.Equal(t, models.Money(4000), cart.Items[1].LineSubtotal)
+	assert.Equal(t, []string{service.WarningInsufficientStock}, warningCodes(cart.Items[1]))
+	assert.Equal(t, []string{service.WarningOutOfStock}, warningCodes(cart.Items[2]))
+	assert.Equal(t, "Metroid", cart.Items[3].Product.Name)
+	assert.Equal(t, models.Money(0), cart.Items[3].LineSubtotal)
+	assert.Equal(t, []string{service.WarningProductDeleted}, warningCodes(cart.Items[3]))
+	assert.True(t, cart.HasWarnings)
+
+	// Deleted products don't count towards the totals
+	assert.Equal(t, 6, cart.ItemCount)
+	assert.Equal(t, models.Money(11000+4000+3000), cart.Subtotal)
+	assert.Equal(t, models.Money(1800), cart.Discounts[0].Amount)
+	assert.Equal(t, 0.2, cart.TaxRate)
+	assert.Equal(t, models.Money(3240), cart.Tax, "Tax is charged on the discounted subtotal")
+	assert.Equal(t, models.Money(18000-1800+3240), cart.Total)
+
+	// Checkout charges exactly what the cart shows
+	deps.DB.Where("product_id IN ?", []uint{mario.ID, kirby.ID, metroid.ID}).Delete(&models.CartItem{})
+	authorizedRequest(r, "POST", "/api/v1/cart", token, fmt.Sprintf(`{"product_id":%d,"quantity":1}`, zelda.ID))
+	w = authorizedRequest(r, "GET", "/api/v1/cart", token, "")
+	cart = service.CartView{}
+	json.Unmarshal(w.Body.Bytes(), &cart)
+	assert.False(t, cart.HasWarnings, "Adding an item again should refresh its price")
+
+	w = authorizedRequest(r, "POST", "/api/v1/cart/checkout", token, "")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var placed struct {
+		OrderID   uint         `json:"order_id"`
+		TotalPaid models.Money `json:"total_paid"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &placed)
+	assert.Equal(t, cart.Total, placed.TotalPaid)
+	var order models.Order
+	deps.DB.First(&order, placed.OrderID)
+	assert.Equal(t, cart.Subtotal, order.SubtotalCents)
+	assert.Equal(t, cart.Tax, order.TaxCents)
+	assert.Equal(t, cart.Discounts[0].Amount, order.DiscountCents)
+}
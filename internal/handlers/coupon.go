@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"game-store-api/internal/models"
+	"game-store-api/internal/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CouponHandler struct {
+	service *service.CouponService
+}
+
+func NewCouponHandler(s *service.CouponService) *CouponHandler {
+	return &CouponHandler{service: s}
+}
+
+// couponInput carries fixed values and min_spend in the minor unit of
+// currency (USD when omitted). Without product_ids or category_ids the
+// coupon applies to the whole cart.
+type couponInput struct {
+	Code           string       `json:"code" binding:"required,max=64"`
+	Description    string       `json:"description"`
+	Type           string       `json:"type" binding:"required,oneof=percentage fixed"`
+	Value          int64        `json:"value" binding:"required,gt=0"`
+	Currency       string       `json:"currency"`
+	MinSpend       models.Money `json:"min_spend" binding:"gte=0"`
+	ProductIDs     []uint       `json:"product_ids"`
+	CategoryIDs    []uint       `json:"category_ids"`
+	StartsAt       *time.Time   `json:"starts_at"`
+	ExpiresAt      *time.Time   `json:"expires_at"`
+	MaxUses        int          `json:"max_uses" binding:"gte=0"`
+	MaxUsesPerUser int          `json:"max_uses_per_user" binding:"gte=0"`
+}
+
+func (h *CouponHandler) CreateCoupon(c *gin.Context) {
+	var input couponInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Currency == "" {
+		input.Currency = models.DefaultCurrency
+	}
+
+	coupon, err := h.service.CreateCoupon(service.CouponInput{
+		Code:           input.Code,
+		Description:    input.Description,
+		Type:           input.Type,
+		Value:          input.Value,
+		Currency:       input.Currency,
+		MinSpend:       input.MinSpend,
+		ProductIDs:     input.ProductIDs,
+		CategoryIDs:    input.CategoryIDs,
+		StartsAt:       input.StartsAt,
+		ExpiresAt:      input.ExpiresAt,
+		MaxUses:        input.MaxUses,
+		MaxUsesPerUser: input.MaxUsesPerUser,
+	})
+	if err != nil {
+		respondCouponError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, coupon)
+}
+
+func (h *CouponHandler) ListCoupons(c *gin.Context) {
+	page, limit := parsePagination(c)
+	coupons, total, err := h.service.ListCoupons(page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch coupons"})
+		return
+	}
+
+	c.JSON(http.StatusOK, paginated(c, coupons, total, page, limit))
+}
+
+func (h *CouponHandler) DeleteCoupon(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("coupon_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon ID"})
+		return
+	}
+
+	if err := h.service.DeleteCoupon(uint(id)); err != nil {
+		respondCouponError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Coupon deleted"})
+}
+
+func respondCouponError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrCouponNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown product in product_ids"})
+	case errors.Is(err, service.ErrUnknownTerm):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown category in category_ids"})
+	case errors.Is(err, service.ErrUnsupportedCurrency), errors.Is(err, service.ErrInvalidCoupon),
+		errors.Is(err, service.ErrInvalidCouponPeriod):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrDuplicateCoupon):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"game-store-api/internal/models"
+	"game-store-api/internal/service"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminCreateCoupon(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+	adminToken := GenerateTestToken(99, "admin")
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+
+	body := fmt.Sprintf(`{"code":"zelda20","type":"percentage","value":20,"product_ids":[%d],"max_uses":100,"max_uses_per_user":1}`, product.ID)
+	assert.Equal(t, http.StatusForbidden, authorizedRequest(r, "POST", "/api/v1/admin/coupons", GenerateTestToken(1, "user"), body).Code)
+
+	w := authorizedRequest(r, "POST", "/api/v1/admin/coupons", adminToken, body)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var coupon models.Coupon
+	json.Unmarshal(w.Body.Bytes(), &coupon)
+	assert.Equal(t, "ZELDA20", coupon.Code)
+	assert.Equal(t, "USD", coupon.Currency)
+	assert.Len(t, coupon.Products, 1)
+
+	// Codes are case-insensitive
+	assert.Equal(t, http.StatusConflict, authorizedRequest(r, "POST", "/api/v1/admin/coupons", adminToken, `{"code":"Zelda20","type":"fixed","value":500}`).Code)
+	assert.Equal(t, http.StatusBadRequest, authorizedRequest(r, "POST", "/api/v1/admin/coupons", adminToken, `{"code":"HALF","type":"percentage","value":150}`).Code)
+	assert.Equal(t, http.StatusBadRequest, authorizedRequest(r, "POST", "/api/v1/admin/coupons", adminToken, `{"code":"FREE","type":"bogo","value":1}`).Code)
+	assert.Equal(t, http.StatusBadRequest, authorizedRequest(r, "POST", "/api/v1/admin/coupons", adminToken, `{"code":"GHOST","type":"fixed","value":500,"product_ids":[999]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, authorizedRequest(r, "POST", "/api/v1/admin/coupons", adminToken,
+		`{"code":"BACKWARDS","type":"fixed","value":500,"starts_at":"2030-01-02T00:00:00Z","expires_at":"2030-01-01T00:00:00Z"}`).Code)
+
+	w = authorizedRequest(r, "GET", "/api/v1/admin/coupons", adminToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Data  []models.Coupon `json:"data"`
+		Total int64           `json:"total"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	assert.Equal(t, int64(1), list.Total)
+
+	assert.Equal(t, http.StatusOK, authorizedRequest(r, "DELETE", fmt.Sprintf("/api/v1/admin/coupons/%d", coupon.ID), adminToken, "").Code)
+	assert.Equal(t, http.StatusNotFound, authorizedRequest(r, "DELETE", fmt.Sprintf("/api/v1/admin/coupons/%d", coupon.ID), adminToken, "").Code)
+
+	// A deleted coupon's code can be used again
+	w = authorizedRequest(r, "POST", "/api/v1/admin/coupons", adminToken, `{"code":"zelda20","type":"fixed","value":500}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var reissued models.Coupon
+	json.Unmarshal(w.Body.Bytes(), &reissued)
+	assert.NotEqual(t, coupon.ID, reissued.ID)
+	assert.Equal(t, models.CouponTypeFixed, reissued.Type)
+	assert.Equal(t, http.StatusConflict, authorizedRequest(r, "POST", "/api/v1/admin/coupons", adminToken, `{"code":"ZELDA20","type":"fixed","value":500}`).Code)
+}
+
+func TestApplyCouponToCart(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	zelda := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&zelda)
+	mario := models.Product{Name: "Mario", Price: 2000, Stock: 10, SKU: "MAR-1"}
+	deps.DB.Create(&mario)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user"}
+	deps.DB.Create(&user)
+	token := GenerateTestToken(user.ID, "user")
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: zelda.ID, Quantity: 1})
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: mario.ID, Quantity: 2})
+
+	past := time.Now().Add(-time.Hour)
+	deps.DB.Create(&models.Coupon{Code: "SAVE10", Type: models.CouponTypePercentage, Value: 10, Currency: "USD", MinSpend: 8000})
+	deps.DB.Create(&models.Coupon{Code: "MARIO5", Type: models.CouponTypeFixed, Value: 500, Currency: "USD", Products: []models.Product{mario}})
+	deps.DB.Create(&models.Coupon{Code: "SONIC", Type: models.CouponTypeFixed, Value: 500, Currency: "USD", Products: []models.Product{{Name: "Sonic", SKU: "SON-1"}}})
+	deps.DB.Create(&models.Coupon{Code: "OLD", Type: models.CouponTypeFixed, Value: 500, Currency: "USD", ExpiresAt: &past})
+
+	apply := func(code string) (int, service.CartView) {
+		w := authorizedRequest(r, "POST", "/api/v1/cart/coupon", token, fmt.Sprintf(`{"code":%q}`, code))
+		var cart service.CartView
+		json.Unmarshal(w.Body.Bytes(), &cart)
+		return w.Code, cart
+	}
+
+	status, cart := apply("save10")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, models.Money(10000), cart.Subtotal)
+	if assert.Len(t, cart.Discounts, 1) {
+		assert.Equal(t, "SAVE10", cart.Discounts[0].Code)
+		assert.Equal(t, "10% off", cart.Discounts[0].Description)
+		assert.Equal(t, models.Money(1000), cart.Discounts[0].Amount)
+	}
+	assert.Equal(t, models.Money(9000), cart.Total)
+
+	// A scoped coupon only discounts its products, never below their price
+	status, cart = apply("MARIO5")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, models.Money(500), cart.Discounts[0].Amount)
+
+	status, _ = apply("SONIC")
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	status, _ = apply("OLD")
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	status, _ = apply("NOPE")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// Failed attempts keep the coupon that was applied; it's priced in the cart's currency
+	w := authorizedRequest(r, "GET", "/api/v1/cart?currency=GBP", token, "")
+	json.Unmarshal(w.Body.Bytes(), &cart)
+	if assert.Len(t, cart.Discounts, 1) {
+		assert.Equal(t, "MARIO5", cart.Discounts[0].Code)
+		assert.Equal(t, models.Money(400), cart.Discounts[0].Amount)
+	}
+	assert.Equal(t, models.Money(8000-400), cart.Total)
+
+	// Falling below the minimum spend leaves the coupon on but explains why it gives nothing
+	status, _ = apply("SAVE10")
+	require.Equal(t, http.StatusOK, status)
+	authorizedRequest(r, "DELETE", fmt.Sprintf("/api/v1/cart/%d", mario.ID), token, "")
+	w = authorizedRequest(r, "GET", "/api/v1/cart", token, "")
+	cart = service.CartView{}
+	json.Unmarshal(w.Body.Bytes(), &cart)
+	assert.Empty(t, cart.Discounts)
+	assert.Equal(t, models.Money(6000), cart.Total)
+	assert.Contains(t, cart.CouponError, "spend at least 80.00 USD")
+
+	assert.Equal(t, http.StatusOK, authorizedRequest(r, "DELETE", "/api/v1/cart/coupon", token, "").Code)
+	w = authorizedRequest(r, "GET", "/api/v1/cart", token, "")
+	cart = service.CartView{}
+	json.Unmarshal(w.Body.Bytes(), &cart)
+	assert.Empty(t, cart.CouponError)
+}
+
+func TestCheckoutWithCoupon(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	token := GenerateTestToken(user.ID, "user")
+	coupon := models.Coupon{Code: "WELCOME", Type: models.CouponTypeFixed, Value: 1500, Currency: "USD", MaxUsesPerUser: 1}
+	deps.DB.Create(&coupon)
+
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 1})
+	require.Equal(t, http.StatusOK, authorizedRequest(r, "POST", "/api/v1/cart/coupon", token, `{"code":"WELCOME"}`).Code)
+
+	w := authorizedRequest(r, "POST", "/api/v1/cart/checkout", token, "")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var placed struct {
+		OrderID   uint         `json:"order_id"`
+		TotalPaid models.Money `json:"total_paid"`
+		Discount  models.Money `json:"discount"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &placed)
+	assert.Equal(t, models.Money(4500), placed.TotalPaid)
+	assert.Equal(t, models.Money(1500), placed.Discount)
+	assert.Equal(t, int64(4500), deps.Payment.Charges[0].Amount.AmountMinor, "Only the discounted total is charged")
+
+	var order models.Order
+	deps.DB.First(&order, placed.OrderID)
+	assert.Equal(t, models.Money(6000), order.SubtotalCents)
+	assert.Equal(t, models.Money(1500), order.DiscountCents)
+	assert.Equal(t, "WELCOME", order.CouponCode)
+
+	deps.DB.First(&coupon, coupon.ID)
+	assert.Equal(t, 1, coupon.Uses)
+	var redemption models.CouponRedemption
+	require.NoError(t, deps.DB.Where("order_id = ?", order.ID).First(&redemption).Error)
+	assert.Equal(t, user.ID, redemption.UserID)
+	var applied int64
+	deps.DB.Model(&models.CartCoupon{}).Count(&applied)
+	assert.Equal(t, int64(0), applied, "Checkout should use up the cart's coupon")
+
+	// One use per customer
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 1})
+	w = authorizedRequest(r, "POST", "/api/v1/cart/coupon", token, `{"code":"WELCOME"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "already used")
+
+	// Cancelling the order gives the use back
+	require.Equal(t, http.StatusOK, authorizedRequest(r, "POST", fmt.Sprintf("/api/v1/orders/%d/cancel", order.ID), token, "").Code)
+	deps.DB.First(&coupon, coupon.ID)
+	assert.Equal(t, 0, coupon.Uses)
+	assert.Equal(t, http.StatusOK, authorizedRequest(r, "POST", "/api/v1/cart/coupon", token, `{"code":"WELCOME"}`).Code)
+}
+
+func TestCheckoutRechecksCouponLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	token := GenerateTestToken(user.ID, "user")
+	coupon := models.Coupon{Code: "LAST1", Type: models.CouponTypePercentage, Value: 50, Currency: "USD", MaxUses: 1}
+	deps.DB.Create(&coupon)
+
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 1})
+	require.Equal(t, http.StatusOK, authorizedRequest(r, "POST", "/api/v1/cart/coupon", token, `{"code":"LAST1"}`).Code)
+
+	// Someone else took the last use after it was applied
+	deps.DB.Model(&coupon).Update("uses", 1)
+
+	w := authorizedRequest(r, "POST", "/api/v1/cart/checkout", token, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "usage limit")
+	assert.Empty(t, deps.Payment.Charges)
+
+	deps.DB.First(&product, product.ID)
+	assert.Equal(t, 10, product.Stock)
+	var orders int64
+	deps.DB.Model(&models.Order{}).Count(&orders)
+	assert.Equal(t, int64(0), orders)
+}
+
+func TestCouponCategoryScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	rpg := models.Category{Term: models.Term{Name: "RPG", Slug: "rpg"}}
+	deps.DB.Create(&rpg)
+	zelda := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1", Categories: []models.Category{rpg}}
+	deps.DB.Create(&zelda)
+	mario := models.Product{Name: "Mario", Price: 2000, Stock: 10, SKU: "MAR-1"}
+	deps.DB.Create(&mario)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	token := GenerateTestToken(user.ID, "user")
+	adminToken := GenerateTestToken(99, "admin")
+
+	body := fmt.Sprintf(`{"code":"RPG25","type":"percentage","value":25,"category_ids":[%d]}`, rpg.ID)
+	w := authorizedRequest(r, "POST", "/api/v1/admin/coupons", adminToken, body)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusBadRequest, authorizedRequest(r, "POST", "/api/v1/admin/coupons", adminToken, `{"code":"GHOST","type":"fixed","value":500,"category_ids":[999]}`).Code)
+
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: mario.ID, Quantity: 1})
+	assert.Equal(t, http.StatusUnprocessableEntity, authorizedRequest(r, "POST", "/api/v1/cart/coupon", token, `{"code":"RPG25"}`).Code)
+
+	// Only the products in the category are discounted, at checkout too
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: zelda.ID, Quantity: 1})
+	w = authorizedRequest(r, "POST", "/api/v1/cart/coupon", token, `{"code":"RPG25"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var cart service.CartView
+	json.Unmarshal(w.Body.Bytes(), &cart)
+	require.Len(t, cart.Discounts, 1)
+	assert.Equal(t, models.Money(1500), cart.Discounts[0].Amount)
+
+	w = authorizedRequest(r, "POST", "/api/v1/cart/checkout", token, "")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var placed struct {
+		Discount models.Money `json:"discount"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &placed)
+	assert.Equal(t, models.Money(1500), placed.Discount)
+}
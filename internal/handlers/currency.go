@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestedCurrency reads the ISO-4217 code prices should be shown in, from the
+// currency query parameter or else the Accept-Currency header. An empty result
+// means the client did not ask for a currency.
+func requestedCurrency(c *gin.Context) string {
+	currency := c.Query("currency")
+	if currency == "" {
+		currency = c.GetHeader("Accept-Currency")
+	}
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
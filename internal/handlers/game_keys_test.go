@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"game-store-api/internal/models"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func uploadKeysCSV(r *gin.Engine, productID uint, token, csv string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/products/%d/keys", productID), bytes.NewBufferString(csv))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// createDigitalProduct stores a digital product with one available key per code.
+func createDigitalProduct(deps TestDeps, sku string, codes ...string) models.Product {
+	product := models.Product{Name: "Hades", Price: 2500, Stock: len(codes), SKU: sku, Digital: true}
+	deps.DB.Create(&product)
+	for _, code := range codes {
+		deps.DB.Create(&models.GameKey{ProductID: product.ID, Code: code})
+	}
+	return product
+}
+
+func TestUploadGameKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+	adminToken := GenerateTestToken(99, "admin")
+
+	// Digital products start empty; their stock comes from keys
+	w := authorizedRequest(r, "POST", "/api/v1/products", adminToken, `{"name":"Hades","price":2500,"sku":"HAD-1","stock":5,"digital":true}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = authorizedRequest(r, "POST", "/api/v1/products", adminToken, `{"name":"Hades","price":2500,"sku":"HAD-1","digital":true}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var product models.Product
+	json.Unmarshal(w.Body.Bytes(), &product)
+	assert.True(t, product.Digital)
+
+	assert.Equal(t, http.StatusForbidden, uploadKeysCSV(r, product.ID, GenerateTestToken(1, "user"), "AAAA-1111\n").Code)
+
+	w = uploadKeysCSV(r, product.ID, adminToken, "code\nAAAA-1111\nBBBB-2222\n\nAAAA-1111\nCCCC-3333,spare column\n")
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"added":3,"duplicates":1,"stock":3}`, w.Body.String())
+
+	// Keys the product already has are reported, not stored twice
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "keys.csv")
+	part.Write([]byte("BBBB-2222\nDDDD-4444\n"))
+	form.Close()
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/products/%d/keys", product.ID), &body)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"added":1,"duplicates":1,"stock":4}`, w.Body.String())
+
+	deps.DB.First(&product, product.ID)
+	assert.Equal(t, 4, product.Stock)
+	var keyCount int64
+	deps.DB.Model(&models.GameKey{}).Where("product_id = ?", product.ID).Count(&keyCount)
+	assert.Equal(t, int64(4), keyCount)
+
+	assert.Equal(t, http.StatusBadRequest, uploadKeysCSV(r, product.ID, adminToken, "code\n\n").Code)
+	assert.Equal(t, http.StatusNotFound, uploadKeysCSV(r, 999, adminToken, "EEEE-5555\n").Code)
+
+	// The stock of a digital product can't be set by hand
+	path := fmt.Sprintf("/api/v1/products/%d", product.ID)
+	assert.Equal(t, http.StatusConflict, authorizedRequest(r, "PATCH", path, adminToken, `{"stock":100}`).Code)
+	w = authorizedRequest(r, "PUT", path, adminToken, `{"name":"Hades II","price":2500,"sku":"HAD-1","stock":100,"digital":true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &product)
+	assert.Equal(t, 4, product.Stock)
+
+	physical := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&physical)
+	assert.Equal(t, http.StatusConflict, uploadKeysCSV(r, physical.ID, adminToken, "EEEE-5555\n").Code)
+}
+
+func TestCheckoutAssignsGameKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := createDigitalProduct(deps, "HAD-1", "AAAA-1111", "BBBB-2222", "CCCC-3333")
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	token := GenerateTestToken(user.ID, "user")
+
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 2})
+	w := authorizedRequest(r, "POST", "/api/v1/cart/checkout", token, "")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var placed struct {
+		OrderID uint `json:"order_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &placed)
+
+	deps.DB.First(&product, product.ID)
+	assert.Equal(t, 1, product.Stock)
+
+	// Only the owner's order details reveal the keys
+	w = authorizedRequest(r, "GET", fmt.Sprintf("/api/v1/orders/%d", placed.OrderID), token, "")
+	var detail models.Order
+	json.Unmarshal(w.Body.Bytes(), &detail)
+	require.Len(t, detail.Items, 1)
+	if assert.Len(t, detail.Items[0].Keys, 2) {
+		assert.Equal(t, "AAAA-1111", detail.Items[0].Keys[0].Code)
+		assert.Equal(t, "BBBB-2222", detail.Items[0].Keys[1].Code)
+	}
+
+	other := GenerateTestToken(user.ID+1, "user")
+	assert.Equal(t, http.StatusNotFound, authorizedRequest(r, "GET", fmt.Sprintf("/api/v1/orders/%d", placed.OrderID), other, "").Code)
+	w = authorizedRequest(r, "GET", "/api/v1/orders", token, "")
+	assert.NotContains(t, w.Body.String(), "AAAA-1111")
+	w = authorizedRequest(r, "GET", fmt.Sprintf("/api/v1/admin/orders/%d", placed.OrderID), GenerateTestToken(99, "admin"), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "AAAA-1111")
+
+	// Keys of a cancelled order may have been seen, so they are revoked
+	assert.Equal(t, http.StatusOK, authorizedRequest(r, "POST", fmt.Sprintf("/api/v1/orders/%d/cancel", placed.OrderID), token, "").Code)
+	deps.DB.First(&product, product.ID)
+	assert.Equal(t, 1, product.Stock)
+	var revoked int64
+	deps.DB.Model(&models.GameKey{}).Where("revoked_at IS NOT NULL").Count(&revoked)
+	assert.Equal(t, int64(2), revoked)
+
+	w = authorizedRequest(r, "GET", fmt.Sprintf("/api/v1/orders/%d", placed.OrderID), token, "")
+	assert.NotContains(t, w.Body.String(), "AAAA-1111")
+}
+
+func TestCheckoutDeclinedReleasesGameKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+	deps.Payment.Decline = true
+
+	product := createDigitalProduct(deps, "HAD-1", "AAAA-1111", "BBBB-2222")
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 2})
+
+	w := authorizedRequest(r, "POST", "/api/v1/cart/checkout", GenerateTestToken(user.ID, "user"), "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// The keys were never shown, so they go back to the pool
+	deps.DB.First(&product, product.ID)
+	assert.Equal(t, 2, product.Stock)
+	var assigned int64
+	deps.DB.Model(&models.GameKey{}).Where("order_item_id IS NOT NULL OR revoked_at IS NOT NULL").Count(&assigned)
+	assert.Equal(t, int64(0), assigned)
+}
+
+func TestCheckoutFailsWithoutEnoughKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := createDigitalProduct(deps, "HAD-1", "AAAA-1111")
+	// Stock out of step with the keys must not oversell
+	deps.DB.Model(&product).Update("stock", 5)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 2})
+
+	w := authorizedRequest(r, "POST", "/api/v1/cart/checkout", GenerateTestToken(user.ID, "user"), "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "not enough stock")
+	assert.Empty(t, deps.Payment.Charges)
+
+	var assigned int64
+	deps.DB.Model(&models.GameKey{}).Where("order_item_id IS NOT NULL").Count(&assigned)
+	assert.Equal(t, int64(0), assigned)
+	var orders int64
+	deps.DB.Model(&models.Order{}).Count(&orders)
+	assert.Equal(t, int64(0), orders)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"game-store-api/internal/jobs"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+	queues map[string]*jobs.Queue
+}
+
+func NewJobHandler(queues map[string]*jobs.Queue) *JobHandler {
+	return &JobHandler{queues: queues}
+}
+
+func (h *JobHandler) queue(c *gin.Context) (*jobs.Queue, bool) {
+	queue, ok := h.queues[c.Param("queue")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Queue not found"})
+	}
+	return queue, ok
+}
+
+func (h *JobHandler) GetDeadJobs(c *gin.Context) {
+	queue, ok := h.queue(c)
+	if !ok {
+		return
+	}
+
+	page, limit := parsePagination(c)
+	dead, total, err := queue.Dead(c.Request.Context(), (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dead jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, paginated(c, dead, total, page, limit))
+}
+
+func (h *JobHandler) RequeueDeadJob(c *gin.Context) {
+	queue, ok := h.queue(c)
+	if !ok {
+		return
+	}
+
+	job, err := queue.RequeueDead(c.Request.Context(), c.Param("job_id"))
+	if errors.Is(err, jobs.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to requeue job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"game-store-api/internal/jobs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminDeadJobs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+	ctx := context.Background()
+
+	queued, err := deps.EmailJobs.Enqueue(ctx, "send_email", map[string]string{"type": "welcome_email", "email": "gamer@test.com"})
+	require.NoError(t, err)
+	_, err = deps.EmailJobs.RunOnce(ctx, map[string]jobs.Handler{
+		"send_email": func(ctx context.Context, job *jobs.Job) error {
+			return jobs.Permanent(errors.New("mailbox does not exist"))
+		},
+	})
+	require.NoError(t, err)
+
+	send := func(method, path, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	adminToken := GenerateTestToken(99, "admin")
+
+	assert.Equal(t, http.StatusForbidden, send("GET", "/api/v1/admin/queues/email/dead", GenerateTestToken(1, "user")).Code)
+	assert.Equal(t, http.StatusNotFound, send("GET", "/api/v1/admin/queues/sms/dead", adminToken).Code)
+
+	w := send("GET", "/api/v1/admin/queues/email/dead", adminToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var listed struct {
+		Data  []jobs.Job `json:"data"`
+		Total int64      `json:"total"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &listed)
+	require.EqualValues(t, 1, listed.Total)
+	assert.Equal(t, queued.ID, listed.Data[0].ID)
+	assert.Equal(t, "mailbox does not exist", listed.Data[0].LastError)
+	assert.NotNil(t, listed.Data[0].FailedAt)
+
+	assert.Equal(t, http.StatusNotFound, send("POST", "/api/v1/admin/queues/email/dead/missing/requeue", adminToken).Code)
+
+	w = send("POST", "/api/v1/admin/queues/email/dead/"+queued.ID+"/requeue", adminToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// The job runs again and is no longer dead
+	var delivered map[string]string
+	ran, err := deps.EmailJobs.RunOnce(ctx, map[string]jobs.Handler{
+		"send_email": func(ctx context.Context, job *jobs.Job) error {
+			return job.Decode(&delivered)
+		},
+	})
+	require.NoError(t, err)
+	assert.True(t, ran)
+	assert.Equal(t, "gamer@test.com", delivered["email"])
+
+	w = send("GET", "/api/v1/admin/queues/email/dead", adminToken)
+	json.Unmarshal(w.Body.Bytes(), &listed)
+	assert.EqualValues(t, 0, listed.Total)
+}
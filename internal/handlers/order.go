@@ -1,31 +1,380 @@
 package handlers
 
 import (
+	"errors"
+	"game-store-api/internal/models"
+	"game-store-api/internal/repository"
 	"game-store-api/internal/service"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// maxIdempotencyKeyLength matches the column size of models.IdempotencyKey.Key.
+const maxIdempotencyKeyLength = 255
+
 type OrderHandler struct {
-	service *service.OrderService
+	service     *service.OrderService
+	idempotency *service.IdempotencyService
 }
 
-func NewOrderHandler(s *service.OrderService) *OrderHandler {
-	return &OrderHandler{service: s}
+func NewOrderHandler(s *service.OrderService, idempotency *service.IdempotencyService) *OrderHandler {
+	return &OrderHandler{service: s, idempotency: idempotency}
 }
 
+// Checkout charges the cart in the requested currency (see requestedCurrency),
+// or in the store's base currency.
+//
+// When the request carries an Idempotency-Key header, a repeat with the same
+// key replays the first successful response instead of charging again, and
+// reusing the key for a different cart is rejected with 409.
 func (h *OrderHandler) Checkout(c *gin.Context) {
 	userID := c.MustGet("userID").(uint)
-	order, err := h.service.Checkout(userID)
+	currency := requestedCurrency(c)
+
+	key := c.GetHeader("Idempotency-Key")
+	if key == "" {
+		h.checkout(c, userID, currency, nil)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+		return
+	}
+
+	fingerprint, err := h.service.CheckoutFingerprint(userID, currency)
+	if err == nil && fingerprint == "" {
+		fingerprint, err = h.retryFingerprint(userID, key, currency)
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read cart"})
+		return
+	}
+	if fingerprint == "" {
+		// Nothing to buy and nothing to replay
+		h.checkout(c, userID, currency, nil)
+		return
+	}
+
+	record, err := h.idempotency.Begin(userID, key, fingerprint)
+	switch {
+	case errors.Is(err, service.ErrIdempotencyKeyInUse), errors.Is(err, service.ErrIdempotencyKeyReused):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check Idempotency-Key"})
+		return
+	}
+
+	if record.Completed() {
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(record.StatusCode, "application/json; charset=utf-8", []byte(record.Response))
+		return
+	}
+
+	h.checkout(c, userID, currency, record)
+}
+
+// retryFingerprint fingerprints a checkout of an empty cart with key. A
+// successful checkout empties the cart, so a retry of it is matched on the
+// order it placed. It returns an empty string when key placed no order.
+func (h *OrderHandler) retryFingerprint(userID uint, key, currency string) (string, error) {
+	record, err := h.idempotency.Find(userID, key)
+	if err != nil || record == nil || record.OrderID == nil {
+		return "", err
+	}
+	return h.service.OrderFingerprint(userID, *record.OrderID, currency)
+}
+
+// checkout places the order and, when record is set, stores the response
+// under its idempotency key or releases the key if the checkout failed.
+func (h *OrderHandler) checkout(c *gin.Context, userID uint, currency string, record *models.IdempotencyKey) {
+	order, err := h.service.Checkout(userID, currency)
+	if err != nil {
+		if record != nil {
+			h.idempotency.Release(record)
+		}
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrEmailNotVerified) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	response := gin.H{
 		"message":    "Order placed successfully",
 		"order_id":   order.ID,
 		"total_paid": order.TotalCents,
-	})
+		"discount":   order.DiscountCents,
+		"tax":        order.TaxCents,
+		"currency":   order.Currency,
+	}
+	if record != nil {
+		if err := h.idempotency.Complete(record, order.ID, http.StatusCreated, response); err != nil {
+			slog.Error("Failed to store idempotent response", "order_id", order.ID, "error", err)
+		}
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+func (h *OrderHandler) GetOrders(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	page, limit := parsePagination(c)
+
+	orders, total, err := h.service.GetOrders(userID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, paginated(c, orders, total, page, limit))
+}
+
+func (h *OrderHandler) GetOrder(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	order, err := h.service.GetOrder(userID, orderID)
+	if errors.Is(err, service.ErrOrderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// CancelOrder lets the customer cancel their order while it is paid but not
+// yet fulfilled. The stock is restored and the payment refunded.
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	order, err := h.service.CancelOrder(userID, orderID)
+	if errors.Is(err, service.ErrCancellationWindowClosed) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		respondPaymentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *OrderHandler) GetOrderPayment(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	payment, err := h.service.GetOrderPayment(orderID)
+	if err != nil {
+		respondPaymentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+// RefundOrder refunds part of an order's charge, or all of it when amount is omitted.
+// The amount is in the minor unit of the order's currency.
+func (h *OrderHandler) RefundOrder(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Amount models.Money `json:"amount" binding:"gte=0"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	payment, err := h.service.RefundOrder(orderID, input.Amount, actor(c))
+	if err != nil {
+		respondPaymentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+func (h *OrderHandler) VoidOrder(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	payment, err := h.service.VoidOrder(orderID, actor(c))
+	if err != nil {
+		respondPaymentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+// ListAllOrders lists every customer's orders for admins, filtered by
+// status, user_id and a created_at range (from, to) given as RFC 3339
+// timestamps or dates.
+func (h *OrderHandler) ListAllOrders(c *gin.Context) {
+	page, limit := parsePagination(c)
+	filter := repository.OrderFilter{
+		Status: c.Query("status"),
+		Limit:  limit,
+		Offset: (page - 1) * limit,
+	}
+
+	if raw := c.Query("user_id"); raw != "" {
+		userID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id must be a positive integer"})
+			return
+		}
+		filter.UserID = uint(userID)
+	}
+
+	var ok bool
+	if filter.CreatedAfter, ok = optionalTimeQuery(c, "from"); !ok {
+		return
+	}
+	if filter.CreatedBefore, ok = optionalTimeQuery(c, "to"); !ok {
+		return
+	}
+
+	orders, total, err := h.service.ListOrders(filter)
+	if errors.Is(err, service.ErrUnknownOrderStatus) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, paginated(c, orders, total, page, limit))
+}
+
+// GetOrderDetails returns any order with its payments and status history.
+func (h *OrderHandler) GetOrderDetails(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	order, err := h.service.GetOrderDetails(orderID)
+	if errors.Is(err, service.ErrOrderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// UpdateOrderStatus moves an order along fulfilment: fulfilled, shipped
+// (with an optional carrier and tracking number) and delivered.
+func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Status         string `json:"status" binding:"required"`
+		Carrier        string `json:"carrier" binding:"max=100"`
+		TrackingNumber string `json:"tracking_number" binding:"max=100"`
+		Note           string `json:"note" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := h.service.UpdateOrderStatus(orderID, service.StatusUpdate{
+		Status:         input.Status,
+		Carrier:        input.Carrier,
+		TrackingNumber: input.TrackingNumber,
+		Note:           input.Note,
+	}, actor(c))
+	if err != nil {
+		respondPaymentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// actor is the signed-in user acting on an order.
+func actor(c *gin.Context) service.Actor {
+	return service.Actor{
+		UserID: c.MustGet("userID").(uint),
+		Role:   c.GetString("userRole"),
+	}
+}
+
+// optionalTimeQuery parses a query parameter given as an RFC 3339 timestamp
+// or a YYYY-MM-DD date. It responds with 400 and returns false when the
+// value is malformed.
+func optionalTimeQuery(c *gin.Context, name string) (*time.Time, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, true
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return &t, true
+		}
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be an RFC 3339 timestamp or a YYYY-MM-DD date"})
+	return nil, false
+}
+
+func orderIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("order_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondPaymentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	case errors.Is(err, service.ErrOrderNotPaid), errors.Is(err, service.ErrInvalidStatusTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUnknownOrderStatus), errors.Is(err, service.ErrStatusNeedsPayment):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPaymentRejected):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPaymentUnavailable):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
 }
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	pb "game-store-api/internal/grpc/payment"
+	"game-store-api/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// placePaidOrder checks out a fresh cart for user and returns the order.
+func placePaidOrder(t *testing.T, deps TestDeps, r *gin.Engine, user models.User, product models.Product) models.Order {
+	t.Helper()
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 1})
+	w := authorizedRequest(r, "POST", "/api/v1/cart/checkout", GenerateTestToken(user.ID, "user"), "")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var placed struct {
+		OrderID uint `json:"order_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &placed)
+	var order models.Order
+	deps.DB.First(&order, placed.OrderID)
+	return order
+}
+
+func TestAdminOrderFulfilment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	order := placePaidOrder(t, deps, r, user, product)
+
+	adminToken := GenerateTestToken(99, "admin")
+	setStatus := func(token, body string) *httptest.ResponseRecorder {
+		return authorizedRequest(r, "POST", fmt.Sprintf("/api/v1/admin/orders/%d/status", order.ID), token, body)
+	}
+
+	assert.Equal(t, http.StatusForbidden, setStatus(GenerateTestToken(user.ID, "user"), `{"status":"fulfilled"}`).Code)
+	// Shipping needs the order to be fulfilled first
+	assert.Equal(t, http.StatusConflict, setStatus(adminToken, `{"status":"shipped"}`).Code)
+	// Money-moving statuses have their own endpoints
+	assert.Equal(t, http.StatusBadRequest, setStatus(adminToken, `{"status":"refunded"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, setStatus(adminToken, `{"status":"lost"}`).Code)
+
+	assert.Equal(t, http.StatusOK, setStatus(adminToken, `{"status":"fulfilled"}`).Code)
+	// A fulfilled order can't be voided any more
+	assert.Equal(t, http.StatusConflict, authorizedRequest(r, "POST", fmt.Sprintf("/api/v1/admin/orders/%d/void", order.ID), adminToken, "").Code)
+
+	w := setStatus(adminToken, `{"status":"shipped","carrier":"DHL","tracking_number":"TRK123","note":"Left the warehouse"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var shipped models.Order
+	json.Unmarshal(w.Body.Bytes(), &shipped)
+	assert.Equal(t, models.OrderStatusShipped, shipped.Status)
+	assert.Equal(t, "DHL", shipped.Carrier)
+	assert.Equal(t, "TRK123", shipped.TrackingNumber)
+
+	assert.Equal(t, http.StatusOK, setStatus(adminToken, `{"status":"delivered"}`).Code)
+	assert.Equal(t, http.StatusConflict, setStatus(adminToken, `{"status":"shipped"}`).Code)
+
+	// The customer sees every step and who made it
+	w = authorizedRequest(r, "GET", fmt.Sprintf("/api/v1/orders/%d", order.ID), GenerateTestToken(user.ID, "user"), "")
+	var detail models.Order
+	json.Unmarshal(w.Body.Bytes(), &detail)
+	require.Len(t, detail.StatusHistory, 4)
+	steps := []struct{ from, to, role string }{
+		{models.OrderStatusPending, models.OrderStatusPaid, models.ActorUser},
+		{models.OrderStatusPaid, models.OrderStatusFulfilled, models.ActorAdmin},
+		{models.OrderStatusFulfilled, models.OrderStatusShipped, models.ActorAdmin},
+		{models.OrderStatusShipped, models.OrderStatusDelivered, models.ActorAdmin},
+	}
+	for i, step := range steps {
+		entry := detail.StatusHistory[i]
+		assert.Equal(t, step.from, entry.FromStatus)
+		assert.Equal(t, step.to, entry.ToStatus)
+		assert.Equal(t, step.role, entry.ActorRole)
+		assert.False(t, entry.CreatedAt.IsZero())
+	}
+	assert.Equal(t, user.ID, *detail.StatusHistory[0].ActorID)
+	assert.Equal(t, uint(99), *detail.StatusHistory[1].ActorID)
+	assert.Equal(t, "Left the warehouse", detail.StatusHistory[2].Note)
+
+	// Customer-visible changes send an email, fulfilment doesn't
+	deps.DeliverEvents()
+	var types []string
+	for _, task := range deps.Emails.Tasks {
+		types = append(types, task["type"])
+	}
+	assert.Equal(t, []string{"order_confirmation", "order_shipped", "order_delivered"}, types)
+	assert.Equal(t, "TRK123", deps.Emails.Last("order_shipped")["tracking_number"])
+
+	// Delivered orders can still be refunded
+	w = authorizedRequest(r, "POST", fmt.Sprintf("/api/v1/admin/orders/%d/refund", order.ID), adminToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	deps.DB.First(&order, order.ID)
+	assert.Equal(t, models.OrderStatusRefunded, order.Status)
+
+	deps.DeliverEvents()
+	assert.NotNil(t, deps.Emails.Last("order_refunded"))
+}
+
+func TestAdminListOrders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	alice := models.User{Email: "alice@test.com", Password: "hashed", Role: "user"}
+	bob := models.User{Email: "bob@test.com", Password: "hashed", Role: "user"}
+	deps.DB.Create(&alice)
+	deps.DB.Create(&bob)
+
+	old := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	orders := []models.Order{
+		{UserID: alice.ID, TotalCents: 100, Status: models.OrderStatusPaid},
+		{UserID: alice.ID, TotalCents: 200, Status: models.OrderStatusShipped},
+		{UserID: bob.ID, TotalCents: 300, Status: models.OrderStatusPaid},
+	}
+	orders[2].CreatedAt = old
+	deps.DB.Create(&orders)
+
+	adminToken := GenerateTestToken(99, "admin")
+	list := func(query string) (int, []models.Order, int64) {
+		w := authorizedRequest(r, "GET", "/api/v1/admin/orders"+query, adminToken, "")
+		var page struct {
+			Data  []models.Order `json:"data"`
+			Total int64          `json:"total"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &page)
+		return w.Code, page.Data, page.Total
+	}
+
+	code, _, total := list("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.EqualValues(t, 3, total)
+
+	_, data, total := list("?status=paid")
+	assert.EqualValues(t, 2, total)
+	for _, order := range data {
+		assert.Equal(t, models.OrderStatusPaid, order.Status)
+	}
+
+	_, data, total = list(fmt.Sprintf("?user_id=%d&status=shipped", alice.ID))
+	require.EqualValues(t, 1, total)
+	assert.Equal(t, orders[1].ID, data[0].ID)
+
+	_, data, total = list("?to=2025-02-01")
+	require.EqualValues(t, 1, total)
+	assert.Equal(t, orders[2].ID, data[0].ID)
+
+	_, _, total = list("?from=2025-02-01T00:00:00Z")
+	assert.EqualValues(t, 2, total)
+
+	code, _, _ = list("?status=lost")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _, _ = list("?from=yesterday")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, http.StatusForbidden, authorizedRequest(r, "GET", "/api/v1/admin/orders", GenerateTestToken(alice.ID, "user"), "").Code)
+
+	w := authorizedRequest(r, "GET", fmt.Sprintf("/api/v1/admin/orders/%d", orders[2].ID), adminToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusNotFound, authorizedRequest(r, "GET", "/api/v1/admin/orders/999", adminToken, "").Code)
+}
+
+func TestCustomerCancelOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	order := placePaidOrder(t, deps, r, user, product)
+	token := GenerateTestToken(user.ID, "user")
+
+	cancel := func(token string, orderID uint) *httptest.ResponseRecorder {
+		return authorizedRequest(r, "POST", fmt.Sprintf("/api/v1/orders/%d/cancel", orderID), token, "")
+	}
+
+	// Someone else's order doesn't exist as far as they can tell
+	assert.Equal(t, http.StatusNotFound, cancel(GenerateTestToken(user.ID+1, "user"), order.ID).Code)
+
+	// The order is cancelled even while the payment service is down, and
+	// the refund is left pending
+	deps.Payment.Unavailable = true
+	w := cancel(token, order.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var cancelled models.Order
+	json.Unmarshal(w.Body.Bytes(), &cancelled)
+	assert.Equal(t, models.OrderStatusCancelled, cancelled.Status)
+	if assert.Len(t, cancelled.StatusHistory, 2) {
+		assert.Equal(t, models.ActorUser, cancelled.StatusHistory[1].ActorRole)
+		assert.Equal(t, "cancelled by customer", cancelled.StatusHistory[1].Note)
+	}
+
+	deps.DB.First(&product, product.ID)
+	assert.Equal(t, 10, product.Stock, "Cancelled order should give its stock back")
+	var payment models.Payment
+	deps.DB.Where("order_id = ?", order.ID).First(&payment)
+	assert.Equal(t, models.PaymentStatusRefundPending, payment.Status)
+
+	// The event workers refund it once the service is back
+	deps.Payment.Unavailable = false
+	deps.DeliverEvents()
+	assert.Len(t, deps.Payment.Refunds, 2, "The failed attempt and the refund")
+	deps.DB.First(&payment, payment.ID)
+	assert.Equal(t, models.PaymentStatusRefunded, payment.Status)
+	assert.Equal(t, payment.Amount, payment.Refunded)
+	assert.Equal(t, pb.PaymentStatus_PAYMENT_STATUS_REFUNDED, deps.Payment.Payments["TEST_TXN_123"].Status)
+	assert.NotNil(t, deps.Emails.Last("order_cancelled"))
+
+	w = cancel(token, order.ID)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "already cancelled")
+
+	// Once fulfilment has started the window has passed
+	fulfilled := models.Order{UserID: user.ID, TotalCents: 100, Status: models.OrderStatusFulfilled}
+	deps.DB.Create(&fulfilled)
+	w = cancel(token, fulfilled.ID)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "already been fulfilled")
+}
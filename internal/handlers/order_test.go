@@ -3,13 +3,19 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	pb "game-store-api/internal/grpc/payment"
 	"game-store-api/internal/models"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 func TestCheckoutFlow(t *testing.T) {
@@ -20,7 +26,7 @@ func TestCheckoutFlow(t *testing.T) {
 	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
 	deps.DB.Create(&product)
 
-	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user"}
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
 	deps.DB.Create(&user)
 
 	token := GenerateTestToken(user.ID, "user")
@@ -50,7 +56,8 @@ func TestCheckoutFlow(t *testing.T) {
 	var order models.Order
 	err := deps.DB.Preload("Items").Where("user_id = ?", user.ID).First(&order).Error
 	assert.Nil(t, err)
-	assert.Equal(t, 12000, order.TotalCents)
+	assert.Equal(t, models.Money(12000), order.TotalCents)
+	assert.Equal(t, models.OrderStatusPaid, order.Status)
 	assert.Equal(t, 1, len(order.Items))
 
 	// Check stock deducted from product
@@ -68,7 +75,7 @@ func TestCheckoutEmptyCart(t *testing.T) {
 	deps := SetupTestDependencies()
 	r := SetupRouter(deps)
 
-	user := models.User{Email: "test@example.com", Password: "hashed", Role: "user"}
+	user := models.User{Email: "test@example.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
 	deps.DB.Create(&user)
 	token := GenerateTestToken(user.ID, "user")
 
@@ -80,3 +87,596 @@ func TestCheckoutEmptyCart(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
+
+func TestOrderHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	other := models.User{Email: "other@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&other)
+
+	for i := 1; i <= 3; i++ {
+		deps.DB.Create(&models.Order{
+			UserID:     user.ID,
+			TotalCents: models.Money(6000 * i),
+			Status:     "paid",
+			Items:      []models.OrderItem{{ProductID: product.ID, Quantity: i, Price: 6000}},
+		})
+	}
+	deps.DB.Create(&models.Order{UserID: other.ID, TotalCents: 6000, Status: "paid"})
+
+	token := GenerateTestToken(user.ID, "user")
+
+	req, _ := http.NewRequest("GET", "/api/v1/orders?limit=2", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var page struct {
+		Data  []models.Order `json:"data"`
+		Total int64          `json:"total"`
+		Next  *string        `json:"next"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &page)
+
+	assert.Equal(t, int64(3), page.Total, "Should only count the user's own orders")
+	assert.Len(t, page.Data, 2)
+	assert.Equal(t, models.Money(18000), page.Data[0].TotalCents, "Newest order should come first")
+	assert.Equal(t, "Zelda", page.Data[0].Items[0].Product.Name, "Product should be preloaded")
+	if assert.NotNil(t, page.Next) {
+		assert.Contains(t, *page.Next, "page=2")
+	}
+
+	// Second page holds the remaining order and no further link
+	req2, _ := http.NewRequest("GET", *page.Next, nil)
+	req2.Header.Set("Authorization", "Bearer "+token)
+	w2 := httptest.NewRecorder()
+	r.ServeHTTP(w2, req2)
+
+	page.Next = nil
+	json.Unmarshal(w2.Body.Bytes(), &page)
+	assert.Len(t, page.Data, 1)
+	assert.Nil(t, page.Next)
+}
+
+func TestOrderDetailScopedToOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+
+	owner := models.User{Email: "owner@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&owner)
+	intruder := models.User{Email: "intruder@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&intruder)
+
+	order := models.Order{
+		UserID:     owner.ID,
+		TotalCents: 6000,
+		Status:     "paid",
+		Items:      []models.OrderItem{{ProductID: product.ID, Quantity: 1, Price: 6000}},
+	}
+	deps.DB.Create(&order)
+
+	getOrder := func(userID uint) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/orders/%d", order.ID), nil)
+		req.Header.Set("Authorization", "Bearer "+GenerateTestToken(userID, "user"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Owner sees the order with its items
+	w1 := getOrder(owner.ID)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var fetched models.Order
+	json.Unmarshal(w1.Body.Bytes(), &fetched)
+	assert.Equal(t, order.ID, fetched.ID)
+	assert.Len(t, fetched.Items, 1)
+	assert.Equal(t, "ZEL-1", fetched.Items[0].Product.SKU)
+
+	// Someone else's order looks like it doesn't exist
+	w2 := getOrder(intruder.ID)
+	assert.Equal(t, http.StatusNotFound, w2.Code)
+}
+
+func TestCheckoutPaymentDeclinedReleasesStock(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+	deps.Payment.Decline = true
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 2})
+
+	req, _ := http.NewRequest("POST", "/api/v1/cart/checkout", nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestToken(user.ID, "user"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// The pending order was charged under its own ID, then cancelled
+	var order models.Order
+	deps.DB.Where("user_id = ?", user.ID).First(&order)
+	assert.Equal(t, models.OrderStatusCancelled, order.Status)
+	if assert.Len(t, deps.Payment.Charges, 1) {
+		assert.Equal(t, int64(order.ID), deps.Payment.Charges[0].OrderId)
+	}
+
+	var payment models.Payment
+	err := deps.DB.Where("order_id = ?", order.ID).First(&payment).Error
+	assert.Nil(t, err, "Declined attempt should be recorded")
+	assert.Equal(t, models.PaymentStatusDeclined, payment.Status)
+	assert.Nil(t, payment.TransactionID)
+	assert.Equal(t, "Declined via Mock", payment.ProviderMessage)
+
+	var updatedProduct models.Product
+	deps.DB.First(&updatedProduct, product.ID)
+	assert.Equal(t, 10, updatedProduct.Stock, "Reserved stock should be released")
+
+	var cartCount int64
+	deps.DB.Model(&models.CartItem{}).Where("user_id = ?", user.ID).Count(&cartCount)
+	assert.Equal(t, int64(1), cartCount, "Cart should be kept for another attempt")
+}
+
+func TestCheckoutOutOfStockNeverCharges(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 1, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 2})
+
+	req, _ := http.NewRequest("POST", "/api/v1/cart/checkout", nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestToken(user.ID, "user"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, deps.Payment.Charges, "Customer must not be charged without stock")
+
+	var orderCount int64
+	deps.DB.Model(&models.Order{}).Count(&orderCount)
+	assert.Equal(t, int64(0), orderCount)
+}
+
+func TestCheckoutRefundsWhenOrderCannotBeCompleted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 2})
+
+	// Simulate the database failing right after the charge went through
+	deps.DB.Callback().Update().Before("gorm:update").Register("test:fail_paid", func(db *gorm.DB) {
+		if db.Statement.Table == "orders" && fmt.Sprint(db.Statement.Dest) == fmt.Sprint(map[string]interface{}{"status": models.OrderStatusPaid}) {
+			db.AddError(errors.New("database went away"))
+		}
+	})
+
+	req, _ := http.NewRequest("POST", "/api/v1/cart/checkout", nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestToken(user.ID, "user"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	if assert.Len(t, deps.Payment.Refunds, 1) {
+		assert.Equal(t, "TEST_TXN_123", deps.Payment.Refunds[0].TransactionId)
+	}
+
+	var payment models.Payment
+	deps.DB.Where("transaction_id = ?", "TEST_TXN_123").First(&payment)
+	assert.Equal(t, models.PaymentStatusRefunded, payment.Status)
+	assert.Equal(t, models.Money(12000), payment.Refunded)
+
+	var order models.Order
+	deps.DB.Where("user_id = ?", user.ID).First(&order)
+	assert.Equal(t, models.OrderStatusCancelled, order.Status)
+
+	var updatedProduct models.Product
+	deps.DB.First(&updatedProduct, product.ID)
+	assert.Equal(t, 10, updatedProduct.Stock)
+}
+
+func TestCheckoutKeepsOrderReservedUntilRefunded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 2})
+
+	// The database fails right after the charge went through, and the
+	// payment service goes down before it can be refunded
+	deps.DB.Callback().Update().Before("gorm:update").Register("test:fail_paid", func(db *gorm.DB) {
+		if db.Statement.Table == "orders" && fmt.Sprint(db.Statement.Dest) == fmt.Sprint(map[string]interface{}{"status": models.OrderStatusPaid}) {
+			db.AddError(errors.New("database went away"))
+		}
+	})
+	deps.Payment.Unavailable = true
+
+	w := authorizedRequest(r, "POST", "/api/v1/cart/checkout", GenerateTestToken(user.ID, "user"), "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "refund is pending")
+
+	var payment models.Payment
+	deps.DB.Where("transaction_id = ?", "TEST_TXN_123").First(&payment)
+	assert.Equal(t, models.PaymentStatusRefundPending, payment.Status)
+
+	var order models.Order
+	deps.DB.Where("user_id = ?", user.ID).First(&order)
+	assert.Equal(t, models.OrderStatusPending, order.Status, "The order must keep its stock while the charge stands")
+	var reservedProduct models.Product
+	deps.DB.First(&reservedProduct, product.ID)
+	assert.Equal(t, 8, reservedProduct.Stock)
+
+	// The event workers refund the charge once the payment service is back
+	deps.Payment.Unavailable = false
+	deps.DeliverEvents()
+
+	deps.DB.First(&payment, payment.ID)
+	assert.Equal(t, models.PaymentStatusRefunded, payment.Status)
+	assert.Equal(t, models.Money(12000), payment.Refunded)
+	assert.Equal(t, pb.PaymentStatus_PAYMENT_STATUS_REFUNDED, deps.Payment.Payments["TEST_TXN_123"].Status)
+
+	deps.DB.First(&order, order.ID)
+	assert.Equal(t, models.OrderStatusCancelled, order.Status)
+	var updatedProduct models.Product
+	deps.DB.First(&updatedProduct, product.ID)
+	assert.Equal(t, 10, updatedProduct.Stock)
+}
+
+func TestAdminRefundOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 2})
+
+	checkoutReq, _ := http.NewRequest("POST", "/api/v1/cart/checkout", nil)
+	checkoutReq.Header.Set("Authorization", "Bearer "+GenerateTestToken(user.ID, "user"))
+	checkoutW := httptest.NewRecorder()
+	r.ServeHTTP(checkoutW, checkoutReq)
+	assert.Equal(t, http.StatusCreated, checkoutW.Code)
+
+	var order models.Order
+	deps.DB.Where("user_id = ?", user.ID).First(&order)
+
+	adminToken := GenerateTestToken(99, "admin")
+	refund := func(token, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/admin/orders/%d/refund", order.ID), bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Customers can't refund themselves
+	assert.Equal(t, http.StatusForbidden, refund(GenerateTestToken(user.ID, "user"), "").Code)
+
+	// Partial refund
+	w1 := refund(adminToken, `{"amount": 2500}`)
+	assert.Equal(t, http.StatusOK, w1.Code)
+	var payment struct {
+		Refunded models.Money `json:"refunded"`
+		Currency string       `json:"currency"`
+		Status   string       `json:"status"`
+	}
+	json.Unmarshal(w1.Body.Bytes(), &payment)
+	assert.Equal(t, models.Money(2500), payment.Refunded)
+	assert.Equal(t, "USD", payment.Currency)
+	assert.Equal(t, "partially_refunded", payment.Status)
+
+	// Refunding more than what's left is rejected
+	assert.Equal(t, http.StatusUnprocessableEntity, refund(adminToken, `{"amount": 10000}`).Code)
+
+	// Empty body refunds the rest
+	w2 := refund(adminToken, "")
+	assert.Equal(t, http.StatusOK, w2.Code)
+	json.Unmarshal(w2.Body.Bytes(), &payment)
+	assert.Equal(t, models.Money(12000), payment.Refunded)
+	assert.Equal(t, "refunded", payment.Status)
+
+	deps.DB.First(&order, order.ID)
+	assert.Equal(t, models.OrderStatusRefunded, order.Status)
+
+	var recorded models.Payment
+	deps.DB.Where("order_id = ?", order.ID).First(&recorded)
+	assert.Equal(t, models.PaymentStatusRefunded, recorded.Status)
+	assert.Equal(t, models.Money(12000), recorded.Refunded)
+
+	// Payment status can be looked up afterwards
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/admin/orders/%d/payment", order.ID), nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	w3 := httptest.NewRecorder()
+	r.ServeHTTP(w3, req)
+	assert.Equal(t, http.StatusOK, w3.Code)
+	assert.Contains(t, w3.Body.String(), `"transaction_id":"TEST_TXN_123"`)
+}
+
+func TestAdminVoidOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 2})
+	unpaid := models.Order{UserID: user.ID, TotalCents: 100, Status: models.OrderStatusPending}
+	deps.DB.Create(&unpaid)
+
+	checkoutReq, _ := http.NewRequest("POST", "/api/v1/cart/checkout", nil)
+	checkoutReq.Header.Set("Authorization", "Bearer "+GenerateTestToken(user.ID, "user"))
+	r.ServeHTTP(httptest.NewRecorder(), checkoutReq)
+
+	var order models.Order
+	deps.DB.Where("user_id = ? AND status = ?", user.ID, models.OrderStatusPaid).First(&order)
+
+	adminToken := GenerateTestToken(99, "admin")
+	void := func(orderID uint) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/admin/orders/%d/void", orderID), nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w1 := void(order.ID)
+	assert.Equal(t, http.StatusOK, w1.Code)
+	assert.Contains(t, w1.Body.String(), `"status":"voided"`)
+
+	deps.DB.First(&order, order.ID)
+	assert.Equal(t, models.OrderStatusCancelled, order.Status)
+	var updatedProduct models.Product
+	deps.DB.First(&updatedProduct, product.ID)
+	assert.Equal(t, 10, updatedProduct.Stock, "Voided order should give its stock back")
+
+	// Already cancelled, never paid, or unknown
+	assert.Equal(t, http.StatusConflict, void(order.ID).Code)
+	assert.Equal(t, http.StatusConflict, void(unpaid.ID).Code)
+	assert.Equal(t, http.StatusNotFound, void(999).Code)
+}
+
+func TestAdminVoidOrderRetriesFailedVoid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	order := placePaidOrder(t, deps, r, user, product)
+
+	// The order is cancelled before the payment service is asked to void
+	deps.Payment.Unavailable = true
+	w := authorizedRequest(r, "POST", fmt.Sprintf("/api/v1/admin/orders/%d/void", order.ID), GenerateTestToken(99, "admin"), "")
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+
+	deps.DB.First(&order, order.ID)
+	assert.Equal(t, models.OrderStatusCancelled, order.Status)
+	var updatedProduct models.Product
+	deps.DB.First(&updatedProduct, product.ID)
+	assert.Equal(t, 10, updatedProduct.Stock)
+	var payment models.Payment
+	deps.DB.Where("order_id = ?", order.ID).First(&payment)
+	assert.Equal(t, models.PaymentStatusVoidPending, payment.Status)
+
+	deps.Payment.Unavailable = false
+	deps.DeliverEvents()
+
+	deps.DB.First(&payment, payment.ID)
+	assert.Equal(t, models.PaymentStatusVoided, payment.Status)
+	assert.Equal(t, pb.PaymentStatus_PAYMENT_STATUS_VOIDED, deps.Payment.Payments["TEST_TXN_123"].Status)
+}
+
+func TestAdminVoidOrderRejectsRefundedPayment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	order := placePaidOrder(t, deps, r, user, product)
+
+	adminToken := GenerateTestToken(99, "admin")
+	require.Equal(t, http.StatusOK, authorizedRequest(r, "POST", fmt.Sprintf("/api/v1/admin/orders/%d/refund", order.ID), adminToken, `{"amount": 1000}`).Code)
+
+	w := authorizedRequest(r, "POST", fmt.Sprintf("/api/v1/admin/orders/%d/void", order.ID), adminToken, "")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	deps.DB.First(&order, order.ID)
+	assert.Equal(t, models.OrderStatusPaid, order.Status, "A rejected void must leave the order as it was")
+	var updatedProduct models.Product
+	deps.DB.First(&updatedProduct, product.ID)
+	assert.Equal(t, 9, updatedProduct.Stock)
+}
+
+func TestCheckoutInRequestedCurrency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 2})
+
+	req, _ := http.NewRequest("POST", "/api/v1/cart/checkout?currency=EUR", nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestToken(user.ID, "user"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var order models.Order
+	deps.DB.Preload("Items").Where("user_id = ?", user.ID).First(&order)
+	assert.Equal(t, "EUR", order.Currency)
+	assert.Equal(t, "USD", order.BaseCurrency)
+	assert.Equal(t, 0.9, order.ExchangeRate)
+	assert.Equal(t, models.Money(10800), order.TotalCents)
+	assert.Equal(t, models.Money(5400), order.Items[0].Price)
+
+	if assert.Len(t, deps.Payment.Charges, 1) {
+		assert.Equal(t, int64(10800), deps.Payment.Charges[0].Amount.AmountMinor)
+		assert.Equal(t, "EUR", deps.Payment.Charges[0].Amount.Currency)
+	}
+}
+
+func TestCheckoutIdempotencyKeyReplaysResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 2})
+	token := GenerateTestToken(user.ID, "user")
+
+	checkout := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/cart/checkout", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Idempotency-Key", "checkout-1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := checkout()
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	// A retry after the cart was emptied replays the original response
+	second := checkout()
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	assert.JSONEq(t, first.Body.String(), second.Body.String())
+
+	assert.Len(t, deps.Payment.Charges, 1, "Customer must be charged once")
+	var orderCount int64
+	deps.DB.Model(&models.Order{}).Count(&orderCount)
+	assert.Equal(t, int64(1), orderCount)
+
+	var updatedProduct models.Product
+	deps.DB.First(&updatedProduct, product.ID)
+	assert.Equal(t, 8, updatedProduct.Stock)
+
+	// A retry in another currency isn't the same request
+	req, _ := http.NewRequest("POST", "/api/v1/cart/checkout?currency=EUR", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Idempotency-Key", "checkout-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Reusing the key for a different cart is a conflict
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 1})
+	third := checkout()
+	assert.Equal(t, http.StatusConflict, third.Code)
+	assert.Len(t, deps.Payment.Charges, 1)
+}
+
+func TestCheckoutIdempotencyKeyReleasedOnFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+	deps.Payment.Decline = true
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 1})
+	token := GenerateTestToken(user.ID, "user")
+
+	checkout := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/cart/checkout", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Idempotency-Key", "checkout-1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, checkout().Code)
+
+	// The declined attempt must not block a retry with the same key
+	deps.Payment.Decline = false
+	w := checkout()
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+	assert.Len(t, deps.Payment.Charges, 2)
+}
+
+func TestCheckoutIdempotencyKeyInProgress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 1})
+
+	// Another request has claimed the key for this cart and not finished yet
+	fingerprint, err := deps.Orders.CheckoutFingerprint(user.ID, "")
+	require.NoError(t, err)
+	claim := models.IdempotencyKey{UserID: user.ID, Key: "checkout-1", Fingerprint: fingerprint}
+	deps.DB.Create(&claim)
+
+	checkout := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/cart/checkout", nil)
+		req.Header.Set("Authorization", "Bearer "+GenerateTestToken(user.ID, "user"))
+		req.Header.Set("Idempotency-Key", "checkout-1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := checkout()
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "still in progress")
+	assert.Empty(t, deps.Payment.Charges)
+
+	// The request crashed, and its claim is taken over once the lease ran out
+	deps.DB.Model(&claim).Update("created_at", time.Now().Add(-10*time.Minute))
+	w = checkout()
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+	assert.Len(t, deps.Payment.Charges, 1)
+}
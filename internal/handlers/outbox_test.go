@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"game-store-api/internal/jobs"
+	"game-store-api/internal/models"
+	"game-store-api/internal/repository"
+	"game-store-api/internal/worker"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func outboxEvents(deps TestDeps, eventType string) []models.OutboxEvent {
+	var events []models.OutboxEvent
+	deps.DB.Where("type = ?", eventType).Order("id").Find(&events)
+	return events
+}
+
+func TestRegisterWritesOutboxEvent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	registerAndLogin(t, r, "test@example.com")
+	// A failed registration leaves no event behind
+	w := postJSON(r, "/api/v1/auth/register", map[string]string{"email": "test@example.com", "password": "mysecretpassword"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	events := outboxEvents(deps, models.EventUserRegistered)
+	require.Len(t, events, 1)
+	assert.Nil(t, events[0].PublishedAt)
+
+	var data models.UserRegisteredEvent
+	require.NoError(t, json.Unmarshal([]byte(events[0].Payload), &data))
+	assert.Equal(t, "test@example.com", data.Email)
+	assert.Equal(t, events[0].AggregateID, data.UserID)
+
+	deps.DeliverEvents()
+
+	deps.DB.First(&events[0], events[0].ID)
+	assert.NotNil(t, events[0].PublishedAt)
+	assert.NotNil(t, deps.Emails.Last("welcome_email"))
+	assert.NotNil(t, deps.Emails.Last("verify_email"))
+
+	// Publishing again sends nothing new
+	sent := len(deps.Emails.Tasks)
+	deps.DeliverEvents()
+	assert.Len(t, deps.Emails.Tasks, sent)
+}
+
+func TestCheckoutWritesOrderEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	token := GenerateTestToken(user.ID, "user")
+
+	// Not enough stock: nothing is committed, so nothing is announced
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 20})
+	assert.Equal(t, http.StatusBadRequest, authorizedRequest(r, "POST", "/api/v1/cart/checkout", token, "").Code)
+	var count int64
+	deps.DB.Model(&models.OutboxEvent{}).Count(&count)
+	assert.Zero(t, count)
+
+	deps.DB.Model(&models.CartItem{}).Where("user_id = ?", user.ID).Update("quantity", 2)
+	assert.Equal(t, http.StatusCreated, authorizedRequest(r, "POST", "/api/v1/cart/checkout", token, "").Code)
+
+	stock := outboxEvents(deps, models.EventStockChanged)
+	require.Len(t, stock, 1)
+	var stockData models.StockChangedEvent
+	json.Unmarshal([]byte(stock[0].Payload), &stockData)
+	assert.Equal(t, models.StockChangedEvent{ProductID: product.ID, PreviousStock: 10, Stock: 8}, stockData)
+
+	paid := outboxEvents(deps, models.EventOrderPaid)
+	require.Len(t, paid, 1)
+	var paidData models.OrderEvent
+	json.Unmarshal([]byte(paid[0].Payload), &paidData)
+	assert.Equal(t, models.OrderStatusPaid, paidData.Status)
+	assert.Equal(t, models.OrderStatusPending, paidData.PreviousStatus)
+	assert.Equal(t, models.Money(12000), paidData.Total)
+
+	deps.DeliverEvents()
+	confirmation := deps.Emails.Last("order_confirmation")
+	require.NotNil(t, confirmation)
+	assert.Equal(t, "gamer@test.com", confirmation["email"])
+	assert.Equal(t, "120.00", confirmation["total"])
+	assert.Equal(t, "USD", confirmation["currency"])
+
+	// A declined payment releases the stock and cancels the order, but the
+	// customer gets no cancellation email for an order they never paid for
+	deps.Payment.Decline = true
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 1})
+	assert.Equal(t, http.StatusBadRequest, authorizedRequest(r, "POST", "/api/v1/cart/checkout", token, "").Code)
+
+	cancelled := outboxEvents(deps, models.EventOrderCancelled)
+	require.Len(t, cancelled, 1)
+	assert.Len(t, outboxEvents(deps, models.EventStockChanged), 3)
+
+	deps.DeliverEvents()
+	assert.Nil(t, deps.Emails.Last("order_cancelled"))
+}
+
+// failingStore is a job store that can't accept jobs, like Redis when it is down.
+type failingStore struct {
+	jobs.Store
+}
+
+func (failingStore) Push(ctx context.Context, queue string, job *jobs.Job) error {
+	return errors.New("connection refused")
+}
+
+func TestOutboxRelayKeepsEventsUntilPublished(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	registerAndLogin(t, r, "test@example.com")
+
+	down := worker.NewOutboxRelay(repository.NewOutboxRepository(deps.DB), jobs.NewQueue("events", failingStore{}, jobs.Options{}))
+	published, err := down.PublishPending(context.Background())
+	assert.Error(t, err)
+	assert.Zero(t, published)
+
+	events := outboxEvents(deps, models.EventUserRegistered)
+	require.Len(t, events, 1)
+	assert.Nil(t, events[0].PublishedAt)
+	assert.Equal(t, 1, events[0].Attempts)
+	assert.Equal(t, "connection refused", events[0].LastError)
+
+	deps.DeliverEvents()
+	deps.DB.First(&events[0], events[0].ID)
+	assert.NotNil(t, events[0].PublishedAt)
+	assert.Empty(t, events[0].LastError)
+	assert.NotNil(t, deps.Emails.Last("verify_email"))
+
+	// Published events are pruned once they are old enough
+	removed, err := repository.NewOutboxRepository(deps.DB).DeletePublishedEvents(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, removed)
+}
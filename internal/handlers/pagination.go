@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// parsePagination reads the page and limit query parameters, falling back to
+// sane defaults for missing or out-of-range values.
+func parsePagination(c *gin.Context) (page, limit int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageLimit)))
+	if err != nil || limit < 1 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return page, limit
+}
+
+// paginated wraps a page of results with the total count and a link to the
+// next page, if there is one. The link keeps every other query parameter.
+func paginated(c *gin.Context, data interface{}, total int64, page, limit int) gin.H {
+	var next *string
+	if int64(page*limit) < total {
+		query := c.Request.URL.Query()
+		query.Set("page", strconv.Itoa(page+1))
+		query.Set("limit", strconv.Itoa(limit))
+		link := c.Request.URL.Path + "?" + query.Encode()
+		next = &link
+	}
+
+	return gin.H{
+		"data":  data,
+		"page":  page,
+		"limit": limit,
+		"total": total,
+		"next":  next,
+	}
+}
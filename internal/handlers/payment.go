@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"errors"
+	"game-store-api/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PaymentHandler struct {
+	service *service.PaymentService
+}
+
+func NewPaymentHandler(s *service.PaymentService) *PaymentHandler {
+	return &PaymentHandler{service: s}
+}
+
+func (h *PaymentHandler) GetPayment(c *gin.Context) {
+	payment, err := h.service.GetPaymentByTransactionID(c.Param("transaction_id"))
+	if errors.Is(err, service.ErrPaymentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"game-store-api/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminGetPaymentByTransactionID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	deps.DB.Create(&models.CartItem{UserID: user.ID, ProductID: product.ID, Quantity: 2})
+
+	checkoutReq, _ := http.NewRequest("POST", "/api/v1/cart/checkout", nil)
+	checkoutReq.Header.Set("Authorization", "Bearer "+GenerateTestToken(user.ID, "user"))
+	checkoutW := httptest.NewRecorder()
+	r.ServeHTTP(checkoutW, checkoutReq)
+	assert.Equal(t, http.StatusCreated, checkoutW.Code)
+
+	var placed struct {
+		OrderID uint `json:"order_id"`
+	}
+	json.Unmarshal(checkoutW.Body.Bytes(), &placed)
+
+	getPayment := func(token, transactionID string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/v1/admin/payments/"+transactionID, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	adminToken := GenerateTestToken(99, "admin")
+	w := getPayment(adminToken, "TEST_TXN_123")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var payment models.Payment
+	json.Unmarshal(w.Body.Bytes(), &payment)
+	assert.Equal(t, placed.OrderID, payment.OrderID)
+	if assert.NotNil(t, payment.TransactionID) {
+		assert.Equal(t, "TEST_TXN_123", *payment.TransactionID)
+	}
+	assert.Equal(t, models.Money(12000), payment.Amount)
+	assert.Equal(t, "USD", payment.Currency)
+	assert.Equal(t, models.PaymentStatusCaptured, payment.Status)
+	assert.Equal(t, "Payment processed via Mock", payment.ProviderMessage)
+	assert.False(t, payment.CreatedAt.IsZero())
+
+	assert.Equal(t, http.StatusNotFound, getPayment(adminToken, "UNKNOWN").Code)
+	assert.Equal(t, http.StatusForbidden, getPayment(GenerateTestToken(user.ID, "user"), "TEST_TXN_123").Code)
+}
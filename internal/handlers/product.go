@@ -1,10 +1,17 @@
 package handlers
 
 import (
+	"encoding/csv"
+	"errors"
+	"game-store-api/internal/media"
 	"game-store-api/internal/models"
+	"game-store-api/internal/repository"
 	"game-store-api/internal/service"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,46 +24,496 @@ func NewProductHandler(s *service.ProductService) *ProductHandler {
 	return &ProductHandler{service: s}
 }
 
+// productInput carries a price in the minor unit of currency and a
+// release_date as YYYY-MM-DD. An omitted currency is USD for a new product
+// and the stored one on update. The term IDs say which
+// categories, platforms and publishers the product belongs to.
+type productInput struct {
+	Name         string       `json:"name" binding:"required"`
+	Description  string       `json:"description"`
+	Price        models.Money `json:"price" binding:"gte=0"`
+	Currency     string       `json:"currency"`
+	Stock        int          `json:"stock" binding:"gte=0"`
+	SKU          string       `json:"sku" binding:"required"`
+	Digital      bool         `json:"digital"`
+	Developer    string       `json:"developer"`
+	ReleaseDate  string       `json:"release_date"`
+	AgeRating    string       `json:"age_rating" binding:"max=16"`
+	CategoryIDs  []uint       `json:"category_ids"`
+	PlatformIDs  []uint       `json:"platform_ids"`
+	PublisherIDs []uint       `json:"publisher_ids"`
+}
+
+// productPatchInput is productInput for PATCH. An empty release_date clears it.
+type productPatchInput struct {
+	Name         *string       `json:"name" binding:"omitempty,min=1"`
+	Description  *string       `json:"description"`
+	Price        *models.Money `json:"price" binding:"omitempty,gte=0"`
+	Currency     *string       `json:"currency"`
+	Stock        *int          `json:"stock" binding:"omitempty,gte=0"`
+	SKU          *string       `json:"sku" binding:"omitempty,min=1"`
+	Digital      *bool         `json:"digital"`
+	Developer    *string       `json:"developer"`
+	ReleaseDate  *string       `json:"release_date"`
+	AgeRating    *string       `json:"age_rating" binding:"omitempty,max=16"`
+	CategoryIDs  *[]uint       `json:"category_ids"`
+	PlatformIDs  *[]uint       `json:"platform_ids"`
+	PublisherIDs *[]uint       `json:"publisher_ids"`
+}
+
+// terms maps the term IDs of the input to their taxonomies.
+func (input productInput) terms() map[repository.Taxonomy][]uint {
+	return map[repository.Taxonomy][]uint{
+		repository.Categories: input.CategoryIDs,
+		repository.Platforms:  input.PlatformIDs,
+		repository.Publishers: input.PublisherIDs,
+	}
+}
+
+// terms maps the term IDs present in the input to their taxonomies.
+func (input productPatchInput) terms() map[repository.Taxonomy][]uint {
+	terms := make(map[repository.Taxonomy][]uint)
+	for taxonomy, ids := range map[repository.Taxonomy]*[]uint{
+		repository.Categories: input.CategoryIDs,
+		repository.Platforms:  input.PlatformIDs,
+		repository.Publishers: input.PublisherIDs,
+	} {
+		if ids != nil {
+			terms[taxonomy] = *ids
+		}
+	}
+	return terms
+}
+
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
-	var input models.Product
+	var input productInput
 
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	err := h.service.CreateProduct(input.Name, input.Description, input.SKU, input.Price, input.Stock)
+	if input.Currency == "" {
+		input.Currency = models.DefaultCurrency
+	}
+	releaseDate, ok := releaseDateParam(c, input.ReleaseDate)
+	if !ok {
+		return
+	}
+
+	create := service.ProductInput{
+		Name:        input.Name,
+		Description: input.Description,
+		SKU:         input.SKU,
+		Price:       input.Price,
+		Currency:    input.Currency,
+		Stock:       input.Stock,
+		Digital:     input.Digital,
+		Developer:   input.Developer,
+		AgeRating:   input.AgeRating,
+		Terms:       input.terms(),
+	}
+	if !releaseDate.IsZero() {
+		create.ReleaseDate = &releaseDate
+	}
+
+	product, err := h.service.CreateProduct(create)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondProductError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, input)
+	c.JSON(http.StatusCreated, product)
 }
 
-func (h *ProductHandler) GetAllProducts(c *gin.Context) {
-	products, err := h.service.GetAllProducts()
+// GetProducts lists the catalogue. Supported query parameters:
+// q, min_price, max_price, in_stock, sort (price|name|created_at|rating), order (asc|desc), page, limit,
+// category, platform and publisher (comma-separated slugs)
+// and currency (also read from the Accept-Currency header).
+func (h *ProductHandler) GetProducts(c *gin.Context) {
+	page, limit := parsePagination(c)
+	filter := repository.ProductFilter{
+		Query:      c.Query("q"),
+		SortBy:     c.DefaultQuery("sort", "created_at"),
+		Desc:       c.DefaultQuery("order", "desc") == "desc",
+		Limit:      limit,
+		Offset:     (page - 1) * limit,
+		Categories: slugsQuery(c, "category"),
+		Platforms:  slugsQuery(c, "platform"),
+		Publishers: slugsQuery(c, "publisher"),
+	}
+
+	if filter.SortBy != "price" && filter.SortBy != "name" && filter.SortBy != "created_at" && filter.SortBy != "rating" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of price, name, created_at, rating"})
+		return
+	}
+	if order := c.DefaultQuery("order", "desc"); order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return
+	}
+
+	var ok bool
+	if filter.MinPrice, ok = optionalMoneyQuery(c, "min_price"); !ok {
+		return
+	}
+	if filter.MaxPrice, ok = optionalMoneyQuery(c, "max_price"); !ok {
+		return
+	}
+	if raw := c.Query("in_stock"); raw != "" {
+		inStock, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "in_stock must be true or false"})
+			return
+		}
+		filter.InStock = inStock
+	}
+
+	products, total, err := h.service.ListProducts(filter, requestedCurrency(c))
+	if errors.Is(err, service.ErrUnsupportedCurrency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
 		return
 	}
 
-	c.JSON(http.StatusOK, products)
+	c.JSON(http.StatusOK, paginated(c, products, total, page, limit))
 }
 
 func (h *ProductHandler) GetProduct(c *gin.Context) {
-	idStr := c.Param("product_id")
-	id, err := strconv.ParseUint(idStr, 10, 64)
+	id, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	product, err := h.service.GetProductByID(id, requestedCurrency(c))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		respondProductError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+// UpdateProduct replaces every editable field of a product (PUT). The stock
+// of a digital product follows its keys, so it is ignored here, and the
+// product keeps its currency unless one is given.
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
+	id, ok := productIDParam(c)
+	if !ok {
 		return
 	}
 
-	product, err := h.service.GetProductByID(uint(id))
+	var input productInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	releaseDate, ok := releaseDateParam(c, input.ReleaseDate)
+	if !ok {
+		return
+	}
+
+	update := service.ProductUpdate{
+		Name:        &input.Name,
+		Description: &input.Description,
+		SKU:         &input.SKU,
+		Price:       &input.Price,
+		Digital:     &input.Digital,
+		Developer:   &input.Developer,
+		ReleaseDate: &releaseDate,
+		AgeRating:   &input.AgeRating,
+		Terms:       input.terms(),
+	}
+	if input.Currency != "" {
+		update.Currency = &input.Currency
+	}
+	if !input.Digital {
+		update.Stock = &input.Stock
+	}
+
+	product, err := h.service.UpdateProduct(id, update)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		respondProductError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+// PatchProduct changes only the fields present in the request body (PATCH).
+func (h *ProductHandler) PatchProduct(c *gin.Context) {
+	id, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input productPatchInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	update := service.ProductUpdate{
+		Name:        input.Name,
+		Description: input.Description,
+		SKU:         input.SKU,
+		Price:       input.Price,
+		Currency:    input.Currency,
+		Stock:       input.Stock,
+		Digital:     input.Digital,
+		Developer:   input.Developer,
+		AgeRating:   input.AgeRating,
+		Terms:       input.terms(),
+	}
+	if input.ReleaseDate != nil {
+		releaseDate, ok := releaseDateParam(c, *input.ReleaseDate)
+		if !ok {
+			return
+		}
+		update.ReleaseDate = &releaseDate
+	}
+
+	product, err := h.service.UpdateProduct(id, update)
+	if err != nil {
+		respondProductError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, product)
 }
+
+func (h *ProductHandler) DeleteProduct(c *gin.Context) {
+	id, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteProduct(id); err != nil {
+		respondProductError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product deleted"})
+}
+
+func (h *ProductHandler) RestoreProduct(c *gin.Context) {
+	id, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	product, err := h.service.RestoreProduct(id)
+	if err != nil {
+		respondProductError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+// maxKeyUploadSize caps a key upload at 5 MB, far more than any real batch.
+const maxKeyUploadSize = 5 << 20
+
+// UploadKeys adds game keys to a digital product. The keys come as CSV,
+// either as a multipart "file" or as the request body, with the key in the
+// first column of each row and an optional "code" header row.
+func (h *ProductHandler) UploadKeys(c *gin.Context) {
+	id, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxKeyUploadSize)
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Upload the keys as a CSV in the file field"})
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the uploaded file"})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	codes, err := readKeyCodes(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV: " + err.Error()})
+		return
+	}
+
+	result, err := h.service.AddKeys(id, codes)
+	if err != nil {
+		respondProductError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// UploadImage adds cover art or a screenshot to a product. The image comes
+// as a multipart "file", with an optional "kind" field of cover or
+// screenshot (the default).
+func (h *ProductHandler) UploadImage(c *gin.Context) {
+	id, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	// Leave room for the rest of the multipart body around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, media.MaxImageSize+1<<20)
+	file, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": media.ErrImageTooLarge.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload the image in the file field"})
+		return
+	}
+	if file.Size > media.MaxImageSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": media.ErrImageTooLarge.Error()})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the uploaded file"})
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the uploaded file"})
+		return
+	}
+
+	image, err := h.service.AddImage(id, c.PostForm("kind"), data)
+	if err != nil {
+		respondProductError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, image)
+}
+
+func (h *ProductHandler) DeleteImage(c *gin.Context) {
+	id, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+	imageID, err := strconv.ParseUint(c.Param("image_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+		return
+	}
+
+	if err := h.service.DeleteImage(id, uint(imageID)); err != nil {
+		respondProductError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Image deleted"})
+}
+
+// readKeyCodes reads the first column of every CSV row, skipping a leading
+// "code" header.
+func readKeyCodes(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var codes []string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(codes) == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "code") {
+			continue
+		}
+		codes = append(codes, record[0])
+	}
+	return codes, nil
+}
+
+func productIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("product_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// releaseDateParam parses a YYYY-MM-DD release date, responding with 400
+// when it is malformed. An empty date yields the zero time.
+func releaseDateParam(c *gin.Context, raw string) (time.Time, bool) {
+	if raw == "" {
+		return time.Time{}, true
+	}
+	date, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "release_date must be a date like 2024-03-21"})
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// slugsQuery splits a comma-separated list of slugs from the query string.
+func slugsQuery(c *gin.Context, name string) []string {
+	var slugs []string
+	for _, slug := range strings.Split(c.Query(name), ",") {
+		if slug = strings.TrimSpace(slug); slug != "" {
+			slugs = append(slugs, strings.ToLower(slug))
+		}
+	}
+	return slugs
+}
+
+// optionalMoneyQuery parses a non-negative minor-unit amount from the query
+// string, responding with 400 when it is malformed. A missing parameter yields nil.
+func optionalMoneyQuery(c *gin.Context, name string) (*models.Money, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, true
+	}
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || value < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a non-negative integer"})
+		return nil, false
+	}
+	amount := models.Money(value)
+	return &amount, true
+}
+
+func respondProductError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, service.ErrImageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+	case errors.Is(err, service.ErrUnsupportedCurrency), errors.Is(err, service.ErrNoKeys), errors.Is(err, service.ErrInvalidKey),
+		errors.Is(err, service.ErrUnknownTerm), errors.Is(err, service.ErrInvalidImageKind):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUnsupportedImage):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrImageTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrStockManagedByKeys), errors.Is(err, service.ErrProductNotDigital), errors.Is(err, service.ErrTooManyImages):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrDuplicateSKU), errors.Is(err, service.ErrProductNotDeleted):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
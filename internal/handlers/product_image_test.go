@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"game-store-api/internal/models"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pngImage encodes a width x height PNG of a single colour.
+func pngImage(width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 200, 40, 40, 255
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func uploadImage(r *gin.Engine, productID uint, token, kind string, data []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if kind != "" {
+		form.WriteField("kind", kind)
+	}
+	part, _ := form.CreateFormFile("file", "upload.png")
+	part.Write(data)
+	form.Close()
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/products/%d/images", productID), &body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminUploadsProductImages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+	adminToken := GenerateTestToken(99, "admin")
+
+	product := models.Product{Name: "Celeste", Price: 1999, Stock: 5, SKU: "CEL-1"}
+	deps.DB.Create(&product)
+
+	w := uploadImage(r, product.ID, adminToken, "screenshot", pngImage(1200, 600))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var screenshot models.ProductImage
+	json.Unmarshal(w.Body.Bytes(), &screenshot)
+	assert.Equal(t, "screenshot", screenshot.Kind)
+	assert.Equal(t, "image/png", screenshot.ContentType)
+	assert.Equal(t, 1200, screenshot.Width)
+	assert.Equal(t, 600, screenshot.Height)
+	assert.Regexp(t, fmt.Sprintf(`^/media/products/%d/[0-9a-f]{32}\.png$`, product.ID), screenshot.URL)
+	assert.Regexp(t, fmt.Sprintf(`^/media/products/%d/[0-9a-f]{32}-thumb\.jpg$`, product.ID), screenshot.ThumbnailURL)
+
+	// The thumbnail keeps the aspect ratio within 400x400
+	stored := deps.Storage.Files[strings.TrimPrefix(screenshot.ThumbnailURL, "/media/")]
+	require.NotEmpty(t, stored)
+	thumbnail, err := jpeg.Decode(bytes.NewReader(stored))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 400, 200), thumbnail.Bounds())
+	red, _, _, _ := thumbnail.At(10, 10).RGBA()
+	assert.InDelta(t, 200, red>>8, 8)
+	assert.Equal(t, pngImage(1200, 600), deps.Storage.Files[strings.TrimPrefix(screenshot.URL, "/media/")])
+
+	w = uploadImage(r, product.ID, adminToken, "cover", pngImage(300, 400))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var cover models.ProductImage
+	json.Unmarshal(w.Body.Bytes(), &cover)
+
+	// Product responses list the cover first
+	w = authorizedRequest(r, "GET", fmt.Sprintf("/api/v1/products/%d", product.ID), "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var fetched models.Product
+	json.Unmarshal(w.Body.Bytes(), &fetched)
+	require.Len(t, fetched.Images, 2)
+	assert.Equal(t, cover.ID, fetched.Images[0].ID)
+	assert.Equal(t, cover.URL, fetched.Images[0].URL)
+	assert.Equal(t, screenshot.ID, fetched.Images[1].ID)
+	assert.Len(t, deps.Storage.Files, 4)
+
+	w = authorizedRequest(r, "GET", "/api/v1/products", "", "")
+	var list struct {
+		Data []models.Product `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	require.Len(t, list.Data, 1)
+	require.Len(t, list.Data[0].Images, 2)
+	assert.Equal(t, cover.ThumbnailURL, list.Data[0].Images[0].ThumbnailURL)
+
+	// A new cover replaces the old one and its files
+	w = uploadImage(r, product.ID, adminToken, "cover", pngImage(300, 400))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var newCover models.ProductImage
+	json.Unmarshal(w.Body.Bytes(), &newCover)
+	assert.NotContains(t, deps.Storage.Files, strings.TrimPrefix(cover.URL, "/media/"))
+	assert.NotContains(t, deps.Storage.Files, strings.TrimPrefix(cover.ThumbnailURL, "/media/"))
+	var count int64
+	deps.DB.Model(&models.ProductImage{}).Where("product_id = ? AND kind = ?", product.ID, "cover").Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	// Deleting an image removes its files
+	path := fmt.Sprintf("/api/v1/products/%d/images/%d", product.ID, screenshot.ID)
+	assert.Equal(t, http.StatusForbidden, authorizedRequest(r, "DELETE", path, GenerateTestToken(1, "user"), "").Code)
+	w = authorizedRequest(r, "DELETE", path, adminToken, "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, deps.Storage.Files, strings.TrimPrefix(screenshot.URL, "/media/"))
+	assert.Len(t, deps.Storage.Files, 2)
+	assert.Equal(t, http.StatusNotFound, authorizedRequest(r, "DELETE", path, adminToken, "").Code)
+
+	// An image is only found under its own product
+	other := models.Product{Name: "Tunic", Price: 2999, Stock: 5, SKU: "TUN-1"}
+	deps.DB.Create(&other)
+	path = fmt.Sprintf("/api/v1/products/%d/images/%d", other.ID, newCover.ID)
+	assert.Equal(t, http.StatusNotFound, authorizedRequest(r, "DELETE", path, adminToken, "").Code)
+}
+
+func TestProductImageValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+	adminToken := GenerateTestToken(99, "admin")
+
+	product := models.Product{Name: "Celeste", Price: 1999, Stock: 5, SKU: "CEL-1"}
+	deps.DB.Create(&product)
+
+	assert.Equal(t, http.StatusForbidden, uploadImage(r, product.ID, GenerateTestToken(1, "user"), "", pngImage(10, 10)).Code)
+	assert.Equal(t, http.StatusNotFound, uploadImage(r, 999, adminToken, "", pngImage(10, 10)).Code)
+	assert.Equal(t, http.StatusBadRequest, uploadImage(r, product.ID, adminToken, "banner", pngImage(10, 10)).Code)
+
+	// The content decides the type, not the file name
+	w := uploadImage(r, product.ID, adminToken, "", []byte("<svg xmlns='http://www.w3.org/2000/svg'></svg>"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code, w.Body.String())
+	truncated := pngImage(50, 50)[:60]
+	assert.Equal(t, http.StatusUnsupportedMediaType, uploadImage(r, product.ID, adminToken, "", truncated).Code)
+
+	tooLarge := append(pngImage(10, 10), make([]byte, 10<<20)...)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, uploadImage(r, product.ID, adminToken, "", tooLarge).Code)
+
+	// JPEGs are kept as JPEGs
+	var jpg bytes.Buffer
+	small := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	small.Set(0, 0, color.White)
+	jpeg.Encode(&jpg, small, nil)
+	w = uploadImage(r, product.ID, adminToken, "", jpg.Bytes())
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var uploaded models.ProductImage
+	json.Unmarshal(w.Body.Bytes(), &uploaded)
+	assert.Equal(t, "image/jpeg", uploaded.ContentType)
+	assert.True(t, strings.HasSuffix(uploaded.URL, ".jpg"))
+
+	assert.Len(t, deps.Storage.Files, 2, "rejected uploads store nothing")
+}
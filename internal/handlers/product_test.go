@@ -3,6 +3,8 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"game-store-api/internal/models"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -36,3 +38,323 @@ func TestCreateProduct(t *testing.T) {
 
 	assert.Equal(t, http.StatusCreated, w.Code)
 }
+
+func TestUpdateProduct(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Old Name", Description: "Old", Price: 1000, Stock: 5, SKU: "UPD-1"}
+	deps.DB.Create(&product)
+	token := GenerateTestToken(1, "admin")
+
+	sendUpdate := func(method string, payload interface{}) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, fmt.Sprintf("/api/v1/products/%d", product.ID), bytes.NewBuffer(jsonValue))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// PUT replaces every field
+	w1 := sendUpdate("PUT", map[string]interface{}{
+		"name": "New Name", "description": "New", "price": 2000, "stock": 7, "sku": "UPD-2",
+	})
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var updated models.Product
+	deps.DB.First(&updated, product.ID)
+	assert.Equal(t, "New Name", updated.Name)
+	assert.Equal(t, models.Money(2000), updated.Price)
+	assert.Equal(t, "UPD-2", updated.SKU)
+
+	// PATCH only touches the given fields
+	w2 := sendUpdate("PATCH", map[string]interface{}{"stock": 0})
+	assert.Equal(t, http.StatusOK, w2.Code)
+
+	deps.DB.First(&updated, product.ID)
+	assert.Equal(t, 0, updated.Stock)
+	assert.Equal(t, "New Name", updated.Name, "Name should be untouched by PATCH")
+
+	// Negative values are rejected
+	w3 := sendUpdate("PATCH", map[string]interface{}{"price": -1})
+	assert.Equal(t, http.StatusBadRequest, w3.Code)
+
+	// PUT without a currency keeps the product's own
+	sendUpdate("PATCH", map[string]interface{}{"currency": "EUR"})
+	w4 := sendUpdate("PUT", map[string]interface{}{
+		"name": "Euro Name", "price": 2500, "stock": 7, "sku": "UPD-2",
+	})
+	assert.Equal(t, http.StatusOK, w4.Code, w4.Body.String())
+	deps.DB.First(&updated, product.ID)
+	assert.Equal(t, "EUR", updated.Currency)
+	assert.Equal(t, models.Money(2500), updated.Price)
+}
+
+func TestUpdateProductConflictsAndMissing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	first := models.Product{Name: "First", Price: 1000, Stock: 5, SKU: "SKU-1"}
+	deps.DB.Create(&first)
+	second := models.Product{Name: "Second", Price: 1000, Stock: 5, SKU: "SKU-2"}
+	deps.DB.Create(&second)
+	token := GenerateTestToken(1, "admin")
+
+	// Taking another product's SKU is a conflict
+	req1, _ := http.NewRequest("PATCH", fmt.Sprintf("/api/v1/products/%d", second.ID), bytes.NewBufferString(`{"sku":"SKU-1"}`))
+	req1.Header.Set("Authorization", "Bearer "+token)
+	w1 := httptest.NewRecorder()
+	r.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusConflict, w1.Code)
+
+	// Creating with a taken SKU is a conflict too
+	req2, _ := http.NewRequest("POST", "/api/v1/products", bytes.NewBufferString(`{"name":"Dup","price":1,"stock":1,"sku":"SKU-2"}`))
+	req2.Header.Set("Authorization", "Bearer "+token)
+	w2 := httptest.NewRecorder()
+	r.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusConflict, w2.Code)
+
+	// Unknown product
+	req3, _ := http.NewRequest("PATCH", "/api/v1/products/999", bytes.NewBufferString(`{"stock":1}`))
+	req3.Header.Set("Authorization", "Bearer "+token)
+	w3 := httptest.NewRecorder()
+	r.ServeHTTP(w3, req3)
+	assert.Equal(t, http.StatusNotFound, w3.Code)
+}
+
+func TestDeleteAndRestoreProduct(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Doomed", Price: 1000, Stock: 5, SKU: "DEL-1"}
+	deps.DB.Create(&product)
+	token := GenerateTestToken(1, "admin")
+	productURL := fmt.Sprintf("/api/v1/products/%d", product.ID)
+
+	send := func(method, url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Restoring a live product is a conflict
+	assert.Equal(t, http.StatusConflict, send("POST", productURL+"/restore").Code)
+
+	assert.Equal(t, http.StatusOK, send("DELETE", productURL).Code)
+	assert.Equal(t, http.StatusNotFound, send("GET", productURL).Code, "Deleted product should be hidden")
+	assert.Equal(t, http.StatusNotFound, send("DELETE", productURL).Code)
+
+	// Row is soft-deleted, not gone
+	var count int64
+	deps.DB.Unscoped().Model(&models.Product{}).Where("id = ?", product.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	assert.Equal(t, http.StatusOK, send("POST", productURL+"/restore").Code)
+	assert.Equal(t, http.StatusOK, send("GET", productURL).Code)
+}
+
+func TestListProducts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	deps.DB.Create(&models.Product{Name: "Elden Ring", Description: "Fantasy action RPG", Price: 5999, BasePrice: 5999, Stock: 50, SKU: "ELD-001"})
+	deps.DB.Create(&models.Product{Name: "Hollow Knight", Description: "Action adventure with insects", Price: 1499, BasePrice: 1499, Stock: 100, SKU: "HK-002"})
+	deps.DB.Create(&models.Product{Name: "Cyberpunk 2077", Description: "Open-world RPG", Price: 2999, BasePrice: 2999, Stock: 25, SKU: "CP-2077"})
+	deps.DB.Create(&models.Product{Name: "Half-Life 3", Description: "Never came out", Price: 99999, BasePrice: 99999, Stock: 0, SKU: "HL3"})
+
+	type listing struct {
+		Data  []models.Product `json:"data"`
+		Total int64            `json:"total"`
+		Next  *string          `json:"next"`
+	}
+	list := func(query string) (int, listing) {
+		req, _ := http.NewRequest("GET", "/api/v1/products"+query, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var body listing
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+	names := func(products []models.Product) []string {
+		var out []string
+		for _, p := range products {
+			out = append(out, p.Name)
+		}
+		return out
+	}
+
+	// Sorting by price ascending, paginated
+	code, page := list("?sort=price&order=asc&limit=3")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int64(4), page.Total)
+	assert.Equal(t, []string{"Hollow Knight", "Cyberpunk 2077", "Elden Ring"}, names(page.Data))
+	if assert.NotNil(t, page.Next) {
+		assert.Contains(t, *page.Next, "page=2")
+		assert.Contains(t, *page.Next, "sort=price")
+	}
+
+	// Price range and stock filters
+	_, page = list("?min_price=2000&max_price=100000&in_stock=true&sort=name&order=asc")
+	assert.Equal(t, []string{"Cyberpunk 2077", "Elden Ring"}, names(page.Data))
+	assert.Nil(t, page.Next)
+
+	// Search over name and description
+	_, page = list("?q=rpg&sort=name&order=asc")
+	assert.Equal(t, []string{"Cyberpunk 2077", "Elden Ring"}, names(page.Data))
+	_, page = list("?q=knight")
+	assert.Equal(t, []string{"Hollow Knight"}, names(page.Data))
+
+	// Bad parameters
+	code, _ = list("?sort=stock")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = list("?min_price=cheap")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestCreateProductCurrency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+	token := GenerateTestToken(1, "admin")
+
+	create := func(payload string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/products", bytes.NewBufferString(payload))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Zero-decimal currencies store whole yen as the minor unit
+	w1 := create(`{"name":"Import","price":7980,"currency":"JPY","stock":1,"sku":"JP-1"}`)
+	assert.Equal(t, http.StatusCreated, w1.Code)
+
+	var product models.Product
+	json.Unmarshal(w1.Body.Bytes(), &product)
+	assert.Equal(t, models.Money(7980), product.Price)
+	assert.Equal(t, "JPY", product.Currency)
+
+	// Currency defaults to USD
+	w2 := create(`{"name":"Local","price":1999,"stock":1,"sku":"US-1"}`)
+	json.Unmarshal(w2.Body.Bytes(), &product)
+	assert.Equal(t, "USD", product.Currency)
+
+	w3 := create(`{"name":"Bogus","price":100,"currency":"XYZ","stock":1,"sku":"XX-1"}`)
+	assert.Equal(t, http.StatusBadRequest, w3.Code)
+}
+
+func TestProductPricesInRequestedCurrency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Elden Ring", Price: 5999, BasePrice: 5999, Stock: 50, SKU: "ELD-001"}
+	deps.DB.Create(&product)
+
+	// Query parameter
+	req1, _ := http.NewRequest("GET", "/api/v1/products?currency=eur", nil)
+	w1 := httptest.NewRecorder()
+	r.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var page struct {
+		Data []models.Product `json:"data"`
+	}
+	json.Unmarshal(w1.Body.Bytes(), &page)
+	if assert.Len(t, page.Data, 1) {
+		assert.Equal(t, "EUR", page.Data[0].Currency)
+		assert.Equal(t, models.Money(5399), page.Data[0].Price, "59.99 USD * 0.9 = 53.99 EUR")
+	}
+
+	// Header, zero-decimal target currency
+	req2, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/products/%d", product.ID), nil)
+	req2.Header.Set("Accept-Currency", "JPY")
+	w2 := httptest.NewRecorder()
+	r.ServeHTTP(w2, req2)
+
+	var converted models.Product
+	json.Unmarshal(w2.Body.Bytes(), &converted)
+	assert.Equal(t, "JPY", converted.Currency)
+	assert.Equal(t, models.Money(8999), converted.Price, "59.99 USD * 150 = 8998.5 JPY, rounded")
+
+	// Price filters are given in the requested currency
+	req3, _ := http.NewRequest("GET", "/api/v1/products?currency=EUR&max_price=5000", nil)
+	w3 := httptest.NewRecorder()
+	r.ServeHTTP(w3, req3)
+	page.Data = nil
+	json.Unmarshal(w3.Body.Bytes(), &page)
+	assert.Empty(t, page.Data, "53.99 EUR is above a 50.00 EUR limit")
+
+	// Stored price is untouched
+	var stored models.Product
+	deps.DB.First(&stored, product.ID)
+	assert.Equal(t, models.Money(5999), stored.Price)
+	assert.Equal(t, "USD", stored.Currency)
+
+	req4, _ := http.NewRequest("GET", "/api/v1/products?currency=XYZ", nil)
+	w4 := httptest.NewRecorder()
+	r.ServeHTTP(w4, req4)
+	assert.Equal(t, http.StatusBadRequest, w4.Code)
+}
+
+func TestListProductsAcrossCurrencies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+	adminToken := GenerateTestToken(1, "admin")
+
+	// In USD: 40.00, 33.33, 20.00 and 62.50
+	ids := map[string]uint{}
+	for _, payload := range []string{
+		`{"name":"Local","price":4000,"currency":"USD","stock":1,"sku":"US-1"}`,
+		`{"name":"Euro","price":3000,"currency":"EUR","stock":1,"sku":"EU-1"}`,
+		`{"name":"Import","price":3000,"currency":"JPY","stock":1,"sku":"JP-1"}`,
+		`{"name":"Pound","price":5000,"currency":"GBP","stock":1,"sku":"GB-1"}`,
+	} {
+		w := authorizedRequest(r, "POST", "/api/v1/products", adminToken, payload)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var created models.Product
+		json.Unmarshal(w.Body.Bytes(), &created)
+		ids[created.Name] = created.ID
+	}
+
+	list := func(query string) []string {
+		w := authorizedRequest(r, "GET", "/api/v1/products"+query, "", "")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var page struct {
+			Data []models.Product `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &page)
+		var names []string
+		for _, p := range page.Data {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"Import", "Euro", "Local", "Pound"}, list("?sort=price&order=asc"))
+	assert.Equal(t, []string{"Pound", "Local", "Euro", "Import"}, list("?sort=price&order=desc"))
+
+	// Bounds apply to the converted prices, so ¥3000 isn't 30.00
+	assert.Equal(t, []string{"Euro", "Local"}, list("?min_price=2500&max_price=4500&sort=price&order=asc"))
+	assert.Equal(t, []string{"Euro", "Local"}, list("?currency=EUR&min_price=2700&max_price=4000&sort=price&order=asc"))
+	assert.Equal(t, []string{"Import", "Euro", "Local"}, list("?currency=JPY&max_price=6000&sort=price&order=asc"))
+
+	// Repricing moves the product
+	w := authorizedRequest(r, "PATCH", fmt.Sprintf("/api/v1/products/%d", ids["Import"]), adminToken, `{"price":9000}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, []string{"Euro", "Local", "Import", "Pound"}, list("?sort=price&order=asc"))
+	w = authorizedRequest(r, "PATCH", fmt.Sprintf("/api/v1/products/%d", ids["Import"]), adminToken, `{"price":9000,"currency":"USD"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, []string{"Euro", "Local", "Pound", "Import"}, list("?sort=price&order=asc"))
+
+	w = authorizedRequest(r, "PATCH", fmt.Sprintf("/api/v1/products/%d", ids["Import"]), adminToken, `{"currency":"XYZ"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
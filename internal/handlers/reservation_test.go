@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"game-store-api/internal/models"
+	"game-store-api/internal/repository"
+	"game-store-api/internal/service"
+	"game-store-api/internal/worker"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCartReservesStock(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 3, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	alice := GenerateTestToken(1, "user")
+	bob := GenerateTestToken(2, "user")
+	add := func(token string, quantity int) int {
+		body := fmt.Sprintf(`{"product_id":%d,"quantity":%d}`, product.ID, quantity)
+		return authorizedRequest(r, "POST", "/api/v1/cart", token, body).Code
+	}
+	available := func() int {
+		w := authorizedRequest(r, "GET", fmt.Sprintf("/api/v1/products/%d", product.ID), alice, "")
+		var shown models.Product
+		json.Unmarshal(w.Body.Bytes(), &shown)
+		assert.Equal(t, 3, shown.Stock)
+		return shown.Available
+	}
+
+	require.Equal(t, http.StatusOK, add(alice, 2))
+	assert.Equal(t, 1, available())
+
+	w := authorizedRequest(r, "POST", "/api/v1/cart", bob, fmt.Sprintf(`{"product_id":%d,"quantity":2}`, product.ID))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "only 1 of Zelda available")
+	require.Equal(t, http.StatusOK, add(bob, 1))
+	assert.Equal(t, 0, available())
+	assert.Equal(t, http.StatusNotFound, authorizedRequest(r, "POST", "/api/v1/cart", bob, `{"product_id":999,"quantity":1}`).Code)
+
+	// Held stock doesn't count as in stock, but the holder's cart is fine
+	w = authorizedRequest(r, "GET", "/api/v1/products?in_stock=true", alice, "")
+	assert.Contains(t, w.Body.String(), `"total":0`)
+	w = authorizedRequest(r, "GET", "/api/v1/cart", alice, "")
+	var cart service.CartView
+	json.Unmarshal(w.Body.Bytes(), &cart)
+	require.Len(t, cart.Items, 1)
+	assert.False(t, cart.HasWarnings)
+	assert.NotNil(t, cart.Items[0].ReservedUntil)
+
+	// Taking items out gives them back
+	require.Equal(t, http.StatusOK, add(alice, -1))
+	assert.Equal(t, 1, available())
+	require.Equal(t, http.StatusOK, authorizedRequest(r, "DELETE", fmt.Sprintf("/api/v1/cart/%d", product.ID), alice, "").Code)
+	assert.Equal(t, 2, available())
+
+	var reservations int64
+	deps.DB.Model(&models.StockReservation{}).Count(&reservations)
+	assert.Equal(t, int64(1), reservations)
+}
+
+func TestCartReservationsExpire(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 2, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	alice := GenerateTestToken(1, "user")
+	bob := GenerateTestToken(2, "user")
+	body := fmt.Sprintf(`{"product_id":%d,"quantity":2}`, product.ID)
+
+	require.Equal(t, http.StatusOK, authorizedRequest(r, "POST", "/api/v1/cart", alice, body).Code)
+	assert.Equal(t, http.StatusConflict, authorizedRequest(r, "POST", "/api/v1/cart", bob, body).Code)
+
+	// An expired hold stops counting before the sweeper gets to it
+	deps.DB.Model(&models.StockReservation{}).Where("user_id = ?", 1).Update("expires_at", time.Now().Add(-time.Minute))
+	require.Equal(t, http.StatusOK, authorizedRequest(r, "POST", "/api/v1/cart", bob, body).Code)
+
+	// Alice keeps her cart line, but it can no longer be bought
+	w := authorizedRequest(r, "GET", "/api/v1/cart", alice, "")
+	var cart service.CartView
+	json.Unmarshal(w.Body.Bytes(), &cart)
+	require.Len(t, cart.Items, 1)
+	assert.Nil(t, cart.Items[0].ReservedUntil)
+	if assert.Len(t, cart.Items[0].Warnings, 1) {
+		assert.Equal(t, service.WarningOutOfStock, cart.Items[0].Warnings[0].Code)
+	}
+
+	swept, err := worker.NewReservationSweeper(repository.NewReservationRepository(deps.DB)).Sweep(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), swept)
+	var reservations int64
+	deps.DB.Model(&models.StockReservation{}).Count(&reservations)
+	assert.Equal(t, int64(1), reservations)
+}
+
+func TestCheckoutRespectsReservations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Zelda", Price: 6000, Stock: 3, SKU: "ZEL-1"}
+	deps.DB.Create(&product)
+	alice := models.User{Email: "alice@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	bob := models.User{Email: "bob@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&alice)
+	deps.DB.Create(&bob)
+	aliceToken := GenerateTestToken(alice.ID, "user")
+	bobToken := GenerateTestToken(bob.ID, "user")
+
+	require.Equal(t, http.StatusOK, authorizedRequest(r, "POST", "/api/v1/cart", aliceToken, fmt.Sprintf(`{"product_id":%d,"quantity":2}`, product.ID)).Code)
+
+	// A cart line without a hold can't take what Alice holds
+	deps.DB.Create(&models.CartItem{UserID: bob.ID, ProductID: product.ID, Quantity: 2})
+	w := authorizedRequest(r, "POST", "/api/v1/cart/checkout", bobToken, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "not enough stock")
+
+	w = authorizedRequest(r, "POST", "/api/v1/cart/checkout", aliceToken, "")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	deps.DB.First(&product, product.ID)
+	assert.Equal(t, 1, product.Stock)
+
+	// Checkout turns the hold into a sale
+	var reservations int64
+	deps.DB.Model(&models.StockReservation{}).Count(&reservations)
+	assert.Equal(t, int64(0), reservations)
+	w = authorizedRequest(r, "GET", fmt.Sprintf("/api/v1/products/%d", product.ID), bobToken, "")
+	assert.Contains(t, w.Body.String(), `"available":1`)
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"game-store-api/internal/repository"
+	"game-store-api/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReviewHandler struct {
+	service *service.ReviewService
+}
+
+func NewReviewHandler(s *service.ReviewService) *ReviewHandler {
+	return &ReviewHandler{service: s}
+}
+
+type reviewInput struct {
+	Rating  int    `json:"rating" binding:"required,min=1,max=5"`
+	Comment string `json:"comment" binding:"max=5000"`
+}
+
+// ListReviews pages through a product's visible reviews, newest first.
+func (h *ReviewHandler) ListReviews(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+	page, limit := parsePagination(c)
+
+	reviews, total, err := h.service.ListReviews(productID, page, limit)
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, paginated(c, reviews, total, page, limit))
+}
+
+// CreateReview lets a customer who paid for the product review it once.
+func (h *ReviewHandler) CreateReview(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+	var input reviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	review, err := h.service.CreateReview(userID, productID, input.Rating, input.Comment)
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, review)
+}
+
+// UpdateReview replaces the rating and comment of the caller's own review.
+func (h *ReviewHandler) UpdateReview(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+	reviewID, ok := reviewIDParam(c)
+	if !ok {
+		return
+	}
+	var input reviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	review, err := h.service.UpdateReview(userID, productID, reviewID, input.Rating, input.Comment)
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+func (h *ReviewHandler) DeleteReview(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+	reviewID, ok := reviewIDParam(c)
+	if !ok {
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	if err := h.service.DeleteReview(userID, productID, reviewID); err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Review deleted"})
+}
+
+// ListAllReviews is the moderation view of reviews. Supported query
+// parameters: product_id, user_id, hidden (true|false), page and limit.
+func (h *ReviewHandler) ListAllReviews(c *gin.Context) {
+	page, limit := parsePagination(c)
+	filter := repository.ReviewFilter{
+		Limit:  limit,
+		Offset: (page - 1) * limit,
+	}
+
+	for name, target := range map[string]*uint{"product_id": &filter.ProductID, "user_id": &filter.UserID} {
+		if raw := c.Query(name); raw != "" {
+			id, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a positive integer"})
+				return
+			}
+			*target = uint(id)
+		}
+	}
+	if raw := c.Query("hidden"); raw != "" {
+		hidden, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "hidden must be true or false"})
+			return
+		}
+		filter.Hidden = &hidden
+	}
+
+	reviews, total, err := h.service.ListAllReviews(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+		return
+	}
+
+	c.JSON(http.StatusOK, paginated(c, reviews, total, page, limit))
+}
+
+// HideReview takes a review out of listings and ratings, with an optional
+// reason shown to moderators.
+func (h *ReviewHandler) HideReview(c *gin.Context) {
+	reviewID, ok := reviewIDParam(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Reason string `json:"reason" binding:"max=255"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	review, err := h.service.HideReview(reviewID, input.Reason)
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+func (h *ReviewHandler) UnhideReview(c *gin.Context) {
+	reviewID, ok := reviewIDParam(c)
+	if !ok {
+		return
+	}
+
+	review, err := h.service.UnhideReview(reviewID)
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+func reviewIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("review_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondReviewError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, service.ErrReviewNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+	case errors.Is(err, service.ErrInvalidRating):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNotPurchased), errors.Is(err, service.ErrNotReviewAuthor):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAlreadyReviewed), errors.Is(err, service.ErrReviewNotVisible), errors.Is(err, service.ErrReviewNotHidden):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
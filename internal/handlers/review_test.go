@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"game-store-api/internal/models"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductReviews(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	product := models.Product{Name: "Hollow Knight", Price: 1500, Stock: 10, SKU: "HK-1"}
+	deps.DB.Create(&product)
+	alice := models.User{Email: "alice@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	bob := models.User{Email: "bob@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	carol := models.User{Email: "carol@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&alice)
+	deps.DB.Create(&bob)
+	deps.DB.Create(&carol)
+	aliceToken := GenerateTestToken(alice.ID, "user")
+	bobToken := GenerateTestToken(bob.ID, "user")
+	reviewsPath := fmt.Sprintf("/api/v1/products/%d/reviews", product.ID)
+
+	// Only customers who paid for the product can review it
+	w := authorizedRequest(r, "POST", reviewsPath, aliceToken, `{"rating":5,"comment":"Great"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+	placePaidOrder(t, deps, r, alice, product)
+	placePaidOrder(t, deps, r, bob, product)
+	order := placePaidOrder(t, deps, r, carol, product)
+	// A refunded order doesn't count
+	deps.DB.Model(&order).Update("status", models.OrderStatusRefunded)
+	w = authorizedRequest(r, "POST", reviewsPath, GenerateTestToken(carol.ID, "user"), `{"rating":1}`)
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, authorizedRequest(r, "POST", reviewsPath, aliceToken, `{"rating":6}`).Code)
+	assert.Equal(t, http.StatusBadRequest, authorizedRequest(r, "POST", reviewsPath, aliceToken, `{"rating":0}`).Code)
+	assert.Equal(t, http.StatusNotFound, authorizedRequest(r, "POST", "/api/v1/products/999/reviews", aliceToken, `{"rating":5}`).Code)
+
+	w = authorizedRequest(r, "POST", reviewsPath, aliceToken, `{"rating":5,"comment":"Great"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var aliceReview models.Review
+	json.Unmarshal(w.Body.Bytes(), &aliceReview)
+	assert.Equal(t, 5, aliceReview.Rating)
+	assert.Equal(t, alice.ID, aliceReview.UserID)
+
+	// One review per customer
+	assert.Equal(t, http.StatusConflict, authorizedRequest(r, "POST", reviewsPath, aliceToken, `{"rating":4}`).Code)
+
+	w = authorizedRequest(r, "POST", reviewsPath, bobToken, `{"rating":2,"comment":"Too hard"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var bobReview models.Review
+	json.Unmarshal(w.Body.Bytes(), &bobReview)
+
+	getProduct := func() models.Product {
+		var fetched models.Product
+		w := authorizedRequest(r, "GET", fmt.Sprintf("/api/v1/products/%d", product.ID), "", "")
+		json.Unmarshal(w.Body.Bytes(), &fetched)
+		return fetched
+	}
+	fetched := getProduct()
+	assert.Equal(t, 3.5, fetched.RatingAverage)
+	assert.Equal(t, 2, fetched.ReviewCount)
+
+	// Authors edit and delete only their own reviews
+	bobPath := fmt.Sprintf("%s/%d", reviewsPath, bobReview.ID)
+	assert.Equal(t, http.StatusForbidden, authorizedRequest(r, "PUT", bobPath, aliceToken, `{"rating":1}`).Code)
+	assert.Equal(t, http.StatusForbidden, authorizedRequest(r, "DELETE", bobPath, aliceToken, "").Code)
+	w = authorizedRequest(r, "PUT", bobPath, bobToken, `{"rating":3,"comment":"Hard but fair"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 4.0, getProduct().RatingAverage)
+
+	w = authorizedRequest(r, "GET", reviewsPath, "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var page struct {
+		Data  []models.Review `json:"data"`
+		Total int64           `json:"total"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Equal(t, int64(2), page.Total)
+	require.Len(t, page.Data, 2)
+	assert.Equal(t, "Hard but fair", page.Data[0].Comment)
+
+	w = authorizedRequest(r, "DELETE", bobPath, bobToken, "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	fetched = getProduct()
+	assert.Equal(t, 5.0, fetched.RatingAverage)
+	assert.Equal(t, 1, fetched.ReviewCount)
+	assert.Equal(t, http.StatusNotFound, authorizedRequest(r, "DELETE", bobPath, bobToken, "").Code)
+
+	// After deleting it, Bob may review again
+	w = authorizedRequest(r, "POST", reviewsPath, bobToken, `{"rating":4}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, 4.5, getProduct().RatingAverage)
+}
+
+func TestAdminModeratesReviews(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+	adminToken := GenerateTestToken(99, "admin")
+
+	product := models.Product{Name: "Hollow Knight", Price: 1500, Stock: 10, SKU: "HK-1"}
+	deps.DB.Create(&product)
+	alice := models.User{Email: "alice@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	bob := models.User{Email: "bob@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&alice)
+	deps.DB.Create(&bob)
+	placePaidOrder(t, deps, r, alice, product)
+	placePaidOrder(t, deps, r, bob, product)
+	reviewsPath := fmt.Sprintf("/api/v1/products/%d/reviews", product.ID)
+
+	authorizedRequest(r, "POST", reviewsPath, GenerateTestToken(alice.ID, "user"), `{"rating":5,"comment":"Great"}`)
+	w := authorizedRequest(r, "POST", reviewsPath, GenerateTestToken(bob.ID, "user"), `{"rating":1,"comment":"spam spam spam"}`)
+	var spam models.Review
+	json.Unmarshal(w.Body.Bytes(), &spam)
+
+	hidePath := fmt.Sprintf("/api/v1/admin/reviews/%d/hide", spam.ID)
+	assert.Equal(t, http.StatusForbidden, authorizedRequest(r, "POST", hidePath, GenerateTestToken(alice.ID, "user"), "").Code)
+	w = authorizedRequest(r, "POST", hidePath, adminToken, `{"reason":"spam"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var hidden models.Review
+	json.Unmarshal(w.Body.Bytes(), &hidden)
+	assert.NotNil(t, hidden.HiddenAt)
+	assert.Equal(t, "spam", hidden.HiddenReason)
+	assert.Equal(t, http.StatusConflict, authorizedRequest(r, "POST", hidePath, adminToken, "").Code)
+
+	// Hidden reviews leave the listing and the rating
+	var page struct {
+		Data  []models.Review `json:"data"`
+		Total int64           `json:"total"`
+	}
+	w = authorizedRequest(r, "GET", reviewsPath, "", "")
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Equal(t, int64(1), page.Total)
+	var stored models.Product
+	deps.DB.First(&stored, product.ID)
+	assert.Equal(t, 5.0, stored.RatingAverage)
+	assert.Equal(t, 1, stored.ReviewCount)
+
+	// Editing doesn't bring a hidden review back
+	w = authorizedRequest(r, "PUT", fmt.Sprintf("%s/%d", reviewsPath, spam.ID), GenerateTestToken(bob.ID, "user"), `{"rating":2}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	deps.DB.First(&stored, product.ID)
+	assert.Equal(t, 1, stored.ReviewCount)
+
+	w = authorizedRequest(r, "GET", "/api/v1/admin/reviews?hidden=true", adminToken, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &page)
+	require.Len(t, page.Data, 1)
+	assert.Equal(t, spam.ID, page.Data[0].ID)
+	assert.Equal(t, http.StatusBadRequest, authorizedRequest(r, "GET", "/api/v1/admin/reviews?hidden=maybe", adminToken, "").Code)
+
+	w = authorizedRequest(r, "POST", fmt.Sprintf("/api/v1/admin/reviews/%d/unhide", spam.ID), adminToken, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	deps.DB.First(&stored, product.ID)
+	assert.Equal(t, 3.5, stored.RatingAverage)
+	assert.Equal(t, 2, stored.ReviewCount)
+	assert.Equal(t, http.StatusNotFound, authorizedRequest(r, "POST", "/api/v1/admin/reviews/999/hide", adminToken, "").Code)
+
+	// Listings can be sorted by rating
+	other := models.Product{Name: "Celeste", Price: 1999, Stock: 10, SKU: "CEL-1", RatingAverage: 4.8, ReviewCount: 10}
+	deps.DB.Create(&other)
+	w = authorizedRequest(r, "GET", "/api/v1/products?sort=rating&order=desc", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var products struct {
+		Data []models.Product `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &products)
+	require.Len(t, products.Data, 2)
+	assert.Equal(t, other.ID, products.Data[0].ID)
+}
@@ -2,11 +2,15 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	pb "game-store-api/internal/grpc/payment"
+	"game-store-api/internal/jobs"
 	"game-store-api/internal/middleware"
 	"game-store-api/internal/models"
+	"game-store-api/internal/pricing"
 	"game-store-api/internal/repository"
 	"game-store-api/internal/service"
+	"game-store-api/internal/worker"
 	"os"
 	"time"
 
@@ -14,12 +18,36 @@ import (
 	"github.com/glebarez/sqlite"
 	"github.com/golang-jwt/jwt/v5"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"gorm.io/gorm"
 )
 
-type MockPaymentClient struct{}
+// MockPaymentClient simulates the payment service with an in-memory ledger.
+type MockPaymentClient struct {
+	Decline bool
+	// Unavailable makes refunds and voids fail as if the service was down.
+	Unavailable bool
+	Charges     []*pb.PaymentRequest
+	Refunds     []*pb.RefundRequest
+	Payments    map[string]*pb.PaymentDetails
+}
 
 func (m *MockPaymentClient) ProcessPayment(ctx context.Context, in *pb.PaymentRequest, opts ...grpc.CallOption) (*pb.PaymentResponse, error) {
+	m.Charges = append(m.Charges, in)
+	if m.Decline {
+		return &pb.PaymentResponse{
+			Success: false,
+			Message: "Declined via Mock",
+		}, nil
+	}
+	m.Payments["TEST_TXN_123"] = &pb.PaymentDetails{
+		TransactionId: "TEST_TXN_123",
+		OrderId:       in.OrderId,
+		Status:        pb.PaymentStatus_PAYMENT_STATUS_CAPTURED,
+		Amount:        in.Amount,
+		Refunded:      &pb.Money{Currency: in.Amount.GetCurrency()},
+	}
 	return &pb.PaymentResponse{
 		Success:       true,
 		TransactionId: "TEST_TXN_123",
@@ -27,41 +55,231 @@ func (m *MockPaymentClient) ProcessPayment(ctx context.Context, in *pb.PaymentRe
 	}, nil
 }
 
+func (m *MockPaymentClient) RefundPayment(ctx context.Context, in *pb.RefundRequest, opts ...grpc.CallOption) (*pb.RefundResponse, error) {
+	m.Refunds = append(m.Refunds, in)
+	if m.Unavailable {
+		return nil, status.Error(codes.Unavailable, "payment service unavailable")
+	}
+	payment, ok := m.Payments[in.TransactionId]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "transaction not found")
+	}
+
+	remaining := payment.Amount.AmountMinor - payment.Refunded.AmountMinor
+	amount := in.GetAmount().GetAmountMinor()
+	if amount == 0 {
+		amount = remaining
+	}
+	if payment.Status == pb.PaymentStatus_PAYMENT_STATUS_VOIDED || amount > remaining {
+		return &pb.RefundResponse{Success: false, Message: "Refund rejected via Mock", Status: payment.Status}, nil
+	}
+
+	payment.Refunded.AmountMinor += amount
+	payment.Status = pb.PaymentStatus_PAYMENT_STATUS_PARTIALLY_REFUNDED
+	if payment.Refunded.AmountMinor == payment.Amount.AmountMinor {
+		payment.Status = pb.PaymentStatus_PAYMENT_STATUS_REFUNDED
+	}
+	return &pb.RefundResponse{
+		Success:  true,
+		RefundId: "TEST_RFD_123",
+		Message:  "Refund processed via Mock",
+		Status:   payment.Status,
+		Refunded: &pb.Money{AmountMinor: amount, Currency: payment.Amount.Currency},
+	}, nil
+}
+
+func (m *MockPaymentClient) CancelPayment(ctx context.Context, in *pb.CancelRequest, opts ...grpc.CallOption) (*pb.CancelResponse, error) {
+	if m.Unavailable {
+		return nil, status.Error(codes.Unavailable, "payment service unavailable")
+	}
+	payment, ok := m.Payments[in.TransactionId]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "transaction not found")
+	}
+	if payment.Status != pb.PaymentStatus_PAYMENT_STATUS_CAPTURED {
+		return &pb.CancelResponse{Success: false, Message: "Void rejected via Mock", Status: payment.Status}, nil
+	}
+
+	payment.Status = pb.PaymentStatus_PAYMENT_STATUS_VOIDED
+	return &pb.CancelResponse{Success: true, Message: "Payment voided via Mock", Status: payment.Status}, nil
+}
+
+func (m *MockPaymentClient) GetPayment(ctx context.Context, in *pb.GetPaymentRequest, opts ...grpc.CallOption) (*pb.PaymentDetails, error) {
+	payment, ok := m.Payments[in.TransactionId]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "transaction not found")
+	}
+	return payment, nil
+}
+
+// verifiedAt marks test users whose email is verified, which checkout requires.
+var verifiedAt = time.Now()
+
+// FakeEmailQueue records email tasks instead of pushing them to Redis.
+type FakeEmailQueue struct {
+	Tasks []map[string]string
+	// Err, when set, fails every Enqueue
+	Err error
+}
+
+func (q *FakeEmailQueue) Enqueue(task map[string]string) error {
+	if q.Err != nil {
+		return q.Err
+	}
+	q.Tasks = append(q.Tasks, task)
+	return nil
+}
+
+// Last returns the most recent task of the given type, or nil.
+func (q *FakeEmailQueue) Last(taskType string) map[string]string {
+	for i := len(q.Tasks) - 1; i >= 0; i-- {
+		if q.Tasks[i]["type"] == taskType {
+			return q.Tasks[i]
+		}
+	}
+	return nil
+}
+
+// FakeStorage keeps stored files in memory.
+type FakeStorage struct {
+	Files map[string][]byte
+}
+
+func (s *FakeStorage) Put(ctx context.Context, key, contentType string, data []byte) error {
+	s.Files[key] = data
+	return nil
+}
+
+func (s *FakeStorage) Delete(ctx context.Context, key string) error {
+	delete(s.Files, key)
+	return nil
+}
+
+func (s *FakeStorage) URL(key string) string {
+	return "/media/" + key
+}
+
 type TestDeps struct {
-	DB             *gorm.DB
-	AuthHandler    *AuthHandler
-	ProductHandler *ProductHandler
-	OrderHandler   *OrderHandler
-	CartHandler    *CartHandler
+	DB              *gorm.DB
+	Payment         *MockPaymentClient
+	AuthService     *service.AuthService
+	Emails          *FakeEmailQueue
+	Storage         *FakeStorage
+	Wishlists       *service.WishlistService
+	Orders          *service.OrderService
+	AuthHandler     *AuthHandler
+	ProductHandler  *ProductHandler
+	OrderHandler    *OrderHandler
+	CartHandler     *CartHandler
+	PaymentHandler  *PaymentHandler
+	EmailJobs       *jobs.Queue
+	EventJobs       *jobs.Queue
+	Relay           *worker.OutboxRelay
+	JobHandler      *JobHandler
+	CouponHandler   *CouponHandler
+	ReviewHandler   *ReviewHandler
+	WishlistHandler *WishlistHandler
+	// Handlers of the product taxonomies
+	CategoryHandler  *TaxonomyHandler
+	PlatformHandler  *TaxonomyHandler
+	PublisherHandler *TaxonomyHandler
+}
+
+// DeliverEvents publishes pending outbox events and handles them, as the
+// relay and event workers would in the background.
+func (d TestDeps) DeliverEvents() {
+	ctx := context.Background()
+	if _, err := d.Relay.PublishPending(ctx); err != nil {
+		panic("Failed to publish outbox events: " + err.Error())
+	}
+
+	handlers := worker.EventHandlers(d.Emails, d.AuthService, d.Wishlists, d.Orders)
+	for {
+		ran, err := d.EventJobs.RunOnce(ctx, handlers)
+		if err != nil {
+			panic("Failed to handle events: " + err.Error())
+		}
+		if !ran {
+			return
+		}
+	}
 }
 
 func SetupTestDependencies() TestDeps {
+	return SetupTestDependenciesWithTax(0)
+}
+
+// SetupTestDependenciesWithTax is SetupTestDependencies with carts and
+// orders taxed at taxRate.
+func SetupTestDependenciesWithTax(taxRate float64) TestDeps {
 	os.Setenv("JWT_SECRET", "test_secret_key")
 
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true})
 	if err != nil {
 		panic("Failed to migrate test database: " + err.Error())
 	}
-	db.AutoMigrate(&models.Product{}, &models.User{}, &models.Order{}, &models.CartItem{}, &models.OrderItem{})
+	db.AutoMigrate(&models.Product{}, &models.User{}, &models.Order{}, &models.CartItem{}, &models.OrderItem{}, &models.IdempotencyKey{}, &models.Payment{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.ActionToken{}, &models.OutboxEvent{}, &models.OrderStatusHistory{}, &models.GameKey{}, &models.Coupon{}, &models.CouponRedemption{}, &models.CartCoupon{}, &models.StockReservation{}, &models.Category{}, &models.Platform{}, &models.Publisher{}, &models.ProductImage{}, &models.Review{}, &models.WishlistItem{}, &models.WishlistAlert{})
 
 	userRepo := repository.NewUserRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	actionTokenRepo := repository.NewActionTokenRepository(db)
+	tokenDenylist := repository.NewTokenDenylist(db, nil)
 	productRepo := repository.NewProductRepository(db)
 	orderRepo := repository.NewOrderRepository(db)
 	cartRepo := repository.NewCartRepository(db)
+	paymentRepo := repository.NewPaymentRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db, nil, 24*time.Hour, 5*time.Minute)
+	outboxRepo := repository.NewOutboxRepository(db)
+	keyRepo := repository.NewGameKeyRepository(db)
+	couponRepo := repository.NewCouponRepository(db)
+	reservationRepo := repository.NewReservationRepository(db)
+	taxonomyRepo := repository.NewTaxonomyRepository(db)
+	imageRepo := repository.NewProductImageRepository(db)
+	reviewRepo := repository.NewReviewRepository(db)
+	wishlistRepo := repository.NewWishlistRepository(db)
 
-	mockPayment := &MockPaymentClient{}
+	rates := pricing.NewExchangeRates("USD", map[string]float64{"EUR": 0.9, "GBP": 0.8, "JPY": 150})
+	mockPayment := &MockPaymentClient{Payments: map[string]*pb.PaymentDetails{}}
+	emails := &FakeEmailQueue{}
+	store := &FakeStorage{Files: map[string][]byte{}}
+	emailJobs := jobs.NewQueue("email", jobs.NewMemoryStore(), jobs.Options{Wait: time.Millisecond})
+	eventJobs := jobs.NewQueue("events", jobs.NewMemoryStore(), jobs.Options{Wait: time.Millisecond})
 
-	authService := service.NewAuthService(userRepo, nil)
-	productService := service.NewProductService(productRepo)
-	cartService := service.NewCartService(cartRepo, productRepo)
-	orderService := service.NewOrderService(orderRepo, productRepo, cartRepo, mockPayment, db)
+	authService := service.NewAuthService(userRepo, refreshTokenRepo, actionTokenRepo, outboxRepo, tokenDenylist, emails, db)
+	productService := service.NewProductService(productRepo, outboxRepo, keyRepo, reservationRepo, taxonomyRepo, imageRepo, store, db, rates)
+	cartService := service.NewCartService(cartRepo, productRepo, couponRepo, reservationRepo, db, rates, taxRate, 15*time.Minute)
+	orderService := service.NewOrderService(orderRepo, userRepo, productRepo, cartRepo, paymentRepo, outboxRepo, keyRepo, couponRepo, reservationRepo, mockPayment, db, rates, taxRate)
+	idempotencyService := service.NewIdempotencyService(idempotencyRepo)
+	paymentService := service.NewPaymentService(paymentRepo)
+	couponService := service.NewCouponService(couponRepo, productRepo, taxonomyRepo, db)
+	taxonomyService := service.NewTaxonomyService(taxonomyRepo, db)
+	reviewService := service.NewReviewService(reviewRepo, productRepo, db)
+	wishlistService := service.NewWishlistService(wishlistRepo, productRepo, reservationRepo, emails, rates)
 
 	return TestDeps{
-		DB:             db,
-		AuthHandler:    NewAuthHandler(authService),
-		ProductHandler: NewProductHandler(productService),
-		CartHandler:    NewCartHandler(cartService),
-		OrderHandler:   NewOrderHandler(orderService),
+		DB:              db,
+		Payment:         mockPayment,
+		AuthService:     authService,
+		Emails:          emails,
+		Storage:         store,
+		Wishlists:       wishlistService,
+		Orders:          orderService,
+		AuthHandler:     NewAuthHandler(authService),
+		ProductHandler:  NewProductHandler(productService),
+		CartHandler:     NewCartHandler(cartService),
+		OrderHandler:    NewOrderHandler(orderService, idempotencyService),
+		PaymentHandler:  NewPaymentHandler(paymentService),
+		EmailJobs:       emailJobs,
+		EventJobs:       eventJobs,
+		Relay:           worker.NewOutboxRelay(outboxRepo, eventJobs),
+		JobHandler:      NewJobHandler(map[string]*jobs.Queue{"email": emailJobs, "events": eventJobs}),
+		CouponHandler:   NewCouponHandler(couponService),
+		ReviewHandler:   NewReviewHandler(reviewService),
+		WishlistHandler: NewWishlistHandler(wishlistService),
+
+		CategoryHandler:  NewTaxonomyHandler(taxonomyService, repository.Categories),
+		PlatformHandler:  NewTaxonomyHandler(taxonomyService, repository.Platforms),
+		PublisherHandler: NewTaxonomyHandler(taxonomyService, repository.Publishers),
 	}
 }
 
@@ -71,18 +289,86 @@ func SetupRouter(deps TestDeps) *gin.Engine {
 	{
 		v1.POST("/auth/register", deps.AuthHandler.Register)
 		v1.POST("/auth/login", deps.AuthHandler.Login)
+		v1.POST("/auth/refresh", deps.AuthHandler.Refresh)
+		v1.POST("/auth/verify-email", deps.AuthHandler.VerifyEmail)
+		v1.POST("/auth/resend-verification", deps.AuthHandler.ResendVerification)
+		v1.POST("/auth/forgot-password", deps.AuthHandler.ForgotPassword)
+		v1.POST("/auth/reset-password", deps.AuthHandler.ResetPassword)
 		v1.GET("/products", deps.ProductHandler.GetProducts)
+		v1.GET("/products/:product_id", deps.ProductHandler.GetProduct)
+		v1.GET("/products/:product_id/reviews", deps.ReviewHandler.ListReviews)
+		v1.GET("/categories", deps.CategoryHandler.ListTerms)
+		v1.GET("/platforms", deps.PlatformHandler.ListTerms)
+		v1.GET("/publishers", deps.PublisherHandler.ListTerms)
 
 		protected := v1.Group("/")
-		protected.Use(middleware.AuthMiddleware())
+		protected.Use(middleware.AuthMiddleware(deps.AuthService))
 		{
+			protected.POST("/auth/logout", deps.AuthHandler.Logout)
+			protected.POST("/auth/logout-all", deps.AuthHandler.LogoutAll)
+
 			protected.POST("/products", middleware.AdminOnly(), deps.ProductHandler.CreateProduct)
+			protected.PUT("/products/:product_id", middleware.AdminOnly(), deps.ProductHandler.UpdateProduct)
+			protected.PATCH("/products/:product_id", middleware.AdminOnly(), deps.ProductHandler.PatchProduct)
+			protected.DELETE("/products/:product_id", middleware.AdminOnly(), deps.ProductHandler.DeleteProduct)
+			protected.POST("/products/:product_id/restore", middleware.AdminOnly(), deps.ProductHandler.RestoreProduct)
+			protected.POST("/products/:product_id/keys", middleware.AdminOnly(), deps.ProductHandler.UploadKeys)
+			protected.POST("/products/:product_id/images", middleware.AdminOnly(), deps.ProductHandler.UploadImage)
+			protected.DELETE("/products/:product_id/images/:image_id", middleware.AdminOnly(), deps.ProductHandler.DeleteImage)
+
+			protected.POST("/products/:product_id/reviews", deps.ReviewHandler.CreateReview)
+			protected.PUT("/products/:product_id/reviews/:review_id", deps.ReviewHandler.UpdateReview)
+			protected.DELETE("/products/:product_id/reviews/:review_id", deps.ReviewHandler.DeleteReview)
+
+			for path, taxonomyHandler := range map[string]*TaxonomyHandler{
+				"/categories": deps.CategoryHandler,
+				"/platforms":  deps.PlatformHandler,
+				"/publishers": deps.PublisherHandler,
+			} {
+				protected.POST(path, middleware.AdminOnly(), taxonomyHandler.CreateTerm)
+				protected.PUT(path+"/:term_id", middleware.AdminOnly(), taxonomyHandler.UpdateTerm)
+				protected.DELETE(path+"/:term_id", middleware.AdminOnly(), taxonomyHandler.DeleteTerm)
+			}
 
 			protected.GET("/cart", deps.CartHandler.GetCart)
 			protected.POST("/cart", deps.CartHandler.AddToCart)
 			protected.DELETE("/cart/:product_id", deps.CartHandler.RemoveFromCart)
+			protected.POST("/cart/coupon", deps.CartHandler.ApplyCoupon)
+			protected.DELETE("/cart/coupon", deps.CartHandler.RemoveCoupon)
+
+			protected.GET("/wishlist", deps.WishlistHandler.GetWishlist)
+			protected.POST("/wishlist", deps.WishlistHandler.AddToWishlist)
+			protected.DELETE("/wishlist/:product_id", deps.WishlistHandler.RemoveFromWishlist)
 
 			protected.POST("/cart/checkout", deps.OrderHandler.Checkout)
+
+			protected.GET("/orders", deps.OrderHandler.GetOrders)
+			protected.GET("/orders/:order_id", deps.OrderHandler.GetOrder)
+			protected.POST("/orders/:order_id/cancel", deps.OrderHandler.CancelOrder)
+
+			admin := protected.Group("/admin")
+			admin.Use(middleware.AdminOnly())
+			{
+				admin.GET("/orders", deps.OrderHandler.ListAllOrders)
+				admin.GET("/orders/:order_id", deps.OrderHandler.GetOrderDetails)
+				admin.POST("/orders/:order_id/status", deps.OrderHandler.UpdateOrderStatus)
+				admin.GET("/orders/:order_id/payment", deps.OrderHandler.GetOrderPayment)
+				admin.POST("/orders/:order_id/refund", deps.OrderHandler.RefundOrder)
+				admin.POST("/orders/:order_id/void", deps.OrderHandler.VoidOrder)
+
+				admin.GET("/payments/:transaction_id", deps.PaymentHandler.GetPayment)
+
+				admin.GET("/queues/:queue/dead", deps.JobHandler.GetDeadJobs)
+				admin.POST("/queues/:queue/dead/:job_id/requeue", deps.JobHandler.RequeueDeadJob)
+
+				admin.GET("/coupons", deps.CouponHandler.ListCoupons)
+				admin.POST("/coupons", deps.CouponHandler.CreateCoupon)
+				admin.DELETE("/coupons/:coupon_id", deps.CouponHandler.DeleteCoupon)
+
+				admin.GET("/reviews", deps.ReviewHandler.ListAllReviews)
+				admin.POST("/reviews/:review_id/hide", deps.ReviewHandler.HideReview)
+				admin.POST("/reviews/:review_id/unhide", deps.ReviewHandler.UnhideReview)
+			}
 		}
 	}
 	return r
@@ -92,6 +378,8 @@ func GenerateTestToken(userID uint, role string) string {
 	claims := jwt.MapClaims{
 		"sub":  float64(userID),
 		"role": role,
+		"jti":  fmt.Sprintf("test-%d-%d", userID, time.Now().UnixNano()),
+		"iat":  time.Now().Unix(),
 		"exp":  time.Now().Add(time.Hour).Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
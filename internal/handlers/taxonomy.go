@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"game-store-api/internal/repository"
+	"game-store-api/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TaxonomyHandler serves the terms of one taxonomy, such as the categories.
+type TaxonomyHandler struct {
+	service  *service.TaxonomyService
+	taxonomy repository.Taxonomy
+}
+
+func NewTaxonomyHandler(s *service.TaxonomyService, taxonomy repository.Taxonomy) *TaxonomyHandler {
+	return &TaxonomyHandler{service: s, taxonomy: taxonomy}
+}
+
+// termInput names a term. Without a slug one is made from the name.
+type termInput struct {
+	Name string `json:"name" binding:"required,max=100"`
+	Slug string `json:"slug" binding:"max=100"`
+}
+
+func (h *TaxonomyHandler) ListTerms(c *gin.Context) {
+	terms, err := h.service.ListTerms(h.taxonomy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch " + string(h.taxonomy)})
+		return
+	}
+	c.JSON(http.StatusOK, terms)
+}
+
+func (h *TaxonomyHandler) CreateTerm(c *gin.Context) {
+	var input termInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	term, err := h.service.CreateTerm(h.taxonomy, input.Name, input.Slug)
+	if err != nil {
+		respondTermError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, term)
+}
+
+func (h *TaxonomyHandler) UpdateTerm(c *gin.Context) {
+	id, ok := termIDParam(c)
+	if !ok {
+		return
+	}
+	var input termInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	term, err := h.service.UpdateTerm(h.taxonomy, id, input.Name, input.Slug)
+	if err != nil {
+		respondTermError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, term)
+}
+
+// DeleteTerm deletes a term. Products and coupons that had it lose it.
+func (h *TaxonomyHandler) DeleteTerm(c *gin.Context) {
+	id, ok := termIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteTerm(h.taxonomy, id); err != nil {
+		respondTermError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+}
+
+func termIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("term_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondTermError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTermNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	case errors.Is(err, service.ErrInvalidSlug):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrDuplicateTerm):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"game-store-api/internal/models"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminManagesTaxonomies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+	adminToken := GenerateTestToken(99, "admin")
+
+	w := authorizedRequest(r, "POST", "/api/v1/categories", adminToken, `{"name":"Role-Playing (RPG)"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var rpg models.Term
+	json.Unmarshal(w.Body.Bytes(), &rpg)
+	assert.Equal(t, "role-playing-rpg", rpg.Slug)
+
+	assert.Equal(t, http.StatusForbidden, authorizedRequest(r, "POST", "/api/v1/categories", GenerateTestToken(1, "user"), `{"name":"Puzzle"}`).Code)
+	assert.Equal(t, http.StatusConflict, authorizedRequest(r, "POST", "/api/v1/categories", adminToken, `{"name":"RPG","slug":"role-playing-rpg"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, authorizedRequest(r, "POST", "/api/v1/categories", adminToken, `{"name":"Puzzle","slug":"Puzzle Games"}`).Code)
+	// Slugs are unique per taxonomy only
+	assert.Equal(t, http.StatusCreated, authorizedRequest(r, "POST", "/api/v1/publishers", adminToken, `{"name":"RPG Inc.","slug":"role-playing-rpg"}`).Code)
+	require.Equal(t, http.StatusCreated, authorizedRequest(r, "POST", "/api/v1/categories", adminToken, `{"name":"Action"}`).Code)
+
+	path := fmt.Sprintf("/api/v1/categories/%d", rpg.ID)
+	w = authorizedRequest(r, "PUT", path, adminToken, `{"name":"RPG","slug":"rpg"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, authorizedRequest(r, "PUT", "/api/v1/categories/999", adminToken, `{"name":"Ghost"}`).Code)
+	assert.Equal(t, http.StatusConflict, authorizedRequest(r, "PUT", path, adminToken, `{"name":"Action"}`).Code)
+
+	// Anyone can list the terms, sorted by name
+	w = authorizedRequest(r, "GET", "/api/v1/categories", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var categories []models.Term
+	json.Unmarshal(w.Body.Bytes(), &categories)
+	require.Len(t, categories, 2)
+	assert.Equal(t, "action", categories[0].Slug)
+	assert.Equal(t, "rpg", categories[1].Slug)
+
+	assert.Equal(t, http.StatusOK, authorizedRequest(r, "DELETE", path, adminToken, "").Code)
+	assert.Equal(t, http.StatusNotFound, authorizedRequest(r, "DELETE", path, adminToken, "").Code)
+}
+
+func TestProductTaxonomies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+	adminToken := GenerateTestToken(99, "admin")
+
+	rpg := models.Category{Term: models.Term{Name: "RPG", Slug: "rpg"}}
+	action := models.Category{Term: models.Term{Name: "Action", Slug: "action"}}
+	switchPlatform := models.Platform{Term: models.Term{Name: "Switch", Slug: "switch"}}
+	ps5 := models.Platform{Term: models.Term{Name: "PS5", Slug: "ps5"}}
+	nintendo := models.Publisher{Term: models.Term{Name: "Nintendo", Slug: "nintendo"}}
+	for _, term := range []any{&rpg, &action, &switchPlatform, &ps5, &nintendo} {
+		require.NoError(t, deps.DB.Create(term).Error)
+	}
+
+	body := fmt.Sprintf(`{"name":"Zelda","price":6000,"sku":"ZEL-1","stock":5,"developer":"Nintendo EPD",
+		"release_date":"2023-05-12","age_rating":"PEGI 12",
+		"category_ids":[%d,%d],"platform_ids":[%d],"publisher_ids":[%d]}`,
+		rpg.ID, action.ID, switchPlatform.ID, nintendo.ID)
+	w := authorizedRequest(r, "POST", "/api/v1/products", adminToken, body)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var zelda models.Product
+	json.Unmarshal(w.Body.Bytes(), &zelda)
+	assert.Equal(t, "Nintendo EPD", zelda.Developer)
+	assert.Equal(t, "PEGI 12", zelda.AgeRating)
+	if assert.NotNil(t, zelda.ReleaseDate) {
+		assert.Equal(t, "2023-05-12", zelda.ReleaseDate.Format("2006-01-02"))
+	}
+	assert.Len(t, zelda.Categories, 2)
+	require.Len(t, zelda.Platforms, 1)
+	assert.Equal(t, "switch", zelda.Platforms[0].Slug)
+	require.Len(t, zelda.Publishers, 1)
+	assert.Equal(t, "Nintendo", zelda.Publishers[0].Name)
+
+	w = authorizedRequest(r, "POST", "/api/v1/products", adminToken, fmt.Sprintf(`{"name":"Elden Ring","price":6000,"sku":"ELD-1","stock":5,"category_ids":[%d],"platform_ids":[%d]}`, rpg.ID, ps5.ID))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = authorizedRequest(r, "POST", "/api/v1/products", adminToken, `{"name":"Ghost","price":6000,"sku":"GHO-1","category_ids":[999]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "categories has no id 999")
+	assert.Equal(t, http.StatusBadRequest, authorizedRequest(r, "POST", "/api/v1/products", adminToken, `{"name":"Ghost","price":6000,"sku":"GHO-1","release_date":"12/05/2023"}`).Code)
+
+	names := func(query string) []string {
+		w := authorizedRequest(r, "GET", "/api/v1/products?sort=name&order=asc&"+query, "", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var page struct {
+			Data []models.Product `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &page)
+		var names []string
+		for _, product := range page.Data {
+			names = append(names, product.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"Elden Ring", "Zelda"}, names("category=rpg"))
+	assert.Equal(t, []string{"Zelda"}, names("category=action"))
+	assert.Equal(t, []string{"Elden Ring", "Zelda"}, names("platform=PS5,switch"))
+	assert.Equal(t, []string{"Elden Ring"}, names("category=rpg&platform=ps5"))
+	assert.Equal(t, []string{"Zelda"}, names("publisher=nintendo"))
+	assert.Empty(t, names("category=action&publisher=sony"))
+
+	// PATCH only replaces the taxonomies it names
+	path := fmt.Sprintf("/api/v1/products/%d", zelda.ID)
+	w = authorizedRequest(r, "PATCH", path, adminToken, fmt.Sprintf(`{"platform_ids":[%d,%d],"release_date":""}`, switchPlatform.ID, ps5.ID))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	zelda = models.Product{}
+	json.Unmarshal(w.Body.Bytes(), &zelda)
+	assert.Len(t, zelda.Platforms, 2)
+	assert.Len(t, zelda.Categories, 2)
+	assert.Nil(t, zelda.ReleaseDate)
+
+	// Deleting a term takes it off its products
+	require.Equal(t, http.StatusOK, authorizedRequest(r, "DELETE", fmt.Sprintf("/api/v1/categories/%d", rpg.ID), adminToken, "").Code)
+	assert.Empty(t, names("category=rpg"))
+	w = authorizedRequest(r, "GET", path, "", "")
+	zelda = models.Product{}
+	json.Unmarshal(w.Body.Bytes(), &zelda)
+	require.Len(t, zelda.Categories, 1)
+	assert.Equal(t, "action", zelda.Categories[0].Slug)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"game-store-api/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WishlistHandler struct {
+	service *service.WishlistService
+}
+
+func NewWishlistHandler(s *service.WishlistService) *WishlistHandler {
+	return &WishlistHandler{service: s}
+}
+
+// GetWishlist pages through the caller's wishlist, newest first, with the
+// products priced in the requested currency (see requestedCurrency).
+func (h *WishlistHandler) GetWishlist(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	page, limit := parsePagination(c)
+
+	items, total, err := h.service.ListWishlist(userID, requestedCurrency(c), page, limit)
+	if err != nil {
+		respondWishlistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, paginated(c, items, total, page, limit))
+}
+
+// AddToWishlist puts a product on the caller's wishlist. Adding a product
+// that is already there succeeds without changing anything.
+func (h *WishlistHandler) AddToWishlist(c *gin.Context) {
+	var input struct {
+		ProductID uint `json:"product_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	if err := h.service.AddToWishlist(userID, input.ProductID); err != nil {
+		respondWishlistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Added to wishlist"})
+}
+
+func (h *WishlistHandler) RemoveFromWishlist(c *gin.Context) {
+	productID, ok := productIDParam(c)
+	if !ok {
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	if err := h.service.RemoveFromWishlist(userID, productID); err != nil {
+		respondWishlistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Removed from wishlist"})
+}
+
+func respondWishlistError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUnsupportedCurrency):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, service.ErrNotInWishlist):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
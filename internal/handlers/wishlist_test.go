@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"game-store-api/internal/models"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWishlist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+
+	hl3 := models.Product{Name: "Half-Life 3", Price: 5999, Stock: 0, SKU: "HL3-1"}
+	zelda := models.Product{Name: "Zelda", Price: 6000, Stock: 10, SKU: "ZEL-1"}
+	deps.DB.Create(&hl3)
+	deps.DB.Create(&zelda)
+	user := models.User{Email: "gamer@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&user)
+	token := GenerateTestToken(user.ID, "user")
+
+	assert.Equal(t, http.StatusUnauthorized, authorizedRequest(r, "GET", "/api/v1/wishlist", "", "").Code)
+	assert.Equal(t, http.StatusBadRequest, authorizedRequest(r, "POST", "/api/v1/wishlist", token, `{}`).Code)
+	assert.Equal(t, http.StatusNotFound, authorizedRequest(r, "POST", "/api/v1/wishlist", token, `{"product_id":999}`).Code)
+
+	body := fmt.Sprintf(`{"product_id":%d}`, hl3.ID)
+	require.Equal(t, http.StatusCreated, authorizedRequest(r, "POST", "/api/v1/wishlist", token, body).Code)
+	// Adding twice keeps a single entry
+	assert.Equal(t, http.StatusCreated, authorizedRequest(r, "POST", "/api/v1/wishlist", token, body).Code)
+	w := authorizedRequest(r, "POST", "/api/v1/wishlist", token, fmt.Sprintf(`{"product_id":%d}`, zelda.ID))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// Other users have their own wishlists
+	w = authorizedRequest(r, "GET", "/api/v1/wishlist", GenerateTestToken(user.ID+1, "user"), "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var page struct {
+		Data  []models.WishlistItem `json:"data"`
+		Total int64                 `json:"total"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Zero(t, page.Total)
+
+	w = authorizedRequest(r, "GET", "/api/v1/wishlist?currency=EUR", token, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Equal(t, int64(2), page.Total)
+	require.Len(t, page.Data, 2)
+	assert.Equal(t, "Half-Life 3", page.Data[1].Product.Name)
+	assert.Equal(t, "EUR", page.Data[1].Product.Currency)
+	assert.Equal(t, 10, page.Data[0].Product.Available)
+	assert.Equal(t, http.StatusBadRequest, authorizedRequest(r, "GET", "/api/v1/wishlist?currency=XYZ", token, "").Code)
+
+	w = authorizedRequest(r, "DELETE", fmt.Sprintf("/api/v1/wishlist/%d", zelda.ID), token, "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, authorizedRequest(r, "DELETE", fmt.Sprintf("/api/v1/wishlist/%d", zelda.ID), token, "").Code)
+
+	// Deleted products drop off the wishlist
+	deps.DB.Delete(&hl3)
+	w = authorizedRequest(r, "GET", "/api/v1/wishlist", token, "")
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Zero(t, page.Total)
+}
+
+func TestWishlistAlerts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := SetupTestDependencies()
+	r := SetupRouter(deps)
+	adminToken := GenerateTestToken(99, "admin")
+
+	hl3 := models.Product{Name: "Half-Life 3", Price: 5999, Stock: 0, SKU: "HL3-1"}
+	deps.DB.Create(&hl3)
+	alice := models.User{Email: "alice@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	bob := models.User{Email: "bob@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	carol := models.User{Email: "carol@test.com", Password: "hashed", Role: "user", EmailVerifiedAt: &verifiedAt}
+	deps.DB.Create(&alice)
+	deps.DB.Create(&bob)
+	deps.DB.Create(&carol)
+	body := fmt.Sprintf(`{"product_id":%d}`, hl3.ID)
+	authorizedRequest(r, "POST", "/api/v1/wishlist", GenerateTestToken(alice.ID, "user"), body)
+	authorizedRequest(r, "POST", "/api/v1/wishlist", GenerateTestToken(bob.ID, "user"), body)
+
+	productPath := fmt.Sprintf("/api/v1/products/%d", hl3.ID)
+	alerts := func(taskType string) []map[string]string {
+		var tasks []map[string]string
+		for _, task := range deps.Emails.Tasks {
+			if task["type"] == taskType {
+				tasks = append(tasks, task)
+			}
+		}
+		return tasks
+	}
+
+	// A restock that carts take up straight away is not news
+	shopper := GenerateTestToken(carol.ID, "user")
+	w := authorizedRequest(r, "PATCH", productPath, adminToken, `{"stock":2}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = authorizedRequest(r, "POST", "/api/v1/cart", shopper, fmt.Sprintf(`{"product_id":%d,"quantity":2}`, hl3.ID))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	deps.DeliverEvents()
+	assert.Empty(t, alerts("wishlist_back_in_stock"))
+	authorizedRequest(r, "DELETE", fmt.Sprintf("/api/v1/cart/%d", hl3.ID), shopper, "")
+	authorizedRequest(r, "PATCH", productPath, adminToken, `{"stock":0}`)
+
+	// Restocking a sold-out product emails everyone wishing for it
+	w = authorizedRequest(r, "PATCH", productPath, adminToken, `{"stock":5}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	deps.DeliverEvents()
+	restocked := alerts("wishlist_back_in_stock")
+	require.Len(t, restocked, 2)
+	assert.Equal(t, "alice@test.com", restocked[0]["email"])
+	assert.Equal(t, "bob@test.com", restocked[1]["email"])
+	assert.Equal(t, "Half-Life 3", restocked[0]["product_name"])
+	assert.Equal(t, "59.99", restocked[0]["price"])
+
+	// Handling the same event again emails nobody twice
+	events := outboxEvents(deps, models.EventStockChanged)
+	require.NotEmpty(t, events)
+	deps.DB.Model(&events[len(events)-1]).Update("published_at", nil)
+	deps.DeliverEvents()
+	assert.Len(t, alerts("wishlist_back_in_stock"), 2)
+
+	// Topping up stock that was never out is not news
+	authorizedRequest(r, "PATCH", productPath, adminToken, `{"stock":8}`)
+	deps.DeliverEvents()
+	assert.Len(t, alerts("wishlist_back_in_stock"), 2)
+
+	// Price rises aren't announced, drops are
+	authorizedRequest(r, "PATCH", productPath, adminToken, `{"price":6999}`)
+	deps.DeliverEvents()
+	assert.Empty(t, alerts("wishlist_price_drop"))
+	require.Len(t, outboxEvents(deps, models.EventPriceChanged), 1)
+
+	w = authorizedRequest(r, "PATCH", productPath, adminToken, `{"price":3999}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	deps.DeliverEvents()
+	dropped := alerts("wishlist_price_drop")
+	require.Len(t, dropped, 2)
+	assert.Equal(t, "39.99", dropped[0]["price"])
+	assert.Equal(t, "69.99", dropped[0]["previous_price"])
+	assert.Equal(t, fmt.Sprintf("%d", alice.ID), dropped[0]["user_id"])
+
+	// Carol joins late and only hears about later events
+	authorizedRequest(r, "POST", "/api/v1/wishlist", GenerateTestToken(carol.ID, "user"), body)
+	authorizedRequest(r, "PATCH", productPath, adminToken, `{"price":2999}`)
+	deps.DeliverEvents()
+	dropped = alerts("wishlist_price_drop")
+	require.Len(t, dropped, 5)
+	assert.Equal(t, "carol@test.com", dropped[4]["email"])
+
+	// An alert that couldn't be queued is sent when the event is retried
+	deps.Emails.Err = errors.New("queue unavailable")
+	authorizedRequest(r, "PATCH", productPath, adminToken, `{"price":1999}`)
+	deps.DeliverEvents()
+	assert.Len(t, alerts("wishlist_price_drop"), 5)
+
+	deps.Emails.Err = nil
+	events = outboxEvents(deps, models.EventPriceChanged)
+	var change models.PriceChangedEvent
+	require.NoError(t, json.Unmarshal([]byte(events[len(events)-1].Payload), &change))
+	require.NoError(t, deps.Wishlists.SendPriceDropAlerts(events[len(events)-1].ID, change))
+	dropped = alerts("wishlist_price_drop")
+	require.Len(t, dropped, 8)
+	assert.Equal(t, "19.99", dropped[7]["price"])
+}
@@ -0,0 +1,61 @@
+// Package jobs is a reliable background job queue. Jobs are reserved with a
+// visibility timeout instead of being popped, so a worker that crashes mid-job
+// doesn't lose it; failed jobs are retried with exponential backoff and end up
+// in a dead-letter queue once they run out of attempts.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Job is the envelope every queued job travels in.
+type Job struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	CreatedAt   time.Time       `json:"created_at"`
+	LastError   string          `json:"last_error,omitempty"`
+	FailedAt    *time.Time      `json:"failed_at,omitempty"`
+
+	// raw is the exact encoding the job was reserved as, which stores use
+	// to find it again.
+	raw []byte
+}
+
+// Decode unmarshals the job's payload into v.
+func (j *Job) Decode(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Handler processes one job. Returning an error retries the job, unless the
+// error is wrapped with Permanent.
+type Handler func(ctx context.Context, job *Job) error
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrNoHandler   = errors.New("no handler for job type")
+)
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error that retrying can't fix, such as a malformed
+// payload. The job goes straight to the dead-letter queue.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
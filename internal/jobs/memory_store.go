@@ -0,0 +1,226 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store kept in process memory. Jobs don't survive a
+// restart, so it is only meant for tests and running without Redis.
+type MemoryStore struct {
+	mu     sync.Mutex
+	queues map[string]*memoryQueue
+}
+
+type memoryLease struct {
+	job      *Job
+	deadline time.Time
+}
+
+type memoryDelayed struct {
+	job *Job
+	at  time.Time
+}
+
+type memoryQueue struct {
+	ready      []*Job
+	processing []*memoryLease
+	delayed    []*memoryDelayed
+	dead       []*Job
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{queues: make(map[string]*memoryQueue)}
+}
+
+// pollInterval is how often Reserve checks an empty queue.
+const pollInterval = 10 * time.Millisecond
+
+func (s *MemoryStore) queue(name string) *memoryQueue {
+	q, ok := s.queues[name]
+	if !ok {
+		q = &memoryQueue{}
+		s.queues[name] = q
+	}
+	return q
+}
+
+func (s *MemoryStore) Push(ctx context.Context, queue string, job *Job) error {
+	job, err := cloneJob(job)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.queue(queue)
+	q.ready = append(q.ready, job)
+	return nil
+}
+
+func (s *MemoryStore) Reserve(ctx context.Context, queue string, wait, visibility time.Duration) (*Job, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		if job := s.tryReserve(queue, visibility); job != nil {
+			return job, nil
+		}
+		if !time.Now().Before(deadline) {
+			return nil, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func (s *MemoryStore) tryReserve(queue string, visibility time.Duration) *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := s.queue(queue)
+	if len(q.ready) == 0 {
+		return nil
+	}
+	job := q.ready[0]
+	q.ready = q.ready[1:]
+	q.processing = append(q.processing, &memoryLease{job: job, deadline: time.Now().Add(visibility)})
+
+	reserved := *job
+	return &reserved
+}
+
+// release removes the reserved job from processing, reporting whether it
+// was still there.
+func (q *memoryQueue) release(id string) bool {
+	for i, lease := range q.processing {
+		if lease.job.ID == id {
+			q.processing = append(q.processing[:i], q.processing[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStore) Ack(ctx context.Context, queue string, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue(queue).release(job.ID)
+	return nil
+}
+
+func (s *MemoryStore) Retry(ctx context.Context, queue string, job *Job, at time.Time) error {
+	job, err := cloneJob(job)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.queue(queue)
+	q.release(job.ID)
+	q.delayed = append(q.delayed, &memoryDelayed{job: job, at: at})
+	return nil
+}
+
+func (s *MemoryStore) Bury(ctx context.Context, queue string, job *Job) error {
+	job, err := cloneJob(job)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.queue(queue)
+	q.release(job.ID)
+	q.dead = append([]*Job{job}, q.dead...)
+	return nil
+}
+
+func (s *MemoryStore) PromoteDue(ctx context.Context, queue string, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := s.queue(queue)
+	promoted := 0
+	waiting := q.delayed[:0]
+	for _, d := range q.delayed {
+		if d.at.After(now) {
+			waiting = append(waiting, d)
+			continue
+		}
+		q.ready = append(q.ready, d.job)
+		promoted++
+	}
+	q.delayed = waiting
+	return promoted, nil
+}
+
+func (s *MemoryStore) ReclaimExpired(ctx context.Context, queue string, now time.Time, visibility time.Duration) ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := s.queue(queue)
+	var reclaimed []*Job
+	for _, lease := range q.processing {
+		if lease.deadline.After(now) {
+			continue
+		}
+		// Keep the job in processing under a fresh lease until the caller
+		// retries or buries it.
+		lease.deadline = now.Add(visibility)
+		job := *lease.job
+		reclaimed = append(reclaimed, &job)
+	}
+	return reclaimed, nil
+}
+
+func (s *MemoryStore) Dead(ctx context.Context, queue string, offset, limit int) ([]*Job, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dead := s.queue(queue).dead
+	total := int64(len(dead))
+	if offset >= len(dead) {
+		return []*Job{}, total, nil
+	}
+	end := offset + limit
+	if end > len(dead) {
+		end = len(dead)
+	}
+
+	jobs := make([]*Job, 0, end-offset)
+	for _, job := range dead[offset:end] {
+		copied := *job
+		jobs = append(jobs, &copied)
+	}
+	return jobs, total, nil
+}
+
+func (s *MemoryStore) TakeDead(ctx context.Context, queue, id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := s.queue(queue)
+	for i, job := range q.dead {
+		if job.ID == id {
+			q.dead = append(q.dead[:i], q.dead[i+1:]...)
+			return job, nil
+		}
+	}
+	return nil, ErrJobNotFound
+}
+
+// cloneJob copies a job through its JSON encoding, so callers can't change
+// what the store holds.
+func cloneJob(job *Job) (*Job, error) {
+	raw, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJob(raw)
+}
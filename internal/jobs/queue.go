@@ -0,0 +1,288 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Options tunes a Queue. Zero values take the defaults below.
+type Options struct {
+	// MaxAttempts is how many times a job runs before it is buried.
+	MaxAttempts int
+	// Visibility is how long a reserved job may run before another worker
+	// may reclaim it. Handlers get a context that expires with it.
+	Visibility time.Duration
+	// BaseBackoff is the delay before the first retry; it doubles with each
+	// further attempt up to MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Wait is how long RunOnce waits for a job to arrive.
+	Wait time.Duration
+}
+
+const (
+	DefaultMaxAttempts = 5
+	DefaultVisibility  = 2 * time.Minute
+	DefaultBaseBackoff = 10 * time.Second
+	DefaultMaxBackoff  = 30 * time.Minute
+	DefaultWait        = 5 * time.Second
+)
+
+// Work pauses after the store fails, starting at minErrorBackoff and
+// doubling with each further failure in a row up to maxErrorBackoff.
+const (
+	minErrorBackoff = time.Second
+	maxErrorBackoff = 30 * time.Second
+)
+
+// Queue is a named queue in a Store.
+type Queue struct {
+	name  string
+	store Store
+	opts  Options
+	now   func() time.Time
+}
+
+func NewQueue(name string, store Store, opts Options) *Queue {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.Visibility <= 0 {
+		opts.Visibility = DefaultVisibility
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = DefaultBaseBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	if opts.Wait <= 0 {
+		opts.Wait = DefaultWait
+	}
+	return &Queue{name: name, store: store, opts: opts, now: time.Now}
+}
+
+func (q *Queue) Name() string {
+	return q.name
+}
+
+// Enqueue adds a job of jobType whose payload is v encoded as JSON.
+func (q *Queue) Enqueue(ctx context.Context, jobType string, v interface{}) (*Job, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encode payload: %w", err)
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{
+		ID:          id,
+		Type:        jobType,
+		Payload:     payload,
+		MaxAttempts: q.opts.MaxAttempts,
+		CreatedAt:   q.now().UTC(),
+	}
+	if err := q.store.Push(ctx, q.name, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// RunOnce does one round of work: it moves due retries back to ready,
+// reclaims jobs whose lease expired, then reserves and runs at most one job.
+// It reports whether a job was run.
+func (q *Queue) RunOnce(ctx context.Context, handlers map[string]Handler) (bool, error) {
+	now := q.now()
+	if _, err := q.store.PromoteDue(ctx, q.name, now); err != nil {
+		return false, fmt.Errorf("promote due jobs: %w", err)
+	}
+
+	expired, err := q.store.ReclaimExpired(ctx, q.name, now, q.opts.Visibility)
+	if err != nil {
+		return false, fmt.Errorf("reclaim expired jobs: %w", err)
+	}
+	for _, job := range expired {
+		slog.Warn("Job lease expired", "queue", q.name, "job_id", job.ID, "type", job.Type)
+		job.Attempts++
+		if err := q.fail(ctx, job, errors.New("visibility timeout expired")); err != nil {
+			return false, err
+		}
+	}
+
+	job, err := q.store.Reserve(ctx, q.name, q.opts.Wait, q.opts.Visibility)
+	if err != nil {
+		return false, fmt.Errorf("reserve job: %w", err)
+	}
+	if job == nil {
+		return false, nil
+	}
+
+	// Once reserved, a job runs to the end even if ctx is cancelled, so that
+	// shutting down doesn't abandon it half done. Its lease still bounds it.
+	ctx = context.WithoutCancel(ctx)
+	job.Attempts++
+	if err := q.run(ctx, handlers, job); err != nil {
+		slog.Warn("Job failed", "queue", q.name, "job_id", job.ID, "type", job.Type, "attempt", job.Attempts, "error", err)
+		return true, q.fail(ctx, job, err)
+	}
+
+	return true, q.store.Ack(ctx, q.name, job)
+}
+
+// Work runs jobs until ctx is cancelled. A job already running when ctx is
+// cancelled is finished first.
+func (q *Queue) Work(ctx context.Context, handlers map[string]Handler) {
+	delay := time.Duration(0)
+	for ctx.Err() == nil {
+		if _, err := q.RunOnce(ctx, handlers); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			delay = nextErrorBackoff(delay)
+			slog.Error("Job queue error", "queue", q.name, "error", err, "retry_in", delay)
+			select {
+			case <-ctx.Done():
+			case <-time.After(delay):
+			}
+			continue
+		}
+		delay = 0
+	}
+}
+
+func nextErrorBackoff(previous time.Duration) time.Duration {
+	if previous < minErrorBackoff {
+		return minErrorBackoff
+	}
+	if previous*2 > maxErrorBackoff {
+		return maxErrorBackoff
+	}
+	return previous * 2
+}
+
+// Pool is a group of workers started by Start.
+type Pool struct {
+	wg sync.WaitGroup
+}
+
+// Start runs concurrency workers in the background until ctx is cancelled.
+func (q *Queue) Start(ctx context.Context, concurrency int, handlers map[string]Handler) *Pool {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	pool := &Pool{}
+	for i := 0; i < concurrency; i++ {
+		pool.wg.Add(1)
+		go func() {
+			defer pool.wg.Done()
+			q.Work(ctx, handlers)
+		}()
+	}
+	return pool
+}
+
+// Wait blocks until every worker has stopped after finishing its current
+// job, or timeout passes. It reports whether all workers stopped.
+func (p *Pool) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (q *Queue) run(ctx context.Context, handlers map[string]Handler, job *Job) (err error) {
+	handler, ok := handlers[job.Type]
+	if !ok {
+		return Permanent(fmt.Errorf("%w %q", ErrNoHandler, job.Type))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, q.opts.Visibility)
+	defer cancel()
+	return handler(ctx, job)
+}
+
+// fail retries a job after its backoff, or buries it when the error is
+// permanent or it has no attempts left.
+func (q *Queue) fail(ctx context.Context, job *Job, cause error) error {
+	now := q.now()
+	job.LastError = cause.Error()
+
+	if IsPermanent(cause) || job.Attempts >= job.MaxAttempts {
+		failedAt := now.UTC()
+		job.FailedAt = &failedAt
+		slog.Error("Job moved to dead-letter queue", "queue", q.name, "job_id", job.ID, "type", job.Type, "attempts", job.Attempts, "error", cause)
+		return q.store.Bury(ctx, q.name, job)
+	}
+
+	return q.store.Retry(ctx, q.name, job, now.Add(q.backoff(job.Attempts)))
+}
+
+// backoff is the delay after the given failed attempt.
+func (q *Queue) backoff(attempt int) time.Duration {
+	delay := q.opts.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= q.opts.MaxBackoff {
+			return q.opts.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// Dead lists the queue's dead jobs, most recent first.
+func (q *Queue) Dead(ctx context.Context, offset, limit int) ([]*Job, int64, error) {
+	return q.store.Dead(ctx, q.name, offset, limit)
+}
+
+// RequeueDead moves a dead job back to ready with a fresh set of attempts.
+func (q *Queue) RequeueDead(ctx context.Context, id string) (*Job, error) {
+	job, err := q.store.TakeDead(ctx, q.name, id)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Attempts = 0
+	job.FailedAt = nil
+	if err := q.store.Push(ctx, q.name, job); err != nil {
+		// Put it back rather than lose it
+		if buryErr := q.store.Bury(ctx, q.name, job); buryErr != nil {
+			slog.Error("Failed to return job to dead-letter queue", "queue", q.name, "job_id", job.ID, "error", buryErr)
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
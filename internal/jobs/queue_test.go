@@ -0,0 +1,346 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time          { return c.now }
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestQueue(t *testing.T, store Store) (*Queue, *testClock) {
+	t.Helper()
+	clock := &testClock{now: time.Now()}
+	q := NewQueue("test", store, Options{
+		MaxAttempts: 3,
+		Visibility:  time.Minute,
+		BaseBackoff: 10 * time.Second,
+		MaxBackoff:  15 * time.Second,
+		Wait:        20 * time.Millisecond,
+	})
+	q.now = clock.Now
+	return q, clock
+}
+
+func testStores(t *testing.T) map[string]Store {
+	stores := map[string]Store{"memory": NewMemoryStore()}
+
+	// Set REDIS_ADDR to also run against a real Redis; its database is flushed.
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		client := redis.NewClient(&redis.Options{Addr: addr})
+		require.NoError(t, client.FlushDB(context.Background()).Err())
+		t.Cleanup(func() { client.Close() })
+		stores["redis"] = NewRedisStore(client)
+	}
+	return stores
+}
+
+func TestQueueRunsJob(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			q, _ := newTestQueue(t, store)
+
+			_, err := q.Enqueue(ctx, "greet", map[string]string{"name": "gopher"})
+			require.NoError(t, err)
+
+			var got map[string]string
+			ran, err := q.RunOnce(ctx, map[string]Handler{
+				"greet": func(ctx context.Context, job *Job) error {
+					assert.Equal(t, 1, job.Attempts)
+					return job.Decode(&got)
+				},
+			})
+			require.NoError(t, err)
+			assert.True(t, ran)
+			assert.Equal(t, "gopher", got["name"])
+
+			// Nothing left to run
+			ran, err = q.RunOnce(ctx, nil)
+			require.NoError(t, err)
+			assert.False(t, ran)
+		})
+	}
+}
+
+func TestQueueRetriesWithBackoffThenBuries(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			q, clock := newTestQueue(t, store)
+
+			calls := 0
+			handlers := map[string]Handler{
+				"flaky": func(ctx context.Context, job *Job) error {
+					calls++
+					return errors.New("smtp unavailable")
+				},
+			}
+
+			queued, err := q.Enqueue(ctx, "flaky", nil)
+			require.NoError(t, err)
+
+			_, err = q.RunOnce(ctx, handlers)
+			require.NoError(t, err)
+			assert.Equal(t, 1, calls)
+
+			// Not due until the 10s backoff has passed
+			clock.Advance(5 * time.Second)
+			ran, err := q.RunOnce(ctx, handlers)
+			require.NoError(t, err)
+			assert.False(t, ran)
+
+			clock.Advance(5 * time.Second)
+			ran, err = q.RunOnce(ctx, handlers)
+			require.NoError(t, err)
+			assert.True(t, ran)
+			assert.Equal(t, 2, calls)
+
+			// The second backoff doubles to 20s but is capped at 15s
+			clock.Advance(15 * time.Second)
+			_, err = q.RunOnce(ctx, handlers)
+			require.NoError(t, err)
+			assert.Equal(t, 3, calls)
+
+			// Out of attempts: the job is dead and never runs again
+			clock.Advance(time.Hour)
+			ran, err = q.RunOnce(ctx, handlers)
+			require.NoError(t, err)
+			assert.False(t, ran)
+
+			dead, total, err := q.Dead(ctx, 0, 10)
+			require.NoError(t, err)
+			require.EqualValues(t, 1, total)
+			assert.Equal(t, queued.ID, dead[0].ID)
+			assert.Equal(t, 3, dead[0].Attempts)
+			assert.Equal(t, "smtp unavailable", dead[0].LastError)
+			assert.NotNil(t, dead[0].FailedAt)
+		})
+	}
+}
+
+func TestQueueBuriesPermanentFailures(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			q, _ := newTestQueue(t, store)
+
+			_, err := q.Enqueue(ctx, "broken", nil)
+			require.NoError(t, err)
+			_, err = q.Enqueue(ctx, "unknown", nil)
+			require.NoError(t, err)
+
+			handlers := map[string]Handler{
+				"broken": func(ctx context.Context, job *Job) error {
+					return Permanent(errors.New("bad payload"))
+				},
+			}
+			for i := 0; i < 2; i++ {
+				_, err = q.RunOnce(ctx, handlers)
+				require.NoError(t, err)
+			}
+
+			dead, total, err := q.Dead(ctx, 0, 10)
+			require.NoError(t, err)
+			require.EqualValues(t, 2, total)
+			// Most recent first
+			assert.Equal(t, "unknown", dead[0].Type)
+			assert.Contains(t, dead[0].LastError, ErrNoHandler.Error())
+			assert.Equal(t, "broken", dead[1].Type)
+			assert.Equal(t, 1, dead[1].Attempts)
+		})
+	}
+}
+
+func TestQueueReclaimsExpiredLease(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			q, clock := newTestQueue(t, store)
+
+			_, err := q.Enqueue(ctx, "slow", nil)
+			require.NoError(t, err)
+
+			// A worker reserves the job and dies without acking it
+			job, err := store.Reserve(ctx, q.Name(), time.Second, time.Minute)
+			require.NoError(t, err)
+			require.NotNil(t, job)
+
+			calls := 0
+			handlers := map[string]Handler{
+				"slow": func(ctx context.Context, job *Job) error {
+					calls++
+					assert.Equal(t, 2, job.Attempts)
+					return nil
+				},
+			}
+
+			// Still leased
+			ran, err := q.RunOnce(ctx, handlers)
+			require.NoError(t, err)
+			assert.False(t, ran)
+
+			// The lease expires and counts as a failed attempt
+			clock.Advance(2 * time.Minute)
+			_, err = q.RunOnce(ctx, handlers)
+			require.NoError(t, err)
+
+			clock.Advance(time.Minute)
+			ran, err = q.RunOnce(ctx, handlers)
+			require.NoError(t, err)
+			assert.True(t, ran)
+			assert.Equal(t, 1, calls)
+		})
+	}
+}
+
+func TestRedisStoreDropsLeaseOfAckedJob(t *testing.T) {
+	store, ok := testStores(t)["redis"].(*RedisStore)
+	if !ok {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	ctx := context.Background()
+	q, clock := newTestQueue(t, store)
+
+	_, err := q.Enqueue(ctx, "once", nil)
+	require.NoError(t, err)
+	job, err := store.Reserve(ctx, q.Name(), time.Second, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+
+	// A reclaimer saw the job in processing, then the worker acked it before
+	// the reclaimer leased it
+	require.NoError(t, store.Ack(ctx, q.Name(), job))
+	lease := redis.Z{Score: float64(clock.Now().Add(time.Minute).UnixMilli()), Member: string(job.raw)}
+	require.NoError(t, store.client.ZAddNX(ctx, queueKey(q.Name(), "leases"), lease).Err())
+
+	clock.Advance(2 * time.Minute)
+	reclaimed, err := store.ReclaimExpired(ctx, q.Name(), clock.Now(), time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, reclaimed, "An acked job must not run again")
+	leases, err := store.client.ZCard(ctx, queueKey(q.Name(), "leases")).Result()
+	require.NoError(t, err)
+	assert.Zero(t, leases)
+
+	ran, err := q.RunOnce(ctx, map[string]Handler{
+		"once": func(ctx context.Context, job *Job) error { return nil },
+	})
+	require.NoError(t, err)
+	assert.False(t, ran)
+}
+
+func TestQueueRequeueDead(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			q, _ := newTestQueue(t, store)
+
+			queued, err := q.Enqueue(ctx, "broken", nil)
+			require.NoError(t, err)
+			_, err = q.RunOnce(ctx, map[string]Handler{
+				"broken": func(ctx context.Context, job *Job) error {
+					return Permanent(errors.New("bad payload"))
+				},
+			})
+			require.NoError(t, err)
+
+			_, err = q.RequeueDead(ctx, "missing")
+			assert.ErrorIs(t, err, ErrJobNotFound)
+
+			requeued, err := q.RequeueDead(ctx, queued.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 0, requeued.Attempts)
+			assert.Nil(t, requeued.FailedAt)
+
+			_, total, err := q.Dead(ctx, 0, 10)
+			require.NoError(t, err)
+			assert.EqualValues(t, 0, total)
+
+			ran, err := q.RunOnce(ctx, map[string]Handler{
+				"broken": func(ctx context.Context, job *Job) error {
+					assert.Equal(t, 1, job.Attempts)
+					return nil
+				},
+			})
+			require.NoError(t, err)
+			assert.True(t, ran)
+		})
+	}
+}
+
+func TestPoolRunsConcurrentlyAndDrainsOnShutdown(t *testing.T) {
+	store := NewMemoryStore()
+	q := NewQueue("test", store, Options{Wait: 20 * time.Millisecond})
+
+	started := make(chan string, 3)
+	release := make(chan struct{})
+	finished := make(chan string, 3)
+	handlers := map[string]Handler{
+		"slow": func(ctx context.Context, job *Job) error {
+			started <- job.ID
+			<-release
+			// Shutting down must not cancel a running job
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			finished <- job.ID
+			return nil
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := q.Enqueue(context.Background(), "slow", nil)
+		require.NoError(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := q.Start(ctx, 3, handlers)
+
+	// All three run at once
+	for i := 0; i < 3; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("jobs did not run concurrently")
+		}
+	}
+
+	cancel()
+	assert.False(t, pool.Wait(50*time.Millisecond), "workers stopped while jobs were running")
+
+	close(release)
+	require.True(t, pool.Wait(time.Second))
+	assert.Len(t, finished, 3)
+
+	// Every job was acked rather than retried or buried
+	_, total, err := q.Dead(context.Background(), 0, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, total)
+	reclaimed, err := store.ReclaimExpired(context.Background(), "test", time.Now().Add(time.Hour), time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, reclaimed)
+}
+
+func TestErrorBackoffGrowsAndCaps(t *testing.T) {
+	delay := time.Duration(0)
+	var delays []time.Duration
+	for i := 0; i < 7; i++ {
+		delay = nextErrorBackoff(delay)
+		delays = append(delays, delay)
+	}
+	assert.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second,
+		16 * time.Second, 30 * time.Second, 30 * time.Second,
+	}, delays)
+}
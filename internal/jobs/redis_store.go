@@ -0,0 +1,227 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore keeps each queue in five keys:
+//
+//	jobs:<queue>:ready       list of jobs waiting to run
+//	jobs:<queue>:processing  list of reserved jobs
+//	jobs:<queue>:leases      zset of reserved jobs scored by lease expiry
+//	jobs:<queue>:delayed     zset of jobs scored by when to retry them
+//	jobs:<queue>:dead        list of jobs that ran out of attempts
+//
+// Reserving is a single BLMOVE from ready to processing, so a job is never
+// only in a worker's memory.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func queueKey(queue, part string) string {
+	return fmt.Sprintf("jobs:%s:%s", queue, part)
+}
+
+func (s *RedisStore) Push(ctx context.Context, queue string, job *Job) error {
+	raw, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.client.RPush(ctx, queueKey(queue, "ready"), raw).Err()
+}
+
+func (s *RedisStore) Reserve(ctx context.Context, queue string, wait, visibility time.Duration) (*Job, error) {
+	raw, err := s.client.BLMove(ctx, queueKey(queue, "ready"), queueKey(queue, "processing"), "LEFT", "RIGHT", wait).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Should this fail, ReclaimExpired gives the job a lease later.
+	lease := float64(time.Now().Add(visibility).UnixMilli())
+	if err := s.client.ZAdd(ctx, queueKey(queue, "leases"), redis.Z{Score: lease, Member: raw}).Err(); err != nil {
+		return nil, err
+	}
+	return decodeJob([]byte(raw))
+}
+
+func (s *RedisStore) Ack(ctx context.Context, queue string, job *Job) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, queueKey(queue, "processing"), 1, job.raw)
+		pipe.ZRem(ctx, queueKey(queue, "leases"), job.raw)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) Retry(ctx context.Context, queue string, job *Job, at time.Time) error {
+	raw, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, queueKey(queue, "processing"), 1, job.raw)
+		pipe.ZRem(ctx, queueKey(queue, "leases"), job.raw)
+		pipe.ZAdd(ctx, queueKey(queue, "delayed"), redis.Z{Score: float64(at.UnixMilli()), Member: raw})
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) Bury(ctx context.Context, queue string, job *Job) error {
+	raw, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, queueKey(queue, "processing"), 1, job.raw)
+		pipe.ZRem(ctx, queueKey(queue, "leases"), job.raw)
+		pipe.LPush(ctx, queueKey(queue, "dead"), raw)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) PromoteDue(ctx context.Context, queue string, now time.Time) (int, error) {
+	due, err := s.client.ZRangeByScore(ctx, queueKey(queue, "delayed"), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	promoted := 0
+	for _, raw := range due {
+		// Whoever removes the job from delayed gets to push it
+		removed, err := s.client.ZRem(ctx, queueKey(queue, "delayed"), raw).Result()
+		if err != nil {
+			return promoted, err
+		}
+		if removed == 0 {
+			continue
+		}
+		if err := s.client.RPush(ctx, queueKey(queue, "ready"), raw).Err(); err != nil {
+			return promoted, err
+		}
+		promoted++
+	}
+	return promoted, nil
+}
+
+// reclaimScript leases the jobs in processing that have no lease yet, then
+// renews and returns the expired leases of jobs still in processing. A lease
+// whose job has left processing, because it was acked or failed meanwhile, is
+// dropped. Running as one script, it can't lease a job acked halfway through.
+//
+//	KEYS[1] processing, KEYS[2] leases
+//	ARGV[1] now, ARGV[2] lease expiry of reclaimed jobs (Unix milliseconds)
+var reclaimScript = redis.NewScript(`
+local processing = {}
+for _, raw in ipairs(redis.call('LRANGE', KEYS[1], 0, -1)) do
+	processing[raw] = true
+	redis.call('ZADD', KEYS[2], 'NX', ARGV[2], raw)
+end
+
+local reclaimed = {}
+for _, raw in ipairs(redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])) do
+	if processing[raw] then
+		redis.call('ZADD', KEYS[2], 'XX', ARGV[2], raw)
+		table.insert(reclaimed, raw)
+	else
+		redis.call('ZREM', KEYS[2], raw)
+	end
+end
+return reclaimed
+`)
+
+func (s *RedisStore) ReclaimExpired(ctx context.Context, queue string, now time.Time, visibility time.Duration) ([]*Job, error) {
+	// Jobs moved to processing by a worker that died before leasing them get
+	// a lease too, and reclaimed jobs stay in processing under a fresh lease
+	// until the caller retries or buries them.
+	expired, err := reclaimScript.Run(ctx, s.client,
+		[]string{queueKey(queue, "processing"), queueKey(queue, "leases")},
+		now.UnixMilli(), now.Add(visibility).UnixMilli(),
+	).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	reclaimed := make([]*Job, 0, len(expired))
+	for _, raw := range expired {
+		job, err := decodeJob([]byte(raw))
+		if err != nil {
+			return reclaimed, err
+		}
+		reclaimed = append(reclaimed, job)
+	}
+	return reclaimed, nil
+}
+
+func (s *RedisStore) Dead(ctx context.Context, queue string, offset, limit int) ([]*Job, int64, error) {
+	total, err := s.client.LLen(ctx, queueKey(queue, "dead")).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	raws, err := s.client.LRange(ctx, queueKey(queue, "dead"), int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	jobs := make([]*Job, 0, len(raws))
+	for _, raw := range raws {
+		job, err := decodeJob([]byte(raw))
+		if err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, total, nil
+}
+
+func (s *RedisStore) TakeDead(ctx context.Context, queue, id string) (*Job, error) {
+	raws, err := s.client.LRange(ctx, queueKey(queue, "dead"), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, raw := range raws {
+		job, err := decodeJob([]byte(raw))
+		if err != nil || job.ID != id {
+			continue
+		}
+		removed, err := s.client.LRem(ctx, queueKey(queue, "dead"), 1, raw).Result()
+		if err != nil {
+			return nil, err
+		}
+		if removed == 0 {
+			// Someone else took it first
+			return nil, ErrJobNotFound
+		}
+		return job, nil
+	}
+	return nil, ErrJobNotFound
+}
+
+func decodeJob(raw []byte) (*Job, error) {
+	var job Job
+	if err := json.Unmarshal(raw, &job); err != nil {
+		return nil, fmt.Errorf("decode job: %w", err)
+	}
+	job.raw = raw
+	return &job, nil
+}
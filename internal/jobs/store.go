@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"time"
+)
+
+// Store holds the queue's state. A job is in exactly one of four places:
+// ready, processing (reserved by a worker until its lease expires), delayed
+// (waiting for a retry) or dead.
+type Store interface {
+	// Push adds a job to the back of the ready list.
+	Push(ctx context.Context, queue string, job *Job) error
+	// Reserve moves the next ready job to processing with a lease of
+	// visibility, waiting up to wait for one. It returns nil when none came.
+	Reserve(ctx context.Context, queue string, wait, visibility time.Duration) (*Job, error)
+	// Ack removes a finished job from processing.
+	Ack(ctx context.Context, queue string, job *Job) error
+	// Retry moves a reserved job to delayed until at.
+	Retry(ctx context.Context, queue string, job *Job, at time.Time) error
+	// Bury moves a reserved job to dead.
+	Bury(ctx context.Context, queue string, job *Job) error
+	// PromoteDue moves delayed jobs whose time has come back to ready.
+	PromoteDue(ctx context.Context, queue string, now time.Time) (int, error)
+	// ReclaimExpired takes jobs whose lease expired out of processing and
+	// returns them, still reserved, so they can be retried or buried.
+	ReclaimExpired(ctx context.Context, queue string, now time.Time, visibility time.Duration) ([]*Job, error)
+	// Dead lists dead jobs, most recent first.
+	Dead(ctx context.Context, queue string, offset, limit int) ([]*Job, int64, error)
+	// TakeDead removes a dead job by ID and returns it.
+	TakeDead(ctx context.Context, queue, id string) (*Job, error)
+}
@@ -0,0 +1,58 @@
+// Package mailer renders email tasks from templates and delivers them.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Message is a rendered email with a plain text and an HTML body.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers rendered messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// FromEnv picks the Mailer configured by MAILER:
+//
+//   - "smtp": SMTPMailer using SMTP_HOST, SMTP_PORT, SMTP_USERNAME,
+//     SMTP_PASSWORD and MAIL_FROM
+//   - "file": FileMailer writing .eml files to MAIL_DROP_DIR
+//   - anything else: LogMailer, for development
+func FromEnv() (Mailer, error) {
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		port, err := strconv.Atoi(envOr("SMTP_PORT", "587"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMTP_PORT: %w", err)
+		}
+		return &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     envOr("MAIL_FROM", defaultFrom),
+		}, nil
+	case "file":
+		return NewFileMailer(envOr("MAIL_DROP_DIR", "mail"), envOr("MAIL_FROM", defaultFrom))
+	default:
+		return &LogMailer{}, nil
+	}
+}
+
+const defaultFrom = "GopherGames <no-reply@gamestore.local>"
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,213 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"mime"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	templates, err := LoadTemplates("https://shop.example.com/")
+	require.NoError(t, err)
+
+	tasks := []map[string]string{
+		{"type": "welcome_email", "email": "player@example.com"},
+		{"type": "verify_email", "email": "player@example.com", "token": "abc.def", "expires_at": "2026-01-01T00:00:00Z"},
+		{"type": "password_reset", "email": "player@example.com", "token": "abc.def", "expires_at": "2026-01-01T00:00:00Z"},
+		{"type": "order_confirmation", "email": "player@example.com", "order_id": "42", "total": "59.99", "currency": "USD"},
+		{"type": "order_shipped", "email": "player@example.com", "order_id": "42", "carrier": "DHL", "tracking_number": "TRK1"},
+		{"type": "order_cancelled", "email": "player@example.com", "order_id": "42", "total": "59.99", "currency": "USD"},
+		{"type": "order_delivered", "email": "player@example.com", "order_id": "42"},
+		{"type": "order_refunded", "email": "player@example.com", "order_id": "42", "total": "59.99", "currency": "USD"},
+		{"type": "wishlist_back_in_stock", "email": "player@example.com", "product_id": "7", "product_name": "Half-Life 3", "price": "59.99", "currency": "USD"},
+		{"type": "wishlist_price_drop", "email": "player@example.com", "product_id": "7", "product_name": "Half-Life 3", "price": "39.99", "previous_price": "59.99", "currency": "USD"},
+	}
+	for _, task := range tasks {
+		msg, err := templates.Render(task)
+		require.NoError(t, err, task["type"])
+		assert.Equal(t, "player@example.com", msg.To)
+		assert.NotEmpty(t, msg.Subject, task["type"])
+		assert.NotContains(t, msg.Subject, "\n")
+		assert.NotEmpty(t, msg.Text, task["type"])
+		assert.Contains(t, msg.HTML, "<html>", task["type"])
+	}
+
+	msg, _ := templates.Render(tasks[1])
+	assert.Contains(t, msg.Text, "https://shop.example.com/?verify_token=abc.def")
+	assert.Contains(t, msg.HTML, `href="https://shop.example.com/?verify_token=abc.def"`)
+
+	msg, _ = templates.Render(tasks[3])
+	assert.Equal(t, "Your GopherGames order #42", msg.Subject)
+	assert.Contains(t, msg.Text, "59.99 USD")
+
+	// Task fields are escaped in HTML
+	msg, _ = templates.Render(map[string]string{"type": "welcome_email", "email": "<script>@example.com"})
+	assert.NotContains(t, msg.HTML, "<script>")
+
+	_, err = templates.Render(map[string]string{"type": "unknown", "email": "player@example.com"})
+	assert.Error(t, err)
+	_, err = templates.Render(map[string]string{"type": "welcome_email"})
+	assert.Error(t, err)
+}
+
+// smtpStandIn is a minimal SMTP server that accepts one message.
+type smtpStandIn struct {
+	listener net.Listener
+	auth     chan string
+	from     chan string
+	to       chan string
+	data     chan string
+}
+
+func startSMTPStandIn(t *testing.T) *smtpStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	s := &smtpStandIn{
+		listener: listener,
+		auth:     make(chan string, 1),
+		from:     make(chan string, 1),
+		to:       make(chan string, 1),
+		data:     make(chan string, 1),
+	}
+	go s.serve()
+	return s
+}
+
+func (s *smtpStandIn) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(command, "AUTH PLAIN"):
+			s.auth <- strings.TrimSpace(line[len("AUTH PLAIN"):])
+			reply("235 2.7.0 Authentication successful")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.from <- line[len("MAIL FROM:"):]
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.to <- line[len("RCPT TO:"):]
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			s.data <- data.String()
+			reply("250 OK: queued")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPMailerAgainstLocalServer(t *testing.T) {
+	server := startSMTPStandIn(t)
+	addr := server.listener.Addr().(*net.TCPAddr)
+
+	m := &SMTPMailer{
+		Host:     "localhost",
+		Port:     addr.Port,
+		Username: "mailer",
+		Password: "secret",
+		From:     "shop@example.com",
+	}
+	err := m.Send(context.Background(), Message{
+		To:      "player@example.com",
+		Subject: "Verify your GopherGames email",
+		Text:    "Hello player",
+		HTML:    "<p>Hello player</p>",
+	})
+	require.NoError(t, err)
+
+	credentials, _ := base64.StdEncoding.DecodeString(<-server.auth)
+	assert.Equal(t, "\x00mailer\x00secret", string(credentials))
+	assert.Equal(t, "<shop@example.com>", <-server.from)
+	assert.Equal(t, "<player@example.com>", <-server.to)
+
+	data := <-server.data
+	assert.Contains(t, data, "Subject: Verify your GopherGames email\r\n")
+	assert.Contains(t, data, "Content-Type: multipart/alternative;")
+	assert.Contains(t, data, "Content-Type: text/plain; charset=UTF-8")
+	assert.Contains(t, data, "Content-Type: text/html; charset=UTF-8")
+	assert.Contains(t, data, "<p>Hello player</p>")
+}
+
+func TestBuildMIMEEncodesHeaders(t *testing.T) {
+	body, err := buildMIME("shop@example.com", Message{
+		To:      "player@example.com\r\nCc: victim@example.com",
+		Subject: "Pokémon is back\r\nBcc: spam@example.com",
+		Text:    "Hello",
+		HTML:    "<p>Hello</p>",
+	})
+	require.NoError(t, err)
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(body)))
+	require.NoError(t, err)
+	assert.Empty(t, msg.Header.Get("Bcc"))
+	assert.Empty(t, msg.Header.Get("Cc"))
+	assert.Equal(t, "player@example.com Cc: victim@example.com", msg.Header.Get("To"))
+
+	// The header is plain ASCII, and decodes to the subject on one line
+	raw := msg.Header.Get("Subject")
+	assert.NotContains(t, raw, "é")
+	subject, err := new(mime.WordDecoder).DecodeHeader(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "Pokémon is back Bcc: spam@example.com", subject)
+}
+
+func TestFileMailerDropsEmlFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir, "shop@example.com")
+	require.NoError(t, err)
+
+	require.NoError(t, m.Send(context.Background(), Message{To: "player@example.com", Subject: "Hi", Text: "Hello", HTML: "<p>Hello</p>"}))
+
+	files, _ := os.ReadDir(dir)
+	require.Len(t, files, 1)
+	assert.True(t, strings.HasSuffix(files[0].Name(), "player_at_example.com.eml"))
+
+	body, _ := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	assert.Contains(t, string(body), "To: player@example.com\r\n")
+	assert.Contains(t, string(body), "Subject: Hi\r\n")
+}
@@ -0,0 +1,94 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// Templates renders email tasks into messages. Every task type has a
+// templates/<type>.txt file, which also defines the "subject" template, and a
+// templates/<type>.html file. Templates see the task's fields plus base_url.
+type Templates struct {
+	baseURL string
+	text    map[string]*texttemplate.Template
+	html    map[string]*htmltemplate.Template
+}
+
+// LoadTemplates parses the embedded templates. baseURL is the storefront
+// address used to build links.
+func LoadTemplates(baseURL string) (*Templates, error) {
+	t := &Templates{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		text:    map[string]*texttemplate.Template{},
+		html:    map[string]*htmltemplate.Template{},
+	}
+
+	entries, err := templateFS.ReadDir("templates")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		path := "templates/" + name
+		switch {
+		case strings.HasSuffix(name, ".txt"):
+			tmpl, err := texttemplate.New(name).Option("missingkey=zero").ParseFS(templateFS, path)
+			if err != nil {
+				return nil, err
+			}
+			t.text[strings.TrimSuffix(name, ".txt")] = tmpl
+		case strings.HasSuffix(name, ".html"):
+			tmpl, err := htmltemplate.New(name).Option("missingkey=zero").ParseFS(templateFS, path)
+			if err != nil {
+				return nil, err
+			}
+			t.html[strings.TrimSuffix(name, ".html")] = tmpl
+		}
+	}
+	return t, nil
+}
+
+// Render builds the message for a task such as
+// {"type": "welcome_email", "email": "player@example.com"}.
+func (t *Templates) Render(task map[string]string) (Message, error) {
+	taskType := task["type"]
+	text, okText := t.text[taskType]
+	html, okHTML := t.html[taskType]
+	if !okText || !okHTML {
+		return Message{}, fmt.Errorf("no email template for task type %q", taskType)
+	}
+	if task["email"] == "" {
+		return Message{}, fmt.Errorf("%s task has no email address", taskType)
+	}
+
+	data := make(map[string]string, len(task)+1)
+	for k, v := range task {
+		data[k] = v
+	}
+	data["base_url"] = t.baseURL
+
+	var subject, textBody, htmlBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := text.Execute(&textBody, data); err != nil {
+		return Message{}, err
+	}
+	if err := html.Execute(&htmlBody, data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      task["email"],
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(textBody.String()) + "\n",
+		HTML:    htmlBody.String(),
+	}, nil
+}
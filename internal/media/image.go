@@ -0,0 +1,133 @@
+// Package media checks uploaded images and makes thumbnails of them.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // register the GIF decoder
+	"image/jpeg"
+	_ "image/png" // register the PNG decoder
+	"net/http"
+)
+
+const (
+	// MaxImageSize caps an uploaded image at 10 MB.
+	MaxImageSize = 10 << 20
+	// maxPixels keeps a small but huge-dimensioned file from exhausting
+	// memory when it is decoded.
+	maxPixels = 50_000_000
+
+	ThumbnailWidth  = 400
+	ThumbnailHeight = 400
+)
+
+var (
+	ErrUnsupportedImage = errors.New("images must be JPEG, PNG or GIF")
+	ErrImageTooLarge    = fmt.Errorf("images must be at most %d MB", MaxImageSize>>20)
+)
+
+// formats maps the content types we accept to the extension stored files get.
+var formats = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Image is a checked upload and its thumbnail, which is always a JPEG.
+type Image struct {
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	Thumbnail   []byte
+}
+
+// Process checks that data is a JPEG, PNG or GIF of acceptable size, going
+// by its content rather than the name or type the client gave, and makes a
+// thumbnail of it that fits ThumbnailWidth x ThumbnailHeight.
+func Process(data []byte) (*Image, error) {
+	if len(data) > MaxImageSize {
+		return nil, ErrImageTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := formats[contentType]
+	if !ok {
+		return nil, ErrUnsupportedImage
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels is too many", ErrImageTooLarge, config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+
+	var thumbnail bytes.Buffer
+	if err := jpeg.Encode(&thumbnail, Thumbnail(img, ThumbnailWidth, ThumbnailHeight), &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return &Image{
+		ContentType: contentType,
+		Ext:         ext,
+		Width:       config.Width,
+		Height:      config.Height,
+		Thumbnail:   thumbnail.Bytes(),
+	}, nil
+}
+
+// Thumbnail scales img down to fit maxWidth x maxHeight, keeping its aspect
+// ratio, by averaging the pixels each thumbnail pixel covers. Smaller images
+// keep their size. Transparent areas become white, since thumbnails are JPEGs.
+func Thumbnail(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+
+	width, height := srcWidth, srcHeight
+	if width > maxWidth {
+		width, height = maxWidth, max(height*maxWidth/width, 1)
+	}
+	if height > maxHeight {
+		width, height = max(width*maxHeight/height, 1), maxHeight
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, srcWidth, srcHeight))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Over)
+	if width == srcWidth && height == srcHeight {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcHeight/height, max((y+1)*srcHeight/height, y*srcHeight/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcWidth/width, max((x+1)*srcWidth/width, x*srcWidth/width+1)
+
+			var r, g, b, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					r += int(row[sx*4])
+					g += int(row[sx*4+1])
+					b += int(row[sx*4+2])
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = 0xff
+		}
+	}
+	return dst
+}
@@ -2,17 +2,26 @@ package middleware
 
 import (
 	"fmt"
+	"math"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// AuthMiddleware verifies the JWT token sent in the Authorization header.
-// It extracts the UserID and Role and injects them into the Gin Context.
-func AuthMiddleware() gin.HandlerFunc {
+// RevocationChecker reports whether an access token was revoked before it
+// expired.
+type RevocationChecker interface {
+	IsRevoked(jti string, userID uint, issuedAt time.Time) (bool, error)
+}
+
+// AuthMiddleware verifies the JWT token sent in the Authorization header and
+// rejects tokens revoked by a logout.
+// It extracts the UserID, Role and token ID and injects them into the Gin Context.
+func AuthMiddleware(revocations RevocationChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get token from header
 		authHeader := c.GetHeader("Authorization")
@@ -54,6 +63,29 @@ func AuthMiddleware() gin.HandlerFunc {
 			if role, ok := claims["role"].(string); ok {
 				c.Set("userRole", role)
 			}
+
+			jti, _ := claims["jti"].(string)
+			_, iatErr := claims.GetIssuedAt()
+			iat, iatOK := claims["iat"].(float64)
+			expiresAt, expErr := claims.GetExpirationTime()
+			if jti == "" || iatErr != nil || !iatOK || expErr != nil || expiresAt == nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+				return
+			}
+
+			// GetIssuedAt drops the milliseconds the issue time is given in
+			issuedAt := time.UnixMilli(int64(math.Round(iat * 1000)))
+			revoked, err := revocations.IsRevoked(jti, c.GetUint("userID"), issuedAt)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify token"})
+				return
+			}
+			if revoked {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+				return
+			}
+			c.Set("tokenID", jti)
+			c.Set("tokenExpiresAt", expiresAt.Time)
 		} else {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims structure"})
 			return
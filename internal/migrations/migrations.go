@@ -0,0 +1,96 @@
+// Package migrations runs the one-off schema and data changes AutoMigrate
+// can't make, each once per database.
+package migrations
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Migration is a one-off change. ID orders the migrations and must never
+// change once released.
+type Migration struct {
+	ID  string
+	Run func(tx *gorm.DB) error
+}
+
+// All lists the migrations to run after AutoMigrate, in order.
+var All = []Migration{
+	{
+		// Coupon codes used to be unique among deleted coupons too
+		ID: "0001_coupon_codes_unique_among_live_coupons",
+		Run: func(tx *gorm.DB) error {
+			if err := tx.Exec("DROP INDEX IF EXISTS idx_coupons_code").Error; err != nil {
+				return err
+			}
+			return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_coupons_live_code ON coupons (code) WHERE deleted_at IS NULL").Error
+		},
+	},
+	{
+		// Checkout started requiring a verified email after accounts existed
+		// that had never been asked to verify it
+		ID: "0002_verify_existing_users",
+		Run: func(tx *gorm.DB) error {
+			return tx.Exec("UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL").Error
+		},
+	},
+}
+
+// appliedMigration records a migration that ran.
+type appliedMigration struct {
+	ID        string `gorm:"primaryKey;size:100"`
+	AppliedAt time.Time
+}
+
+func (appliedMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Run runs the migrations that haven't run on db yet, each in its own
+// transaction along with its record. An instance starting at the same time
+// waits on the record and skips the migration once it is committed.
+func Run(db *gorm.DB, migrations []Migration) error {
+	if err := db.AutoMigrate(&appliedMigration{}); err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		applied, err := run(db, migration)
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", migration.ID, err)
+		}
+		if applied {
+			slog.Info("Applied migration", "id", migration.ID)
+		}
+	}
+	return nil
+}
+
+// run runs migration unless it is already recorded, and reports whether it ran.
+func run(db *gorm.DB, migration Migration) (bool, error) {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	record := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&appliedMigration{ID: migration.ID, AppliedAt: time.Now()})
+	if record.Error != nil || record.RowsAffected == 0 {
+		tx.Rollback()
+		return false, record.Error
+	}
+	if err := migration.Run(tx); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
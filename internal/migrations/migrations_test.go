@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"errors"
+	"game-store-api/internal/models"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestRunAppliesEachMigrationOnce(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true})
+	require.NoError(t, err)
+
+	runs := map[string]int{}
+	migrations := []Migration{
+		{ID: "0001_first", Run: func(tx *gorm.DB) error { runs["0001_first"]++; return nil }},
+		{ID: "0002_broken", Run: func(tx *gorm.DB) error { runs["0002_broken"]++; return errors.New("boom") }},
+	}
+
+	assert.Error(t, Run(db, migrations))
+	assert.Error(t, Run(db, migrations), "A failed migration is retried on the next run")
+	assert.Equal(t, 1, runs["0001_first"])
+	assert.Equal(t, 2, runs["0002_broken"])
+
+	var ids []string
+	db.Model(&appliedMigration{}).Pluck("id", &ids)
+	assert.Equal(t, []string{"0001_first"}, ids)
+}
+
+func TestAllRunOnceOnCurrentSchema(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Coupon{}, &models.User{}))
+
+	existing := models.User{Email: "existing@test.com", Password: "hashed", Role: "user"}
+	require.NoError(t, db.Create(&existing).Error)
+	require.NoError(t, Run(db, All))
+	db.First(&existing, existing.ID)
+	assert.True(t, existing.EmailVerified(), "Accounts from before the verification requirement can still check out")
+
+	// Accounts created afterwards still have to verify
+	newcomer := models.User{Email: "newcomer@test.com", Password: "hashed", Role: "user"}
+	require.NoError(t, db.Create(&newcomer).Error)
+	require.NoError(t, Run(db, All))
+	db.First(&newcomer, newcomer.ID)
+	assert.False(t, newcomer.EmailVerified())
+}
@@ -2,10 +2,15 @@ package models
 
 import "gorm.io/gorm"
 
+// CartItem is a product in a user's cart. AddedPrice and AddedCurrency are
+// the product's price when the item was last added, so the cart can tell
+// the customer about price changes since.
 type CartItem struct {
 	gorm.Model
-	UserID    uint    `json:"user_id"`
-	ProductID uint    `json:"product_id"`
-	Product   Product `json:"product"`
-	Quantity  int     `json:"quantity"`
+	UserID        uint    `json:"user_id"`
+	ProductID     uint    `json:"product_id"`
+	Product       Product `json:"product"`
+	Quantity      int     `json:"quantity"`
+	AddedPrice    Money   `json:"-"`
+	AddedCurrency string  `json:"-" gorm:"size:3"`
 }
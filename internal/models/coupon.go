@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	CouponTypePercentage = "percentage"
+	CouponTypeFixed      = "fixed"
+)
+
+// Coupon is a discount code. Value is a percentage (1-100) for percentage
+// coupons and an amount in the minor unit of Currency for fixed ones, and
+// MinSpend is in Currency too. A coupon with Products or Categories only
+// discounts those products and the products in those categories; without
+// either it discounts the whole cart. Zero usage limits mean unlimited.
+// Deleting a coupon frees its code for a new one.
+type Coupon struct {
+	gorm.Model
+	Code           string     `json:"code" gorm:"size:64;uniqueIndex:idx_coupons_live_code,where:deleted_at IS NULL"`
+	Description    string     `json:"description"`
+	Type           string     `json:"type" gorm:"size:16"`
+	Value          int64      `json:"value"`
+	Currency       string     `json:"currency" gorm:"size:3;default:'USD'"`
+	MinSpend       Money      `json:"min_spend"`
+	Products       []Product  `json:"products,omitempty" gorm:"many2many:coupon_products"`
+	Categories     []Category `json:"categories,omitempty" gorm:"many2many:coupon_categories"`
+	StartsAt       *time.Time `json:"starts_at"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	MaxUses        int        `json:"max_uses"`
+	MaxUsesPerUser int        `json:"max_uses_per_user"`
+	Uses           int        `json:"uses"`
+}
+
+// CouponRedemption is a coupon used on an order. It counts toward the
+// coupon's usage limits until the order is cancelled.
+type CouponRedemption struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CouponID  uint      `json:"coupon_id" gorm:"index"`
+	UserID    uint      `json:"user_id" gorm:"index"`
+	OrderID   uint      `json:"order_id" gorm:"uniqueIndex"`
+	Discount  Money     `json:"discount"`
+	Currency  string    `json:"currency" gorm:"size:3"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CartCoupon is the coupon a user applied to their cart.
+type CartCoupon struct {
+	UserID    uint `gorm:"primarykey;autoIncrement:false"`
+	CouponID  uint
+	Coupon    Coupon
+	CreatedAt time.Time
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GameKey is one activation key of a digital product. A key is available
+// until checkout assigns it to an order item. Keys of an order that was
+// cancelled before payment go back to the pool; keys of a paid order may
+// already have been seen by the customer, so they are revoked instead.
+type GameKey struct {
+	gorm.Model
+	ProductID   uint       `json:"product_id" gorm:"uniqueIndex:idx_game_keys_product_code"`
+	Code        string     `json:"code" gorm:"size:255;uniqueIndex:idx_game_keys_product_code"`
+	OrderItemID *uint      `json:"-" gorm:"index"`
+	AssignedAt  *time.Time `json:"-"`
+	RevokedAt   *time.Time `json:"-"`
+}
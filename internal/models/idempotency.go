@@ -0,0 +1,22 @@
+package models
+
+import "gorm.io/gorm"
+
+// IdempotencyKey records a client-supplied Idempotency-Key together with a
+// fingerprint of the request it was first used for and, once that request
+// succeeded, the response to replay for repeats.
+type IdempotencyKey struct {
+	gorm.Model
+	UserID      uint   `json:"user_id" gorm:"uniqueIndex:idx_idempotency_user_key"`
+	Key         string `json:"key" gorm:"size:255;uniqueIndex:idx_idempotency_user_key"`
+	Fingerprint string `json:"fingerprint"`
+	OrderID     *uint  `json:"order_id"`
+	StatusCode  int    `json:"status_code"`
+	Response    string `json:"response"`
+}
+
+// Completed reports whether the request behind the key finished and its
+// response was stored.
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
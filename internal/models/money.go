@@ -0,0 +1,50 @@
+package models
+
+import "fmt"
+
+// DefaultCurrency is the ISO-4217 code prices are stored in unless stated otherwise.
+const DefaultCurrency = "USD"
+
+// Money is an amount in the minor unit of its currency: cents for USD,
+// pence for GBP, yen for JPY. The currency itself lives next to the amount
+// on the owning model. Keeping money as an integer means totals never
+// drift the way floating point amounts do.
+type Money int64
+
+// currencyExponents lists the supported ISO-4217 currencies and how many
+// decimal places their minor unit has.
+var currencyExponents = map[string]int{
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"JPY": 0,
+}
+
+// CurrencyExponent reports the number of decimal places of a currency's
+// minor unit, and whether the currency is supported at all.
+func CurrencyExponent(currency string) (int, bool) {
+	exponent, ok := currencyExponents[currency]
+	return exponent, ok
+}
+
+// Format renders an amount in currency as a plain decimal, e.g. 5999 USD as
+// "59.99" and 1500 JPY as "1500".
+func (m Money) Format(currency string) string {
+	exponent, _ := CurrencyExponent(currency)
+
+	sign := ""
+	amount := int64(m)
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	if exponent == 0 {
+		return fmt.Sprintf("%s%d", sign, amount)
+	}
+
+	unit := int64(1)
+	for i := 0; i < exponent; i++ {
+		unit *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/unit, exponent, amount%unit)
+}
@@ -1,13 +1,61 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusPaid      = "paid"
+	OrderStatusFulfilled = "fulfilled"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusRefunded  = "refunded"
+)
+
+const (
+	ActorSystem = "system"
+	ActorUser   = "user"
+	ActorAdmin  = "admin"
+)
 
 type Order struct {
 	gorm.Model
-	UserID     uint
-	TotalCents int         `json:"total_cents"`
-	Status     string      `json:"status"`
-	Items      []OrderItem `json:"items"`
+	UserID       uint    `json:"user_id"`
+	TotalCents   Money   `json:"total_cents"`
+	Currency     string  `json:"currency" gorm:"size:3;default:'USD'"`
+	BaseCurrency string  `json:"base_currency" gorm:"size:3;default:'USD'"`
+	ExchangeRate float64 `json:"exchange_rate" gorm:"default:1"`
+	Status       string  `json:"status" gorm:"index"`
+	// SubtotalCents is the price of the items before DiscountCents was taken
+	// off for CouponCode and TaxCents added; TotalCents is what was charged.
+	SubtotalCents Money  `json:"subtotal_cents"`
+	DiscountCents Money  `json:"discount_cents"`
+	TaxCents      Money  `json:"tax_cents"`
+	CouponCode    string `json:"coupon_code,omitempty"`
+	// Carrier and TrackingNumber are set when the order ships.
+	Carrier        string               `json:"carrier,omitempty"`
+	TrackingNumber string               `json:"tracking_number,omitempty"`
+	Items          []OrderItem          `json:"items"`
+	Payments       []Payment            `json:"payments,omitempty"`
+	StatusHistory  []OrderStatusHistory `json:"status_history,omitempty"`
+}
+
+// OrderStatusHistory records one status change of an order and who made it.
+// ActorID is nil for changes the system made on its own, such as cancelling
+// an order whose payment was declined.
+type OrderStatusHistory struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	OrderID    uint      `json:"order_id" gorm:"index"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ActorID    *uint     `json:"actor_id"`
+	ActorRole  string    `json:"actor_role"`
+	Note       string    `json:"note,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 type OrderItem struct {
@@ -16,5 +64,7 @@ type OrderItem struct {
 	ProductID uint    `json:"product_id"`
 	Product   Product `json:"product"`
 	Quantity  int     `json:"quantity"`
-	Price     int     `json:"price"`
+	Price     Money   `json:"price"`
+	// Keys is only loaded for the customer's own order details.
+	Keys []GameKey `json:"keys,omitempty"`
 }
@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+const (
+	EventUserRegistered = "user.registered"
+	EventOrderPaid      = "order.paid"
+	EventOrderFulfilled = "order.fulfilled"
+	EventOrderShipped   = "order.shipped"
+	EventOrderDelivered = "order.delivered"
+	EventOrderCancelled = "order.cancelled"
+	EventOrderRefunded  = "order.refunded"
+	EventStockChanged   = "product.stock_changed"
+	EventPriceChanged   = "product.price_changed"
+	EventPaymentPending = "payment.pending"
+)
+
+// OrderStatusEvent is the event recorded when an order moves to status.
+func OrderStatusEvent(status string) string {
+	return "order." + status
+}
+
+// OutboxEvent is a domain event written in the same transaction as the change
+// it describes. The outbox relay publishes it afterwards, so an event exists
+// if and only if its change was committed.
+type OutboxEvent struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Type        string    `gorm:"size:100;index" json:"type"`
+	AggregateID uint      `json:"aggregate_id"`
+	Payload     string    `gorm:"type:text" json:"payload"`
+	CreatedAt   time.Time `json:"created_at"`
+	// PublishedAt is nil until the relay has published the event.
+	PublishedAt *time.Time `gorm:"index" json:"published_at"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// UserRegisteredEvent is the payload of EventUserRegistered.
+type UserRegisteredEvent struct {
+	UserID uint   `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// OrderEvent is the payload of the order events. PreviousStatus tells a
+// cancelled order that was paid apart from one whose payment never went
+// through.
+type OrderEvent struct {
+	OrderID        uint   `json:"order_id"`
+	UserID         uint   `json:"user_id"`
+	Email          string `json:"email"`
+	Total          Money  `json:"total"`
+	Currency       string `json:"currency"`
+	Status         string `json:"status"`
+	PreviousStatus string `json:"previous_status"`
+	Carrier        string `json:"carrier,omitempty"`
+	TrackingNumber string `json:"tracking_number,omitempty"`
+}
+
+// StockChangedEvent is the payload of EventStockChanged.
+type StockChangedEvent struct {
+	ProductID     uint `json:"product_id"`
+	PreviousStock int  `json:"previous_stock"`
+	Stock         int  `json:"stock"`
+}
+
+// PriceChangedEvent is the payload of EventPriceChanged. The currency can
+// change along with the price.
+type PriceChangedEvent struct {
+	ProductID        uint   `json:"product_id"`
+	PreviousPrice    Money  `json:"previous_price"`
+	PreviousCurrency string `json:"previous_currency"`
+	Price            Money  `json:"price"`
+	Currency         string `json:"currency"`
+}
+
+// PaymentPendingEvent is the payload of EventPaymentPending, recorded when a
+// payment is left for the event workers to settle with the payment service.
+type PaymentPendingEvent struct {
+	PaymentID uint `json:"payment_id"`
+	OrderID   uint `json:"order_id"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	PaymentStatusCaptured          = "captured"
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+	PaymentStatusRefunded          = "refunded"
+	PaymentStatusVoided            = "voided"
+	PaymentStatusDeclined          = "declined"
+	PaymentStatusFailed            = "failed"
+	// PaymentStatusRefundPending is a charge owed back to the customer that
+	// the payment service hasn't refunded yet.
+	PaymentStatusRefundPending = "refund_pending"
+	// PaymentStatusVoidPending is a charge of a cancelled order that the
+	// payment service hasn't voided yet.
+	PaymentStatusVoidPending = "void_pending"
+)
+
+// Payment is one charge attempt against an order, as reported by the payment
+// service. Declined and failed attempts have no transaction ID.
+type Payment struct {
+	gorm.Model
+	OrderID         uint    `json:"order_id" gorm:"index"`
+	TransactionID   *string `json:"transaction_id" gorm:"uniqueIndex"`
+	Amount          Money   `json:"amount"`
+	Refunded        Money   `json:"refunded"`
+	Currency        string  `json:"currency" gorm:"size:3"`
+	Status          string  `json:"status"`
+	ProviderMessage string  `json:"provider_message"`
+}
@@ -1,14 +1,40 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// Product is an item in the catalogue. The stock of a Digital product is the
+// number of its GameKeys that are still available, so it can't be set by hand.
+// Products are sorted into Categories (genres), Platforms and Publishers,
+// and shown with their Images, the cover first.
 type Product struct {
 	gorm.Model
 	Name        string `json:"name"`
 	Description string `json:"description"`
-	Price       int    `json:"price"`
-	SKU         string `json:"sku" gorm:"unique"`
-	Stock       int    `json:"stock"`
+	Price       Money  `json:"price"`
+	Currency    string `json:"currency" gorm:"size:3;default:'USD'"`
+	// BasePrice is Price in the base currency of the exchange rates, so
+	// products priced in different currencies can be filtered and sorted
+	// together. It is set whenever the price changes.
+	BasePrice   Money          `json:"-" gorm:"index"`
+	SKU         string         `json:"sku" gorm:"unique"`
+	Stock       int            `json:"stock"`
+	Digital     bool           `json:"digital"`
+	Developer   string         `json:"developer"`
+	ReleaseDate *time.Time     `json:"release_date"`
+	AgeRating   string         `json:"age_rating" gorm:"size:16"`
+	Categories  []Category     `json:"categories,omitempty" gorm:"many2many:product_categories"`
+	Platforms   []Platform     `json:"platforms,omitempty" gorm:"many2many:product_platforms"`
+	Publishers  []Publisher    `json:"publishers,omitempty" gorm:"many2many:product_publishers"`
+	Images      []ProductImage `json:"images,omitempty"`
+	// RatingAverage and ReviewCount sum up the product's visible reviews.
+	// They are kept up to date whenever a review changes.
+	RatingAverage float64 `json:"rating_average"`
+	ReviewCount   int     `json:"review_count"`
+	// Available is Stock less what other customers hold in their carts. It
+	// is worked out when products are read and never stored.
+	Available int `json:"available" gorm:"-"`
 }
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+const (
+	ImageKindCover      = "cover"
+	ImageKindScreenshot = "screenshot"
+)
+
+// ProductImage is the cover art or a screenshot of a product. A product has
+// at most one cover, at position 0; screenshots follow in upload order. The
+// files live in storage under Key and ThumbnailKey, and their URLs are
+// worked out from the keys when the image is read.
+type ProductImage struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	ProductID    uint      `json:"product_id" gorm:"index"`
+	Kind         string    `json:"kind" gorm:"size:16"`
+	Position     int       `json:"position"`
+	Key          string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	ContentType  string    `json:"content_type" gorm:"size:32"`
+	Size         int       `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	URL          string `json:"url" gorm:"-"`
+	ThumbnailURL string `json:"thumbnail_url" gorm:"-"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// StockReservation holds stock for a cart line until ExpiresAt. Reserved
+// stock is still counted in Product.Stock; it only stops other customers
+// from putting it in their carts. A user has at most one reservation per
+// product.
+type StockReservation struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_stock_reservations_user_product"`
+	ProductID uint      `json:"product_id" gorm:"uniqueIndex:idx_stock_reservations_user_product;index"`
+	Quantity  int       `json:"quantity"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
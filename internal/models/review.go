@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Review is a customer's 1 to 5 star rating of a product they bought, with an
+// optional comment. A user reviews a product at most once. Admins hide
+// reviews instead of deleting them; hidden reviews are left out of listings
+// and of the product's rating.
+type Review struct {
+	ID           uint       `json:"id" gorm:"primarykey"`
+	ProductID    uint       `json:"product_id" gorm:"uniqueIndex:idx_reviews_product_user"`
+	UserID       uint       `json:"user_id" gorm:"uniqueIndex:idx_reviews_product_user;index"`
+	Rating       int        `json:"rating"`
+	Comment      string     `json:"comment"`
+	HiddenAt     *time.Time `json:"hidden_at,omitempty"`
+	HiddenReason string     `json:"hidden_reason,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
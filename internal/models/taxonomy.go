@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Term is an entry of one of the catalogue's taxonomies. Products are
+// filtered by its Slug, which is unique within the taxonomy.
+type Term struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	Name      string    `json:"name" gorm:"size:100;not null"`
+	Slug      string    `json:"slug" gorm:"size:100;uniqueIndex;not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Category is a genre such as RPG or Platformer.
+type Category struct {
+	Term
+}
+
+// Platform is a system a game runs on, such as PC, PS5 or Switch.
+type Platform struct {
+	Term
+}
+
+// Publisher is a company that publishes games.
+type Publisher struct {
+	Term
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is a long-lived credential that can be exchanged once for a new
+// access token. Only a hash of the token is stored. Rotating a token revokes
+// it and points it at its replacement.
+type RefreshToken struct {
+	gorm.Model
+	UserID       uint       `json:"user_id" gorm:"index"`
+	TokenHash    string     `json:"-" gorm:"size:64;uniqueIndex"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uint      `json:"replaced_by_id"`
+}
+
+// RevokedToken is the database fallback for the access token denylist kept in
+// Redis. Key is either "jti:<token id>" for a single token or "user:<user id>"
+// for every token of a user issued before IssuedBefore.
+type RevokedToken struct {
+	ID           uint   `gorm:"primarykey"`
+	Key          string `gorm:"size:100;uniqueIndex"`
+	IssuedBefore time.Time
+	ExpiresAt    time.Time `gorm:"index"`
+}
+
+const (
+	ActionVerifyEmail   = "verify_email"
+	ActionPasswordReset = "password_reset"
+)
+
+// ActionToken tracks a signed, single-use token emailed to a user to verify
+// their address or reset their password. The token itself is a JWT; only its
+// ID is stored here, so it can be used once.
+type ActionToken struct {
+	gorm.Model
+	UserID    uint       `json:"user_id" gorm:"index"`
+	Purpose   string     `json:"purpose" gorm:"size:32;index"`
+	TokenID   string     `json:"-" gorm:"size:64;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
@@ -1,12 +1,20 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type User struct {
 	gorm.Model
-	Email    string `json:"email" gorm:"unique"`
-	Password string `json:"password"`
-	Role     string `json:"role" gorm:"default:'user'"`
+	Email           string     `json:"email" gorm:"unique"`
+	Password        string     `json:"password"`
+	Role            string     `json:"role" gorm:"default:'user'"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+}
+
+// EmailVerified reports whether the user has confirmed their email address.
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// WishlistItem is a product a user wants. Users are emailed when a product
+// on their wishlist comes back in stock or gets cheaper.
+type WishlistItem struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_wishlist_items_user_product"`
+	ProductID uint      `json:"product_id" gorm:"uniqueIndex:idx_wishlist_items_user_product;index"`
+	Product   Product   `json:"product"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WishlistAlert records that a user was emailed about an outbox event, so an
+// event that is handled twice emails nobody twice.
+type WishlistAlert struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	EventID   uint      `json:"event_id" gorm:"uniqueIndex:idx_wishlist_alerts_event_user"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_wishlist_alerts_event_user"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,95 @@
+package pricing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"game-store-api/internal/models"
+	"math"
+	"os"
+)
+
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// ExchangeRates converts money between currencies through a base currency.
+// Rates[X] is how many units of X one unit of Base buys.
+type ExchangeRates struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+func NewExchangeRates(base string, rates map[string]float64) *ExchangeRates {
+	table := &ExchangeRates{Base: base, Rates: make(map[string]float64, len(rates)+1)}
+	for currency, rate := range rates {
+		table.Rates[currency] = rate
+	}
+	table.Rates[base] = 1
+	return table
+}
+
+// LoadExchangeRates reads a rate table from a JSON file such as config/exchange_rates.json.
+func LoadExchangeRates(path string) (*ExchangeRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var table ExchangeRates
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("invalid exchange rate file %s: %w", path, err)
+	}
+	if _, ok := models.CurrencyExponent(table.Base); !ok {
+		return nil, fmt.Errorf("invalid exchange rate file %s: unknown base currency %q", path, table.Base)
+	}
+	for currency, rate := range table.Rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate file %s: rate for %s must be positive", path, currency)
+		}
+	}
+	return NewExchangeRates(table.Base, table.Rates), nil
+}
+
+// Supports reports whether money can be priced in the given currency.
+func (r *ExchangeRates) Supports(currency string) bool {
+	_, known := models.CurrencyExponent(currency)
+	_, hasRate := r.Rates[currency]
+	return known && hasRate
+}
+
+// Rate returns how many units of to one unit of from buys.
+func (r *ExchangeRates) Rate(from, to string) (float64, error) {
+	if !r.Supports(from) || !r.Supports(to) {
+		return 0, ErrUnsupportedCurrency
+	}
+	return r.Rates[to] / r.Rates[from], nil
+}
+
+// Convert turns an amount in from's minor unit into to's minor unit, rounding
+// half away from zero.
+func (r *ExchangeRates) Convert(amount models.Money, from, to string) (models.Money, error) {
+	if from == to {
+		return amount, nil
+	}
+
+	rate, err := r.Rate(from, to)
+	if err != nil {
+		return 0, err
+	}
+	fromExponent, _ := models.CurrencyExponent(from)
+	toExponent, _ := models.CurrencyExponent(to)
+
+	scale := math.Pow10(toExponent - fromExponent)
+	return models.Money(math.Round(float64(amount) * rate * scale)), nil
+}
+
+// ConvertProduct rewrites a product's price into currency for display.
+// The product must not be saved afterwards.
+func (r *ExchangeRates) ConvertProduct(product *models.Product, currency string) error {
+	price, err := r.Convert(product.Price, product.Currency, currency)
+	if err != nil {
+		return err
+	}
+	product.Price = price
+	product.Currency = currency
+	return nil
+}
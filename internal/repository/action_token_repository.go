@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"game-store-api/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ActionTokenRepository interface {
+	CreateActionToken(token *models.ActionToken) error
+	GetActionTokenByTokenID(tokenID string) (*models.ActionToken, error)
+	UseActionToken(tx *gorm.DB, id uint) (bool, error)
+	CountRecentActionTokens(userID uint, purpose string, since time.Time) (int64, error)
+}
+
+type actionTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewActionTokenRepository(db *gorm.DB) ActionTokenRepository {
+	return &actionTokenRepository{db: db}
+}
+
+func (r *actionTokenRepository) CreateActionToken(token *models.ActionToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *actionTokenRepository) GetActionTokenByTokenID(tokenID string) (*models.ActionToken, error) {
+	var token models.ActionToken
+	err := r.db.Where("token_id = ?", tokenID).First(&token).Error
+	return &token, err
+}
+
+// UseActionToken marks a token used unless it already was, and reports
+// whether this call used it.
+func (r *actionTokenRepository) UseActionToken(tx *gorm.DB, id uint) (bool, error) {
+	result := tx.Model(&models.ActionToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *actionTokenRepository) CountRecentActionTokens(userID uint, purpose string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.ActionToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at > ?", userID, purpose, since).
+		Count(&count).Error
+	return count, err
+}
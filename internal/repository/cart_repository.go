@@ -4,13 +4,23 @@ import (
 	"game-store-api/internal/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CartRepository interface {
-	AddItem(item *models.CartItem) error
+	// GetItem finds the user's cart line for a product, or returns a new,
+	// unsaved one with no quantity.
+	GetItem(tx *gorm.DB, userID, productID uint) (*models.CartItem, error)
+	SaveItem(tx *gorm.DB, item *models.CartItem) error
+	DeleteItem(tx *gorm.DB, item *models.CartItem) error
 	GetCartByUserID(userID uint) ([]models.CartItem, error)
 	RemoveItem(userID, productID uint) error
+	// ClearCart empties the cart and drops its coupon.
 	ClearCart(tx *gorm.DB, userID uint) error
+	SetCoupon(userID, couponID uint) error
+	// GetCoupon returns the coupon applied to the cart, or nil.
+	GetCoupon(userID uint) (*models.Coupon, error)
+	RemoveCoupon(userID uint) error
 }
 
 type cartRepository struct {
@@ -21,26 +31,31 @@ func NewCartRepository(db *gorm.DB) CartRepository {
 	return &cartRepository{db: db}
 }
 
-func (r *cartRepository) AddItem(item *models.CartItem) error {
-	// If item exists update quantity else create
-	var existingItem models.CartItem
-	err := r.db.Where("user_id = ? AND product_id = ?", item.UserID, item.ProductID).First(&existingItem).Error
-	if err == nil {
-		existingItem.Quantity += item.Quantity
-		if existingItem.Quantity <= 0 {
-			return r.db.Delete(&existingItem).Error
-		}
-		return r.db.Save(&existingItem).Error
-	}
-	if item.Quantity <= 0 {
-		return nil
+func (r *cartRepository) GetItem(tx *gorm.DB, userID, productID uint) (*models.CartItem, error) {
+	var item models.CartItem
+	err := tx.Where("user_id = ? AND product_id = ?", userID, productID).Limit(1).Find(&item).Error
+	if item.ID == 0 {
+		item.UserID = userID
+		item.ProductID = productID
 	}
-	return r.db.Create(item).Error
+	return &item, err
+}
+
+func (r *cartRepository) SaveItem(tx *gorm.DB, item *models.CartItem) error {
+	return tx.Save(item).Error
 }
 
+func (r *cartRepository) DeleteItem(tx *gorm.DB, item *models.CartItem) error {
+	return tx.Delete(item).Error
+}
+
+// GetCartByUserID loads the cart with its products and their categories,
+// including deleted products so the cart can still name them.
 func (r *cartRepository) GetCartByUserID(userID uint) ([]models.CartItem, error) {
 	var CartItems []models.CartItem
-	err := r.db.Preload("Product").Where("user_id = ?", userID).Find(&CartItems).Error
+	err := r.db.Preload("Product", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).Preload("Product.Categories").Where("user_id = ?", userID).Order("id").Find(&CartItems).Error
 	return CartItems, err
 }
 
@@ -54,5 +69,29 @@ func (r *cartRepository) RemoveItem(userID, productID uint) error {
 }
 
 func (r *cartRepository) ClearCart(tx *gorm.DB, userID uint) error {
-	return tx.Where("user_id = ?", userID).Delete(&models.CartItem{}).Error
+	if err := tx.Where("user_id = ?", userID).Delete(&models.CartItem{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.CartCoupon{}).Error
+}
+
+func (r *cartRepository) SetCoupon(userID, couponID uint) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"coupon_id", "created_at"}),
+	}).Create(&models.CartCoupon{UserID: userID, CouponID: couponID}).Error
+}
+
+func (r *cartRepository) GetCoupon(userID uint) (*models.Coupon, error) {
+	var applied models.CartCoupon
+	err := r.db.Preload("Coupon.Products").Preload("Coupon.Categories").Where("user_id = ?", userID).Limit(1).Find(&applied).Error
+	if err != nil || applied.UserID == 0 || applied.Coupon.ID == 0 {
+		// A deleted coupon no longer applies
+		return nil, err
+	}
+	return &applied.Coupon, nil
+}
+
+func (r *cartRepository) RemoveCoupon(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&models.CartCoupon{}).Error
 }
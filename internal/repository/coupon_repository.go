@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"game-store-api/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CouponRepository interface {
+	CreateCoupon(coupon *models.Coupon) error
+	ListCoupons(limit, offset int) ([]models.Coupon, int64, error)
+	GetCouponByCode(code string) (*models.Coupon, error)
+	GetCouponByIDForUpdate(tx *gorm.DB, id uint) (*models.Coupon, error)
+	DeleteCoupon(id uint) (bool, error)
+	// CountRedemptions counts the uses of a coupon by one user.
+	CountRedemptions(tx *gorm.DB, couponID, userID uint) (int64, error)
+	AddRedemption(tx *gorm.DB, redemption *models.CouponRedemption) error
+	// RemoveRedemption deletes the redemption of an order, if it has one,
+	// and gives the use back to its coupon.
+	RemoveRedemption(tx *gorm.DB, orderID uint) error
+}
+
+type couponRepository struct {
+	db *gorm.DB
+}
+
+func NewCouponRepository(db *gorm.DB) CouponRepository {
+	return &couponRepository{db: db}
+}
+
+func (r *couponRepository) CreateCoupon(coupon *models.Coupon) error {
+	// The scoped products and categories already exist; only link them
+	return r.db.Omit("Products.*", "Categories.*").Create(coupon).Error
+}
+
+func (r *couponRepository) ListCoupons(limit, offset int) ([]models.Coupon, int64, error) {
+	var coupons []models.Coupon
+	var total int64
+
+	if err := r.db.Model(&models.Coupon{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := r.db.Preload("Products").Preload("Categories").Order("id DESC").Limit(limit).Offset(offset).Find(&coupons).Error
+	return coupons, total, err
+}
+
+func (r *couponRepository) GetCouponByCode(code string) (*models.Coupon, error) {
+	var coupon models.Coupon
+	err := r.db.Preload("Products").Preload("Categories").Where("code = ?", code).First(&coupon).Error
+	return &coupon, err
+}
+
+// GetCouponByIDForUpdate locks the coupon row so its usage can be counted
+// without racing other checkouts.
+func (r *couponRepository) GetCouponByIDForUpdate(tx *gorm.DB, id uint) (*models.Coupon, error) {
+	var coupon models.Coupon
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&coupon, id).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&coupon).Association("Products").Find(&coupon.Products); err != nil {
+		return nil, err
+	}
+	err = tx.Model(&coupon).Association("Categories").Find(&coupon.Categories)
+	return &coupon, err
+}
+
+func (r *couponRepository) DeleteCoupon(id uint) (bool, error) {
+	result := r.db.Delete(&models.Coupon{}, id)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *couponRepository) CountRedemptions(tx *gorm.DB, couponID, userID uint) (int64, error) {
+	var count int64
+	err := tx.Model(&models.CouponRedemption{}).
+		Where("coupon_id = ? AND user_id = ?", couponID, userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *couponRepository) AddRedemption(tx *gorm.DB, redemption *models.CouponRedemption) error {
+	if err := tx.Create(redemption).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Coupon{}).Where("id = ?", redemption.CouponID).
+		Update("uses", gorm.Expr("uses + 1")).Error
+}
+
+func (r *couponRepository) RemoveRedemption(tx *gorm.DB, orderID uint) error {
+	var redemption models.CouponRedemption
+	err := tx.Where("order_id = ?", orderID).Limit(1).Find(&redemption).Error
+	if err != nil || redemption.ID == 0 {
+		return err
+	}
+
+	if err := tx.Delete(&redemption).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Coupon{}).Unscoped().Where("id = ?", redemption.CouponID).
+		Update("uses", gorm.Expr("uses - 1")).Error
+}
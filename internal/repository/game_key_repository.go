@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"game-store-api/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GameKeyRepository manages the key inventory of digital products. Callers
+// hold the product's row lock (GetProductByIDForUpdate) while changing its
+// keys, which keeps two checkouts from taking the same key.
+type GameKeyRepository interface {
+	// AddKeys stores new keys, skipping codes the product already has, and
+	// reports how many were added.
+	AddKeys(tx *gorm.DB, keys []models.GameKey) (int64, error)
+	CountAvailableKeys(tx *gorm.DB, productID uint) (int64, error)
+	// AssignKeys gives up to quantity available keys of a product to an
+	// order item and reports how many it got.
+	AssignKeys(tx *gorm.DB, productID, orderItemID uint, quantity int) (int64, error)
+	// ReleaseKeys returns an order item's keys to the pool.
+	ReleaseKeys(tx *gorm.DB, orderItemID uint) error
+	// RevokeKeys invalidates an order item's keys for good.
+	RevokeKeys(tx *gorm.DB, orderItemID uint) error
+}
+
+type gameKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewGameKeyRepository(db *gorm.DB) GameKeyRepository {
+	return &gameKeyRepository{db: db}
+}
+
+func (r *gameKeyRepository) AddKeys(tx *gorm.DB, keys []models.GameKey) (int64, error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(keys, 500)
+	return result.RowsAffected, result.Error
+}
+
+func availableKeys(tx *gorm.DB, productID uint) *gorm.DB {
+	return tx.Model(&models.GameKey{}).
+		Where("product_id = ? AND order_item_id IS NULL AND revoked_at IS NULL", productID)
+}
+
+func (r *gameKeyRepository) CountAvailableKeys(tx *gorm.DB, productID uint) (int64, error) {
+	var count int64
+	err := availableKeys(tx, productID).Count(&count).Error
+	return count, err
+}
+
+func (r *gameKeyRepository) AssignKeys(tx *gorm.DB, productID, orderItemID uint, quantity int) (int64, error) {
+	var ids []uint
+	if err := availableKeys(tx, productID).Order("id").Limit(quantity).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result := tx.Model(&models.GameKey{}).
+		Where("id IN ? AND order_item_id IS NULL", ids).
+		Updates(map[string]interface{}{"order_item_id": orderItemID, "assigned_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
+
+func (r *gameKeyRepository) ReleaseKeys(tx *gorm.DB, orderItemID uint) error {
+	return tx.Model(&models.GameKey{}).
+		Where("order_item_id = ? AND revoked_at IS NULL", orderItemID).
+		Updates(map[string]interface{}{"order_item_id": nil, "assigned_at": nil}).Error
+}
+
+func (r *gameKeyRepository) RevokeKeys(tx *gorm.DB, orderItemID uint) error {
+	return tx.Model(&models.GameKey{}).
+		Where("order_item_id = ? AND revoked_at IS NULL", orderItemID).
+		Update("revoked_at", time.Now()).Error
+}
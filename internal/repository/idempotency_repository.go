@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"game-store-api/internal/models"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// IdempotencyRepository stores idempotency keys. Completed keys expire after
+// the TTL given to the constructor, and keys whose request never completed
+// after the lease, after which they can be claimed again.
+type IdempotencyRepository interface {
+	// Claim stores record unless its key is already taken, in which case the
+	// existing record is returned with false.
+	Claim(record *models.IdempotencyKey) (*models.IdempotencyKey, bool, error)
+	// Get returns the record stored under key, or nil when there is none.
+	Get(userID uint, key string) (*models.IdempotencyKey, error)
+	Complete(record *models.IdempotencyKey) error
+	Release(userID uint, key string) error
+}
+
+// NewIdempotencyRepository keeps keys in Redis when a client is available and
+// in the database otherwise. The lease bounds how long a request that crashed
+// holds its key.
+func NewIdempotencyRepository(db *gorm.DB, redisClient *redis.Client, ttl, lease time.Duration) IdempotencyRepository {
+	if redisClient != nil {
+		return &redisIdempotencyRepository{client: redisClient, ttl: ttl, lease: lease}
+	}
+	return &dbIdempotencyRepository{db: db, ttl: ttl, lease: lease}
+}
+
+type dbIdempotencyRepository struct {
+	db    *gorm.DB
+	ttl   time.Duration
+	lease time.Duration
+}
+
+func (r *dbIdempotencyRepository) Claim(record *models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+	err := r.db.Create(record).Error
+	if err == nil {
+		return record, true, nil
+	}
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, false, err
+	}
+
+	var existing models.IdempotencyKey
+	err = r.db.Where("user_id = ? AND key = ?", record.UserID, record.Key).First(&existing).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if !r.expired(&existing) {
+		return &existing, false, nil
+	}
+
+	// The old key has expired, or its request never completed, so it's free
+	// to be claimed again.
+	if err := r.db.Unscoped().Delete(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	record.ID = 0
+	if err := r.db.Create(record).Error; err != nil {
+		return nil, false, err
+	}
+	return record, true, nil
+}
+
+// expired reports whether key can be claimed again.
+func (r *dbIdempotencyRepository) expired(key *models.IdempotencyKey) bool {
+	if key.Completed() {
+		return time.Since(key.CreatedAt) >= r.ttl
+	}
+	return time.Since(key.CreatedAt) >= r.lease
+}
+
+func (r *dbIdempotencyRepository) Get(userID uint, key string) (*models.IdempotencyKey, error) {
+	var record models.IdempotencyKey
+	err := r.db.Where("user_id = ? AND key = ?", userID, key).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.expired(&record) {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+func (r *dbIdempotencyRepository) Complete(record *models.IdempotencyKey) error {
+	return r.db.Save(record).Error
+}
+
+func (r *dbIdempotencyRepository) Release(userID uint, key string) error {
+	return r.db.Unscoped().Where("user_id = ? AND key = ?", userID, key).Delete(&models.IdempotencyKey{}).Error
+}
+
+type redisIdempotencyRepository struct {
+	client *redis.Client
+	ttl    time.Duration
+	lease  time.Duration
+}
+
+func idempotencyRedisKey(userID uint, key string) string {
+	return fmt.Sprintf("idempotency:%d:%s", userID, key)
+}
+
+func (r *redisIdempotencyRepository) Claim(record *models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+	ctx := context.Background()
+	redisKey := idempotencyRedisKey(record.UserID, record.Key)
+
+	record.CreatedAt = time.Now()
+	body, err := json.Marshal(record)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// The claim lives for the lease until Complete keeps it for the TTL
+	claimed, err := r.client.SetNX(ctx, redisKey, body, r.lease).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if claimed {
+		return record, true, nil
+	}
+
+	stored, err := r.client.Get(ctx, redisKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// Expired between SETNX and GET; try once more.
+		return r.Claim(record)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var existing models.IdempotencyKey
+	if err := json.Unmarshal(stored, &existing); err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+func (r *redisIdempotencyRepository) Get(userID uint, key string) (*models.IdempotencyKey, error) {
+	stored, err := r.client.Get(context.Background(), idempotencyRedisKey(userID, key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record models.IdempotencyKey
+	if err := json.Unmarshal(stored, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *redisIdempotencyRepository) Complete(record *models.IdempotencyKey) error {
+	record.UpdatedAt = time.Now()
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(context.Background(), idempotencyRedisKey(record.UserID, record.Key), body, r.ttl).Err()
+}
+
+func (r *redisIdempotencyRepository) Release(userID uint, key string) error {
+	return r.client.Del(context.Background(), idempotencyRedisKey(userID, key)).Err()
+}
@@ -2,12 +2,34 @@ package repository
 
 import (
 	"game-store-api/internal/models"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 type OrderRepository interface {
 	CreateOrder(tx *gorm.DB, order *models.Order) error
+	// UpdateOrderStatus moves an order from one status to another. It reports
+	// false when the order was no longer in the from status.
+	UpdateOrderStatus(tx *gorm.DB, orderID uint, from, to string) (bool, error)
+	UpdateOrderShipping(tx *gorm.DB, orderID uint, carrier, trackingNumber string) error
+	AddStatusHistory(tx *gorm.DB, entry *models.OrderStatusHistory) error
+	GetOrderByID(orderID uint) (*models.Order, error)
+	GetOrderDetails(orderID uint) (*models.Order, error)
+	ListOrders(filter OrderFilter) ([]models.Order, int64, error)
+	GetOrdersByUserID(userID uint, limit, offset int) ([]models.Order, int64, error)
+	GetOrderByUserID(userID, orderID uint) (*models.Order, error)
+}
+
+// OrderFilter narrows and pages the admin order listing. Zero values don't
+// filter.
+type OrderFilter struct {
+	Status        string
+	UserID        uint
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Limit         int
+	Offset        int
 }
 
 type orderRepository struct {
@@ -21,3 +43,98 @@ func NewOrderRepository(db *gorm.DB) OrderRepository {
 func (r *orderRepository) CreateOrder(tx *gorm.DB, order *models.Order) error {
 	return tx.Create(order).Error
 }
+
+func (r *orderRepository) UpdateOrderStatus(tx *gorm.DB, orderID uint, from, to string) (bool, error) {
+	result := tx.Model(&models.Order{}).Where("id = ? AND status = ?", orderID, from).Update("status", to)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *orderRepository) UpdateOrderShipping(tx *gorm.DB, orderID uint, carrier, trackingNumber string) error {
+	return tx.Model(&models.Order{}).Where("id = ?", orderID).
+		Updates(map[string]interface{}{"carrier": carrier, "tracking_number": trackingNumber}).Error
+}
+
+func (r *orderRepository) AddStatusHistory(tx *gorm.DB, entry *models.OrderStatusHistory) error {
+	return tx.Create(entry).Error
+}
+
+func (r *orderRepository) GetOrderByID(orderID uint) (*models.Order, error) {
+	var order models.Order
+	err := r.db.Preload("Items").First(&order, orderID).Error
+	return &order, err
+}
+
+// GetOrderDetails loads an order with its items, payments and status history.
+func (r *orderRepository) GetOrderDetails(orderID uint) (*models.Order, error) {
+	var order models.Order
+	err := r.db.Preload("Items.Product", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).
+		Preload("Payments").
+		Preload("StatusHistory", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		}).
+		First(&order, orderID).Error
+	return &order, err
+}
+
+func (r *orderRepository) ListOrders(filter OrderFilter) ([]models.Order, int64, error) {
+	query := r.db.Model(&models.Order{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *filter.CreatedBefore)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var orders []models.Order
+	err := query.Preload("Items").
+		Order("created_at DESC, id DESC").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&orders).Error
+	return orders, total, err
+}
+
+func (r *orderRepository) GetOrdersByUserID(userID uint, limit, offset int) ([]models.Order, int64, error) {
+	var total int64
+	if err := r.db.Model(&models.Order{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var orders []models.Order
+	err := r.db.Preload("Items.Product", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&orders).Error
+	return orders, total, err
+}
+
+func (r *orderRepository) GetOrderByUserID(userID, orderID uint) (*models.Order, error) {
+	var order models.Order
+	err := r.db.Preload("Items.Product", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).
+		Preload("Items.Keys", "revoked_at IS NULL").
+		Preload("StatusHistory", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		}).
+		Where("user_id = ?", userID).
+		First(&order, orderID).Error
+	return &order, err
+}
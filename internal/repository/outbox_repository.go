@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"game-store-api/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OutboxRepository interface {
+	// AddEvent writes an event as part of tx.
+	AddEvent(tx *gorm.DB, event *models.OutboxEvent) error
+	// GetPendingEvents returns up to limit unpublished events, oldest first.
+	GetPendingEvents(limit int) ([]models.OutboxEvent, error)
+	MarkEventPublished(id uint, publishedAt time.Time) error
+	MarkEventFailed(id uint, reason string) error
+	// DeletePublishedEvents removes events published before the given time.
+	DeletePublishedEvents(before time.Time) (int64, error)
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) AddEvent(tx *gorm.DB, event *models.OutboxEvent) error {
+	return tx.Create(event).Error
+}
+
+func (r *outboxRepository) GetPendingEvents(limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := r.db.Where("published_at IS NULL").Order("id ASC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+func (r *outboxRepository) MarkEventPublished(id uint, publishedAt time.Time) error {
+	return r.db.Model(&models.OutboxEvent{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"published_at": publishedAt, "last_error": ""}).Error
+}
+
+func (r *outboxRepository) MarkEventFailed(id uint, reason string) error {
+	return r.db.Model(&models.OutboxEvent{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"attempts": gorm.Expr("attempts + 1"), "last_error": reason}).Error
+}
+
+func (r *outboxRepository) DeletePublishedEvents(before time.Time) (int64, error) {
+	result := r.db.Where("published_at < ?", before).Delete(&models.OutboxEvent{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"game-store-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type PaymentRepository interface {
+	CreatePayment(tx *gorm.DB, payment *models.Payment) error
+	UpdatePayment(tx *gorm.DB, payment *models.Payment) error
+	GetPaymentByID(id uint) (*models.Payment, error)
+	GetPaymentByTransactionID(transactionID string) (*models.Payment, error)
+	GetCapturedPaymentByOrderID(orderID uint) (*models.Payment, error)
+}
+
+type paymentRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentRepository(db *gorm.DB) PaymentRepository {
+	return &paymentRepository{db: db}
+}
+
+func (r *paymentRepository) CreatePayment(tx *gorm.DB, payment *models.Payment) error {
+	return tx.Create(payment).Error
+}
+
+func (r *paymentRepository) UpdatePayment(tx *gorm.DB, payment *models.Payment) error {
+	return tx.Save(payment).Error
+}
+
+func (r *paymentRepository) GetPaymentByID(id uint) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.First(&payment, id).Error
+	return &payment, err
+}
+
+func (r *paymentRepository) GetPaymentByTransactionID(transactionID string) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.Where("transaction_id = ?", transactionID).First(&payment).Error
+	return &payment, err
+}
+
+// GetCapturedPaymentByOrderID returns the order's successful charge, whatever
+// has happened to it since (refunds, void).
+func (r *paymentRepository) GetCapturedPaymentByOrderID(orderID uint) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.Where("order_id = ? AND transaction_id IS NOT NULL", orderID).
+		Order("id DESC").
+		First(&payment).Error
+	return &payment, err
+}
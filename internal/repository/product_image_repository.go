@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"game-store-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type ProductImageRepository interface {
+	GetImage(productID, imageID uint) (*models.ProductImage, error)
+	// GetCover returns the product's cover, or nil when it has none.
+	GetCover(tx *gorm.DB, productID uint) (*models.ProductImage, error)
+	CountScreenshots(tx *gorm.DB, productID uint) (int64, error)
+	// LastPosition returns the highest position of the product's images,
+	// or 0 when it has none.
+	LastPosition(tx *gorm.DB, productID uint) (int, error)
+	CreateImage(tx *gorm.DB, image *models.ProductImage) error
+	DeleteImage(tx *gorm.DB, image *models.ProductImage) error
+}
+
+type productImageRepository struct {
+	db *gorm.DB
+}
+
+func NewProductImageRepository(db *gorm.DB) ProductImageRepository {
+	return &productImageRepository{db: db}
+}
+
+func (r *productImageRepository) GetImage(productID, imageID uint) (*models.ProductImage, error) {
+	var image models.ProductImage
+	err := r.db.Where("product_id = ?", productID).First(&image, imageID).Error
+	return &image, err
+}
+
+func (r *productImageRepository) GetCover(tx *gorm.DB, productID uint) (*models.ProductImage, error) {
+	var images []models.ProductImage
+	err := tx.Where("product_id = ? AND kind = ?", productID, models.ImageKindCover).Limit(1).Find(&images).Error
+	if err != nil || len(images) == 0 {
+		return nil, err
+	}
+	return &images[0], nil
+}
+
+func (r *productImageRepository) CountScreenshots(tx *gorm.DB, productID uint) (int64, error) {
+	var count int64
+	err := tx.Model(&models.ProductImage{}).
+		Where("product_id = ? AND kind = ?", productID, models.ImageKindScreenshot).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *productImageRepository) LastPosition(tx *gorm.DB, productID uint) (int, error) {
+	var position int
+	err := tx.Model(&models.ProductImage{}).
+		Select("COALESCE(MAX(position), 0)").
+		Where("product_id = ?", productID).
+		Scan(&position).Error
+	return position, err
+}
+
+func (r *productImageRepository) CreateImage(tx *gorm.DB, image *models.ProductImage) error {
+	return tx.Create(image).Error
+}
+
+func (r *productImageRepository) DeleteImage(tx *gorm.DB, image *models.ProductImage) error {
+	return tx.Delete(image).Error
+}
@@ -2,17 +2,54 @@ package repository
 
 import (
 	"game-store-api/internal/models"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type ProductRepository interface {
-	CreateProduct(product *models.Product) error
-	GetAllProducts() ([]models.Product, error)
+	CreateProduct(tx *gorm.DB, product *models.Product) error
+	ListProducts(filter ProductFilter) ([]models.Product, int64, error)
 	GetProductByID(id uint) (*models.Product, error)
+	GetProductsByIDs(ids []uint) ([]models.Product, error)
 	GetProductByIDForUpdate(tx *gorm.DB, id uint) (*models.Product, error)
 	UpdateProduct(tx *gorm.DB, product *models.Product) error
+	UpdateRating(tx *gorm.DB, productID uint, average float64, count int) error
+	// ListPrices returns the id, price, currency and base price of every
+	// product, deleted ones included.
+	ListPrices() ([]models.Product, error)
+	UpdateBasePrice(productID uint, basePrice models.Money) error
+	DeleteProduct(id uint) (bool, error)
+	GetProductByIDUnscoped(id uint) (*models.Product, error)
+	RestoreProduct(product *models.Product) error
+}
+
+// ProductFilter narrows, orders and pages a product listing.
+type ProductFilter struct {
+	Query string
+	// Price bounds in minor units of the base currency
+	MinPrice *models.Money
+	MaxPrice *models.Money
+	InStock  bool
+	SortBy   string // "price", "name", "created_at" or "rating"
+	Desc     bool
+	Limit    int
+	Offset   int
+
+	// Term slugs to filter by. A product matches a taxonomy if it has any
+	// of its slugs, and must match every taxonomy given.
+	Categories []string
+	Platforms  []string
+	Publishers []string
+}
+
+var productSortColumns = map[string]string{
+	"price":      "base_price",
+	"name":       "name",
+	"created_at": "created_at",
+	"rating":     "rating_average",
 }
 
 type productRepository struct {
@@ -23,19 +60,90 @@ func NewProductRepository(db *gorm.DB) ProductRepository {
 	return &productRepository{db: db}
 }
 
-func (r *productRepository) CreateProduct(product *models.Product) error {
-	return r.db.Create(product).Error
+func (r *productRepository) CreateProduct(tx *gorm.DB, product *models.Product) error {
+	return tx.Create(product).Error
 }
 
-func (r *productRepository) GetAllProducts() ([]models.Product, error) {
+// withDetails loads the categories, platforms, publishers and images of
+// products, the images in display order.
+func withDetails(db *gorm.DB) *gorm.DB {
+	return db.Preload("Categories").Preload("Platforms").Preload("Publishers").
+		Preload("Images", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position, id")
+		})
+}
+
+func (r *productRepository) ListProducts(filter ProductFilter) ([]models.Product, int64, error) {
+	query := r.db.Model(&models.Product{})
+
+	if filter.Query != "" {
+		if r.db.Dialector.Name() == "postgres" {
+			query = query.Where(
+				"to_tsvector('english', name || ' ' || description) @@ plainto_tsquery('english', ?)",
+				filter.Query,
+			)
+		} else {
+			// Plain substring match for databases without full-text search (e.g. SQLite in tests)
+			like := "%" + strings.ToLower(filter.Query) + "%"
+			query = query.Where("LOWER(name) LIKE ? OR LOWER(description) LIKE ?", like, like)
+		}
+	}
+	if filter.MinPrice != nil {
+		query = query.Where("base_price >= ?", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		query = query.Where("base_price <= ?", *filter.MaxPrice)
+	}
+	if filter.InStock {
+		// In stock means some is left after what carts hold
+		query = query.Where(
+			"stock > COALESCE((SELECT SUM(quantity) FROM stock_reservations WHERE product_id = products.id AND expires_at > ?), 0)",
+			time.Now(),
+		)
+	}
+
+	for taxonomy, slugs := range map[Taxonomy][]string{
+		Categories: filter.Categories,
+		Platforms:  filter.Platforms,
+		Publishers: filter.Publishers,
+	} {
+		if len(slugs) == 0 {
+			continue
+		}
+		table, column := taxonomy.productLinks()
+		query = query.Where("products.id IN (?)", r.db.Table(table).
+			Select(table+".product_id").
+			Joins("JOIN "+string(taxonomy)+" ON "+string(taxonomy)+".id = "+table+"."+column).
+			Where(string(taxonomy)+".slug IN ?", slugs))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	column, ok := productSortColumns[filter.SortBy]
+	if !ok {
+		column = "created_at"
+	}
+	direction := " ASC"
+	if filter.Desc {
+		direction = " DESC"
+	}
+
 	var products []models.Product
-	err := r.db.Find(&products).Error
-	return products, err
+	err := withDetails(query).
+		Order(column + direction).
+		Order("id" + direction).
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&products).Error
+	return products, total, err
 }
 
 func (r *productRepository) GetProductByID(id uint) (*models.Product, error) {
 	var product models.Product
-	err := r.db.First(&product, id).Error
+	err := withDetails(r.db).First(&product, id).Error
 	return &product, err
 }
 
@@ -45,6 +153,49 @@ func (r *productRepository) GetProductByIDForUpdate(tx *gorm.DB, id uint) (*mode
 	return &product, err
 }
 
+// UpdateProduct saves the product's own columns. Its terms are changed with
+// TaxonomyRepository.SetProductTerms.
 func (r *productRepository) UpdateProduct(tx *gorm.DB, product *models.Product) error {
-	return tx.Save(product).Error
+	return tx.Omit(clause.Associations).Save(product).Error
+}
+
+// UpdateRating stores the summary of the product's reviews without touching
+// its updated_at, since the product itself didn't change.
+func (r *productRepository) UpdateRating(tx *gorm.DB, productID uint, average float64, count int) error {
+	return tx.Model(&models.Product{}).Where("id = ?", productID).
+		UpdateColumns(map[string]interface{}{"rating_average": average, "review_count": count}).Error
+}
+
+func (r *productRepository) ListPrices() ([]models.Product, error) {
+	var products []models.Product
+	err := r.db.Unscoped().Select("id", "price", "currency", "base_price").Order("id").Find(&products).Error
+	return products, err
+}
+
+func (r *productRepository) UpdateBasePrice(productID uint, basePrice models.Money) error {
+	return r.db.Unscoped().Model(&models.Product{}).Where("id = ?", productID).
+		UpdateColumn("base_price", basePrice).Error
+}
+
+func (r *productRepository) GetProductsByIDs(ids []uint) ([]models.Product, error) {
+	var products []models.Product
+	err := r.db.Where("id IN ?", ids).Find(&products).Error
+	return products, err
+}
+
+// DeleteProduct soft-deletes the product and reports whether a row was affected.
+func (r *productRepository) DeleteProduct(id uint) (bool, error) {
+	result := r.db.Delete(&models.Product{}, id)
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetProductByIDUnscoped finds a product including soft-deleted ones.
+func (r *productRepository) GetProductByIDUnscoped(id uint) (*models.Product, error) {
+	var product models.Product
+	err := withDetails(r.db.Unscoped()).First(&product, id).Error
+	return &product, err
+}
+
+func (r *productRepository) RestoreProduct(product *models.Product) error {
+	return r.db.Unscoped().Model(product).Update("deleted_at", nil).Error
 }
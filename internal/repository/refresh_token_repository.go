@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"game-store-api/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository interface {
+	CreateRefreshToken(tx *gorm.DB, token *models.RefreshToken) error
+	GetRefreshTokenByHash(tokenHash string) (*models.RefreshToken, error)
+	RevokeRefreshToken(tx *gorm.DB, id uint, replacedByID *uint) (bool, error)
+	RevokeUserRefreshTokens(userID uint) error
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) CreateRefreshToken(tx *gorm.DB, token *models.RefreshToken) error {
+	return tx.Create(token).Error
+}
+
+func (r *refreshTokenRepository) GetRefreshTokenByHash(tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error
+	return &token, err
+}
+
+// RevokeRefreshToken revokes a token unless it already was, and reports
+// whether this call revoked it. Two requests racing to rotate the same token
+// can't both win.
+func (r *refreshTokenRepository) RevokeRefreshToken(tx *gorm.DB, id uint, replacedByID *uint) (bool, error) {
+	result := tx.Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "replaced_by_id": replacedByID})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *refreshTokenRepository) RevokeUserRefreshTokens(userID uint) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
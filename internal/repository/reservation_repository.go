@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"game-store-api/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReservationRepository stores the stock held by carts. Only reservations
+// that expire after the given time count; expired ones are ignored until
+// DeleteExpired removes them.
+type ReservationRepository interface {
+	// ReservedByOthers sums what every user but userID holds of a product.
+	ReservedByOthers(tx *gorm.DB, productID, userID uint, now time.Time) (int, error)
+	// ReservedQuantities sums the holds on each of productIDs.
+	ReservedQuantities(productIDs []uint, now time.Time) (map[uint]int, error)
+	GetUserReservations(userID uint, now time.Time) ([]models.StockReservation, error)
+	// SaveReservation creates or replaces the user's hold on a product.
+	SaveReservation(tx *gorm.DB, reservation *models.StockReservation) error
+	DeleteReservation(tx *gorm.DB, userID, productID uint) error
+	DeleteUserReservations(tx *gorm.DB, userID uint) error
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+type reservationRepository struct {
+	db *gorm.DB
+}
+
+func NewReservationRepository(db *gorm.DB) ReservationRepository {
+	return &reservationRepository{db: db}
+}
+
+func (r *reservationRepository) ReservedByOthers(tx *gorm.DB, productID, userID uint, now time.Time) (int, error) {
+	var reserved int
+	err := tx.Model(&models.StockReservation{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("product_id = ? AND user_id <> ? AND expires_at > ?", productID, userID, now).
+		Scan(&reserved).Error
+	return reserved, err
+}
+
+func (r *reservationRepository) ReservedQuantities(productIDs []uint, now time.Time) (map[uint]int, error) {
+	var rows []struct {
+		ProductID uint
+		Reserved  int
+	}
+	err := r.db.Model(&models.StockReservation{}).
+		Select("product_id, SUM(quantity) AS reserved").
+		Where("product_id IN ? AND expires_at > ?", productIDs, now).
+		Group("product_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	reserved := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		reserved[row.ProductID] = row.Reserved
+	}
+	return reserved, nil
+}
+
+func (r *reservationRepository) GetUserReservations(userID uint, now time.Time) ([]models.StockReservation, error) {
+	var reservations []models.StockReservation
+	err := r.db.Where("user_id = ? AND expires_at > ?", userID, now).Find(&reservations).Error
+	return reservations, err
+}
+
+func (r *reservationRepository) SaveReservation(tx *gorm.DB, reservation *models.StockReservation) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "product_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"quantity", "expires_at", "updated_at"}),
+	}).Create(reservation).Error
+}
+
+func (r *reservationRepository) DeleteReservation(tx *gorm.DB, userID, productID uint) error {
+	return tx.Where("user_id = ? AND product_id = ?", userID, productID).Delete(&models.StockReservation{}).Error
+}
+
+func (r *reservationRepository) DeleteUserReservations(tx *gorm.DB, userID uint) error {
+	return tx.Where("user_id = ?", userID).Delete(&models.StockReservation{}).Error
+}
+
+func (r *reservationRepository) DeleteExpired(now time.Time) (int64, error) {
+	result := r.db.Where("expires_at <= ?", now).Delete(&models.StockReservation{})
+	return result.RowsAffected, result.Error
+}
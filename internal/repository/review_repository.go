@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"game-store-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// purchasedStatuses are the order statuses in which the customer has paid
+// and kept the order.
+var purchasedStatuses = []string{
+	models.OrderStatusPaid,
+	models.OrderStatusFulfilled,
+	models.OrderStatusShipped,
+	models.OrderStatusDelivered,
+}
+
+type ReviewRepository interface {
+	// HasPurchased reports whether the user has a paid order item of the product.
+	HasPurchased(userID, productID uint) (bool, error)
+	GetReview(productID, reviewID uint) (*models.Review, error)
+	GetReviewByID(reviewID uint) (*models.Review, error)
+	// ListVisibleReviews returns a page of the product's reviews that
+	// aren't hidden, newest first.
+	ListVisibleReviews(productID uint, limit, offset int) ([]models.Review, int64, error)
+	ListReviews(filter ReviewFilter) ([]models.Review, int64, error)
+	CreateReview(tx *gorm.DB, review *models.Review) error
+	UpdateReview(tx *gorm.DB, review *models.Review) error
+	DeleteReview(tx *gorm.DB, review *models.Review) error
+	// RatingSummary averages the ratings of the product's visible reviews.
+	RatingSummary(tx *gorm.DB, productID uint) (average float64, count int, err error)
+}
+
+// ReviewFilter narrows and pages the admin review listing. Zero values don't
+// filter.
+type ReviewFilter struct {
+	ProductID uint
+	UserID    uint
+	Hidden    *bool
+	Limit     int
+	Offset    int
+}
+
+type reviewRepository struct {
+	db *gorm.DB
+}
+
+func NewReviewRepository(db *gorm.DB) ReviewRepository {
+	return &reviewRepository{db: db}
+}
+
+func (r *reviewRepository) HasPurchased(userID, productID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL").
+		Where("orders.user_id = ? AND order_items.product_id = ? AND orders.status IN ?", userID, productID, purchasedStatuses).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *reviewRepository) GetReview(productID, reviewID uint) (*models.Review, error) {
+	var review models.Review
+	err := r.db.Where("product_id = ?", productID).First(&review, reviewID).Error
+	return &review, err
+}
+
+func (r *reviewRepository) GetReviewByID(reviewID uint) (*models.Review, error) {
+	var review models.Review
+	err := r.db.First(&review, reviewID).Error
+	return &review, err
+}
+
+func (r *reviewRepository) ListVisibleReviews(productID uint, limit, offset int) ([]models.Review, int64, error) {
+	query := r.db.Model(&models.Review{}).Where("product_id = ? AND hidden_at IS NULL", productID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var reviews []models.Review
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&reviews).Error
+	return reviews, total, err
+}
+
+func (r *reviewRepository) ListReviews(filter ReviewFilter) ([]models.Review, int64, error) {
+	query := r.db.Model(&models.Review{})
+	if filter.ProductID != 0 {
+		query = query.Where("product_id = ?", filter.ProductID)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Hidden != nil {
+		if *filter.Hidden {
+			query = query.Where("hidden_at IS NOT NULL")
+		} else {
+			query = query.Where("hidden_at IS NULL")
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var reviews []models.Review
+	err := query.Order("created_at DESC, id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&reviews).Error
+	return reviews, total, err
+}
+
+func (r *reviewRepository) CreateReview(tx *gorm.DB, review *models.Review) error {
+	return tx.Create(review).Error
+}
+
+func (r *reviewRepository) UpdateReview(tx *gorm.DB, review *models.Review) error {
+	return tx.Save(review).Error
+}
+
+func (r *reviewRepository) DeleteReview(tx *gorm.DB, review *models.Review) error {
+	return tx.Delete(review).Error
+}
+
+func (r *reviewRepository) RatingSummary(tx *gorm.DB, productID uint) (float64, int, error) {
+	var summary struct {
+		Average float64
+		Count   int
+	}
+	err := tx.Model(&models.Review{}).
+		Select("COALESCE(AVG(rating), 0) AS average, COUNT(*) AS count").
+		Where("product_id = ? AND hidden_at IS NULL", productID).
+		Scan(&summary).Error
+	return summary.Average, summary.Count, err
+}
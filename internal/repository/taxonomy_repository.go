@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"game-store-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Taxonomy is one of the ways the catalogue is classified. Its value is the
+// table its terms are stored in.
+type Taxonomy string
+
+const (
+	Categories Taxonomy = "categories"
+	Platforms  Taxonomy = "platforms"
+	Publishers Taxonomy = "publishers"
+)
+
+// productLinks names the table linking products to terms of the taxonomy
+// and its term column.
+func (t Taxonomy) productLinks() (table, column string) {
+	switch t {
+	case Categories:
+		return "product_categories", "category_id"
+	case Platforms:
+		return "product_platforms", "platform_id"
+	default:
+		return "product_publishers", "publisher_id"
+	}
+}
+
+// TaxonomyRepository stores the terms of every taxonomy and which products
+// they classify.
+type TaxonomyRepository interface {
+	ListTerms(taxonomy Taxonomy) ([]models.Term, error)
+	GetTermsByIDs(tx *gorm.DB, taxonomy Taxonomy, ids []uint) ([]models.Term, error)
+	CreateTerm(taxonomy Taxonomy, term *models.Term) error
+	// UpdateTerm saves the name and slug of a term and reports whether it exists.
+	UpdateTerm(taxonomy Taxonomy, term *models.Term) (bool, error)
+	// DeleteTerm removes a term from every product and coupon, then deletes it.
+	DeleteTerm(tx *gorm.DB, taxonomy Taxonomy, id uint) (bool, error)
+	// SetProductTerms replaces the terms of taxonomy a product has with ids.
+	SetProductTerms(tx *gorm.DB, taxonomy Taxonomy, productID uint, ids []uint) error
+}
+
+type taxonomyRepository struct {
+	db *gorm.DB
+}
+
+func NewTaxonomyRepository(db *gorm.DB) TaxonomyRepository {
+	return &taxonomyRepository{db: db}
+}
+
+func (r *taxonomyRepository) ListTerms(taxonomy Taxonomy) ([]models.Term, error) {
+	var terms []models.Term
+	err := r.db.Table(string(taxonomy)).Order("name").Order("id").Find(&terms).Error
+	return terms, err
+}
+
+func (r *taxonomyRepository) GetTermsByIDs(tx *gorm.DB, taxonomy Taxonomy, ids []uint) ([]models.Term, error) {
+	var terms []models.Term
+	err := tx.Table(string(taxonomy)).Where("id IN ?", ids).Find(&terms).Error
+	return terms, err
+}
+
+func (r *taxonomyRepository) CreateTerm(taxonomy Taxonomy, term *models.Term) error {
+	return r.db.Table(string(taxonomy)).Create(term).Error
+}
+
+func (r *taxonomyRepository) UpdateTerm(taxonomy Taxonomy, term *models.Term) (bool, error) {
+	result := r.db.Table(string(taxonomy)).Where("id = ?", term.ID).
+		Updates(map[string]any{"name": term.Name, "slug": term.Slug, "updated_at": r.db.NowFunc()})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	return true, r.db.Table(string(taxonomy)).First(term, term.ID).Error
+}
+
+func (r *taxonomyRepository) DeleteTerm(tx *gorm.DB, taxonomy Taxonomy, id uint) (bool, error) {
+	table, column := taxonomy.productLinks()
+	if err := tx.Exec("DELETE FROM "+table+" WHERE "+column+" = ?", id).Error; err != nil {
+		return false, err
+	}
+	if taxonomy == Categories {
+		if err := tx.Exec("DELETE FROM coupon_categories WHERE category_id = ?", id).Error; err != nil {
+			return false, err
+		}
+	}
+
+	result := tx.Table(string(taxonomy)).Where("id = ?", id).Delete(&models.Term{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *taxonomyRepository) SetProductTerms(tx *gorm.DB, taxonomy Taxonomy, productID uint, ids []uint) error {
+	table, column := taxonomy.productLinks()
+	if err := tx.Exec("DELETE FROM "+table+" WHERE product_id = ?", productID).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	seen := make(map[uint]bool, len(ids))
+	links := make([]map[string]any, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			links = append(links, map[string]any{"product_id": productID, column: id})
+		}
+	}
+	return tx.Table(table).Create(links).Error
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"game-store-api/internal/models"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TokenDenylist tracks revoked access tokens until they would have expired
+// anyway.
+type TokenDenylist interface {
+	// Revoke denies a single token by its ID.
+	Revoke(jti string, expiresAt time.Time) error
+	// RevokeUser denies every token of a user issued strictly before
+	// issuedBefore, compared in milliseconds. The entry is kept until
+	// expiresAt, when all of those tokens have expired.
+	RevokeUser(userID uint, issuedBefore, expiresAt time.Time) error
+	IsRevoked(jti string, userID uint, issuedAt time.Time) (bool, error)
+}
+
+// NewTokenDenylist keeps the denylist in Redis when a client is available and
+// in the database otherwise.
+func NewTokenDenylist(db *gorm.DB, redisClient *redis.Client) TokenDenylist {
+	if redisClient != nil {
+		return &redisTokenDenylist{client: redisClient}
+	}
+	return &dbTokenDenylist{db: db}
+}
+
+func revokedJTIKey(jti string) string {
+	return "jti:" + jti
+}
+
+func revokedUserKey(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+type dbTokenDenylist struct {
+	db *gorm.DB
+}
+
+func (d *dbTokenDenylist) Revoke(jti string, expiresAt time.Time) error {
+	return d.upsert(revokedJTIKey(jti), time.Now(), expiresAt)
+}
+
+func (d *dbTokenDenylist) RevokeUser(userID uint, issuedBefore, expiresAt time.Time) error {
+	return d.upsert(revokedUserKey(userID), issuedBefore, expiresAt)
+}
+
+func (d *dbTokenDenylist) upsert(key string, issuedBefore, expiresAt time.Time) error {
+	// Drop entries for tokens that have expired on their own
+	if err := d.db.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+
+	return d.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"issued_before", "expires_at"}),
+	}).Create(&models.RevokedToken{Key: key, IssuedBefore: issuedBefore, ExpiresAt: expiresAt}).Error
+}
+
+func (d *dbTokenDenylist) IsRevoked(jti string, userID uint, issuedAt time.Time) (bool, error) {
+	var count int64
+	err := d.db.Model(&models.RevokedToken{}).
+		Where("expires_at > ?", time.Now()).
+		Where("key = ? OR (key = ? AND issued_before > ?)", revokedJTIKey(jti), revokedUserKey(userID), issuedAt).
+		Count(&count).Error
+	return count > 0, err
+}
+
+type redisTokenDenylist struct {
+	client *redis.Client
+}
+
+func (d *redisTokenDenylist) Revoke(jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return d.client.Set(context.Background(), "revoked_token:"+revokedJTIKey(jti), 1, ttl).Err()
+}
+
+func (d *redisTokenDenylist) RevokeUser(userID uint, issuedBefore, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return d.client.Set(context.Background(), redisRevokedUserKey(userID), issuedBefore.UnixMilli(), ttl).Err()
+}
+
+func (d *redisTokenDenylist) IsRevoked(jti string, userID uint, issuedAt time.Time) (bool, error) {
+	ctx := context.Background()
+	values, err := d.client.MGet(ctx, "revoked_token:"+revokedJTIKey(jti), redisRevokedUserKey(userID)).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return false, err
+	}
+	if values[0] != nil {
+		return true, nil
+	}
+	if values[1] == nil {
+		return false, nil
+	}
+
+	var issuedBefore int64 // Unix milliseconds
+	if _, err := fmt.Sscan(values[1].(string), &issuedBefore); err != nil {
+		return false, err
+	}
+	return issuedAt.UnixMilli() < issuedBefore, nil
+}
+
+// redisRevokedUserKey holds the cutoff in Unix milliseconds. It is apart from
+// the key that used to hold it in seconds, which would read as a cutoff in
+// 1970.
+func redisRevokedUserKey(userID uint) string {
+	return "revoked_token:" + revokedUserKey(userID) + ":ms"
+}
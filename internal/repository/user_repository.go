@@ -7,8 +7,10 @@ import (
 )
 
 type UserRepository interface {
-	CreateUser(user *models.User) error
+	CreateUser(tx *gorm.DB, user *models.User) error
 	GetUserByEmail(email string) (*models.User, error)
+	GetUserByID(id uint) (*models.User, error)
+	UpdateUser(tx *gorm.DB, user *models.User) error
 }
 
 type userRepository struct {
@@ -19,8 +21,8 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
-func (r *userRepository) CreateUser(user *models.User) error {
-	return r.db.Create(user).Error
+func (r *userRepository) CreateUser(tx *gorm.DB, user *models.User) error {
+	return tx.Create(user).Error
 }
 
 func (r *userRepository) GetUserByEmail(email string) (*models.User, error) {
@@ -28,3 +30,13 @@ func (r *userRepository) GetUserByEmail(email string) (*models.User, error) {
 	err := r.db.Where("email = ?", email).First(&user).Error
 	return &user, err
 }
+
+func (r *userRepository) GetUserByID(id uint) (*models.User, error) {
+	var user models.User
+	err := r.db.First(&user, id).Error
+	return &user, err
+}
+
+func (r *userRepository) UpdateUser(tx *gorm.DB, user *models.User) error {
+	return tx.Save(user).Error
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"game-store-api/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WishlistRepository interface {
+	// ListItems returns a page of the user's wishlist, newest first. Products
+	// that were deleted are left out.
+	ListItems(userID uint, limit, offset int) ([]models.WishlistItem, int64, error)
+	// AddItem puts a product on the wishlist. Adding it twice is a no-op.
+	AddItem(item *models.WishlistItem) error
+	RemoveItem(userID, productID uint) (bool, error)
+	// GetWishers returns the users with the product on their wishlist.
+	GetWishers(productID uint) ([]models.User, error)
+	// HasAlert reports whether the user was already emailed about an event.
+	HasAlert(eventID, userID uint) (bool, error)
+	// RecordAlert notes that the user was emailed about an event. Recording
+	// it twice is a no-op.
+	RecordAlert(eventID, userID uint) error
+}
+
+type wishlistRepository struct {
+	db *gorm.DB
+}
+
+func NewWishlistRepository(db *gorm.DB) WishlistRepository {
+	return &wishlistRepository{db: db}
+}
+
+func (r *wishlistRepository) ListItems(userID uint, limit, offset int) ([]models.WishlistItem, int64, error) {
+	query := r.db.Model(&models.WishlistItem{}).
+		Joins("JOIN products ON products.id = wishlist_items.product_id AND products.deleted_at IS NULL").
+		Where("wishlist_items.user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var items []models.WishlistItem
+	err := query.Preload("Product").
+		Order("wishlist_items.created_at DESC, wishlist_items.id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&items).Error
+	return items, total, err
+}
+
+func (r *wishlistRepository) AddItem(item *models.WishlistItem) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Omit("Product").Create(item).Error
+}
+
+func (r *wishlistRepository) RemoveItem(userID, productID uint) (bool, error) {
+	result := r.db.Where("user_id = ? AND product_id = ?", userID, productID).Delete(&models.WishlistItem{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *wishlistRepository) GetWishers(productID uint) ([]models.User, error) {
+	var users []models.User
+	err := r.db.Joins("JOIN wishlist_items ON wishlist_items.user_id = users.id").
+		Where("wishlist_items.product_id = ?", productID).
+		Order("users.id").
+		Find(&users).Error
+	return users, err
+}
+
+func (r *wishlistRepository) HasAlert(eventID, userID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.WishlistAlert{}).Where("event_id = ? AND user_id = ?", eventID, userID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *wishlistRepository) RecordAlert(eventID, userID uint) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.WishlistAlert{EventID: eventID, UserID: userID}).Error
+}
@@ -1,29 +1,80 @@
 package service
 
 import (
-	"context"
-	"encoding/json"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"game-store-api/internal/models"
 	"game-store-api/internal/repository"
+	"log/slog"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/redis/go-redis/v9"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 7 * 24 * time.Hour
+
+	VerifyEmailTokenTTL   = 24 * time.Hour
+	PasswordResetTokenTTL = time.Hour
+
+	// At most this many verification or reset emails are sent to one
+	// address per actionEmailWindow.
+	maxActionEmails   = 3
+	actionEmailWindow = time.Hour
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrInvalidActionToken  = errors.New("invalid, expired or already used token")
+)
+
+// EmailQueue hands email tasks to the email worker.
+type EmailQueue interface {
+	Enqueue(task map[string]string) error
+}
+
+// TokenPair is what a client gets on login and on every refresh.
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
 type AuthService struct {
-	userRepo    repository.UserRepository
-	redisClient *redis.Client
+	userRepo        repository.UserRepository
+	refreshRepo     repository.RefreshTokenRepository
+	actionTokenRepo repository.ActionTokenRepository
+	outboxRepo      repository.OutboxRepository
+	denylist        repository.TokenDenylist
+	emailQueue      EmailQueue
+	db              *gorm.DB
 }
 
-func NewAuthService(userRepo repository.UserRepository, redisClient *redis.Client) *AuthService {
+// NewAuthService builds the service. emailQueue may be nil when Redis is not
+// available, in which case no emails are sent.
+func NewAuthService(
+	userRepo repository.UserRepository,
+	refreshRepo repository.RefreshTokenRepository,
+	actionTokenRepo repository.ActionTokenRepository,
+	outboxRepo repository.OutboxRepository,
+	denylist repository.TokenDenylist,
+	emailQueue EmailQueue,
+	db *gorm.DB) *AuthService {
 	return &AuthService{
-		userRepo:    userRepo,
-		redisClient: redisClient,
+		userRepo:        userRepo,
+		refreshRepo:     refreshRepo,
+		actionTokenRepo: actionTokenRepo,
+		outboxRepo:      outboxRepo,
+		denylist:        denylist,
+		emailQueue:      emailQueue,
+		db:              db,
 	}
 }
 
@@ -44,38 +95,432 @@ func (s *AuthService) Register(email, password string) error {
 		Role:     "user",
 	}
 
-	if err := s.userRepo.CreateUser(&user); err != nil {
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := s.userRepo.CreateUser(tx, &user); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// The welcome and verification emails are sent when this event is handled
+	event := models.UserRegisteredEvent{UserID: user.ID, Email: user.Email}
+	if err := recordEvent(tx, s.outboxRepo, models.EventUserRegistered, user.ID, event); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// SendWelcomeEmail queues the welcome email of a newly registered user.
+func (s *AuthService) SendWelcomeEmail(userID uint) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	return s.enqueueEmail(map[string]string{
+		"email":   user.Email,
+		"user_id": fmt.Sprintf("%d", user.ID),
+		"type":    "welcome_email",
+	})
+}
+
+// SendVerificationEmail emails a verification link to a user who hasn't
+// verified their address yet.
+func (s *AuthService) SendVerificationEmail(userID uint) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified() {
+		return nil
+	}
+	return s.sendActionEmail(user, models.ActionVerifyEmail)
+}
+
+// RequestEmailVerification emails a new verification link to an unverified
+// user. Unknown and already verified addresses are silently ignored so the
+// endpoint can't be used to find out who has an account.
+func (s *AuthService) RequestEmailVerification(email string) error {
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil || user.EmailVerified() {
+		return nil
+	}
+	return s.sendActionEmail(user, models.ActionVerifyEmail)
+}
+
+// VerifyEmail consumes a verification token and marks the address verified.
+func (s *AuthService) VerifyEmail(token string) error {
+	record, user, err := s.checkActionToken(token, models.ActionVerifyEmail)
+	if err != nil {
+		return err
+	}
+
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := s.useActionToken(tx, record); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if !user.EmailVerified() {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		if err := s.userRepo.UpdateUser(tx, user); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// RequestPasswordReset emails a password reset link. Like
+// RequestEmailVerification it doesn't reveal whether the address exists.
+func (s *AuthService) RequestPasswordReset(email string) error {
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil {
+		return nil
+	}
+	return s.sendActionEmail(user, models.ActionPasswordReset)
+}
+
+// ResetPassword consumes a reset token, sets the new password and ends every
+// session of the user. Receiving the email proves the address, so it also
+// counts as verification.
+func (s *AuthService) ResetPassword(token, password string) error {
+	record, user, err := s.checkActionToken(token, models.ActionPasswordReset)
+	if err != nil {
+		return err
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := s.useActionToken(tx, record); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	user.Password = string(hashed)
+	if !user.EmailVerified() {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := s.userRepo.UpdateUser(tx, user); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	return s.LogoutAll(user.ID)
+}
+
+// sendActionEmail issues a signed single-use token for purpose and queues an
+// email task carrying it, unless the user already got maxActionEmails of them
+// within actionEmailWindow.
+func (s *AuthService) sendActionEmail(user *models.User, purpose string) error {
+	sent, err := s.actionTokenRepo.CountRecentActionTokens(user.ID, purpose, time.Now().Add(-actionEmailWindow))
+	if err != nil {
+		return err
+	}
+	if sent >= maxActionEmails {
+		slog.Warn("Email rate limit reached", "user_id", user.ID, "type", purpose)
+		return nil
+	}
+
+	ttl := VerifyEmailTokenTTL
+	if purpose == models.ActionPasswordReset {
+		ttl = PasswordResetTokenTTL
+	}
+
+	tokenID, err := randomToken(16)
+	if err != nil {
+		return err
+	}
+	record := &models.ActionToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenID:   tokenID,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.actionTokenRepo.CreateActionToken(record); err != nil {
+		return err
+	}
+
+	claims := jwt.MapClaims{
+		"sub":     float64(user.ID),
+		"purpose": purpose,
+		"jti":     tokenID,
+		"exp":     record.ExpiresAt.Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(actionTokenKey(purpose))
+	if err != nil {
 		return err
 	}
 
-	if s.redisClient != nil {
-		taskPayload := map[string]string{
-			"email":   user.Email,
-			"user_id": fmt.Sprintf("%d", user.ID),
-			"type":    "welcome_email",
+	return s.enqueueEmail(map[string]string{
+		"email":      user.Email,
+		"user_id":    fmt.Sprintf("%d", user.ID),
+		"type":       purpose,
+		"token":      token,
+		"expires_at": record.ExpiresAt.Format(time.RFC3339),
+	})
+}
+
+// checkActionToken checks a token's signature, purpose, expiry and that it
+// hasn't been used, and returns its record and user.
+func (s *AuthService) checkActionToken(token, purpose string) (*models.ActionToken, *models.User, error) {
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
 		}
-		jsonBody, _ := json.Marshal(taskPayload)
-		s.redisClient.RPush(context.Background(), "send_email_queue", jsonBody)
+		return actionTokenKey(purpose), nil
+	})
+	if err != nil || !parsed.Valid {
+		return nil, nil, ErrInvalidActionToken
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != purpose {
+		return nil, nil, ErrInvalidActionToken
+	}
+	tokenID, _ := claims["jti"].(string)
+	userID, _ := claims["sub"].(float64)
+
+	record, err := s.actionTokenRepo.GetActionTokenByTokenID(tokenID)
+	if err != nil || record.UserID != uint(userID) || record.Purpose != purpose || record.UsedAt != nil {
+		return nil, nil, ErrInvalidActionToken
+	}
+
+	user, err := s.userRepo.GetUserByID(record.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidActionToken
+	}
+	return record, user, nil
+}
+
+// useActionToken marks a checked token used, failing if a concurrent request
+// used it first.
+func (s *AuthService) useActionToken(tx *gorm.DB, record *models.ActionToken) error {
+	used, err := s.actionTokenRepo.UseActionToken(tx, record.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidActionToken
 	}
 	return nil
 }
 
-func (s *AuthService) Login(email, password string) (string, error) {
+// actionTokenKey derives a signing key per purpose, so a verification token
+// can't be used as a reset token or as an access token.
+func actionTokenKey(purpose string) []byte {
+	return []byte(os.Getenv("JWT_SECRET") + ":" + purpose)
+}
+
+func (s *AuthService) enqueueEmail(task map[string]string) error {
+	if s.emailQueue == nil {
+		slog.Warn("Email queue unavailable, email not sent", "type", task["type"], "user_id", task["user_id"])
+		return nil
+	}
+	if err := s.emailQueue.Enqueue(task); err != nil {
+		slog.Error("Failed to enqueue email", "type", task["type"], "user_id", task["user_id"], "error", err)
+		return err
+	}
+	return nil
+}
+
+func (s *AuthService) Login(email, password string) (*TokenPair, error) {
 	user, err := s.userRepo.GetUserByEmail(email)
 	if err != nil {
-		return "", errors.New("invalid email or password")
+		return nil, errors.New("invalid email or password")
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return "", errors.New("invalid email or password")
+		return nil, errors.New("invalid email or password")
+	}
+
+	refreshToken, record, err := s.newRefreshToken(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refreshRepo.CreateRefreshToken(s.db, record); err != nil {
+		return nil, err
+	}
+	return s.tokenPair(user, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new token pair. The old refresh
+// token is revoked; presenting a rotated token again is treated as theft and
+// ends every session of the user.
+func (s *AuthService) Refresh(refreshToken string) (*TokenPair, error) {
+	record, err := s.refreshRepo.GetRefreshTokenByHash(hashToken(refreshToken))
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if record.RevokedAt != nil {
+		if record.ReplacedByID != nil {
+			slog.Warn("Rotated refresh token reused, revoking all sessions", "user_id", record.UserID)
+			if err := s.LogoutAll(record.UserID); err != nil {
+				slog.Error("Failed to revoke sessions", "user_id", record.UserID, "error", err)
+			}
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetUserByID(record.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	newToken, newRecord, err := s.newRefreshToken(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := s.refreshRepo.CreateRefreshToken(tx, newRecord); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	revoked, err := s.refreshRepo.RevokeRefreshToken(tx, record.ID, &newRecord.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
 	}
+	if !revoked {
+		// Another request rotated this token first
+		tx.Rollback()
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return s.tokenPair(user, newToken)
+}
+
+// Logout revokes the access token with the given ID and, when provided, the
+// refresh token of the same session.
+func (s *AuthService) Logout(userID uint, jti string, expiresAt time.Time, refreshToken string) error {
+	if err := s.denylist.Revoke(jti, expiresAt); err != nil {
+		return err
+	}
+	if refreshToken == "" {
+		return nil
+	}
+
+	record, err := s.refreshRepo.GetRefreshTokenByHash(hashToken(refreshToken))
+	if err != nil || record.UserID != userID {
+		return nil
+	}
+	_, err = s.refreshRepo.RevokeRefreshToken(s.db, record.ID, nil)
+	return err
+}
 
+// LogoutAll ends every session of a user: all refresh tokens are revoked and
+// all access tokens issued so far are denied until they expire.
+func (s *AuthService) LogoutAll(userID uint) error {
+	if err := s.refreshRepo.RevokeUserRefreshTokens(userID); err != nil {
+		return err
+	}
+	// Tokens carry their issue time in milliseconds, so only tokens issued
+	// before this millisecond are denied and a login right afterwards, such
+	// as after a password reset, is not.
+	now := time.Now().Truncate(time.Millisecond)
+	return s.denylist.RevokeUser(userID, now, now.Add(AccessTokenTTL))
+}
+
+// IsRevoked reports whether an access token has been revoked by a logout.
+func (s *AuthService) IsRevoked(jti string, userID uint, issuedAt time.Time) (bool, error) {
+	return s.denylist.IsRevoked(jti, userID, issuedAt)
+}
+
+func (s *AuthService) tokenPair(user *models.User, refreshToken string) (*TokenPair, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	// iat is in seconds with milliseconds, so tokens issued in the second of
+	// a LogoutAll can be told apart from those it revoked.
+	now := time.Now()
 	claims := jwt.MapClaims{
 		"sub":  float64(user.ID),
 		"role": user.Role,
-		"exp":  time.Now().Add(time.Hour * 24).Unix(),
+		"jti":  jti,
+		"iat":  float64(now.UnixMilli()) / 1000,
+		"exp":  now.Add(AccessTokenTTL).Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	secret := os.Getenv("JWT_SECRET")
-	return token.SignedString([]byte(secret))
+	accessToken, err := token.SignedString([]byte(secret))
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// newRefreshToken returns a new opaque refresh token and the record to store
+// for it.
+func (s *AuthService) newRefreshToken(userID uint) (string, *models.RefreshToken, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &models.RefreshToken{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	}, nil
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"fmt"
+	"game-store-api/internal/models"
+	"game-store-api/internal/pricing"
+	"math"
+	"time"
+)
+
+// Codes of the warnings on a cart line.
+const (
+	WarningProductDeleted    = "product_deleted"
+	WarningOutOfStock        = "out_of_stock"
+	WarningInsufficientStock = "insufficient_stock"
+	WarningPriceChanged      = "price_changed"
+)
+
+// CartWarning tells the customer why a line may not check out as shown.
+type CartWarning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// CartLine is one item of a priced cart. UnitPrice and LineSubtotal are in
+// the cart's currency; a line whose product was deleted costs nothing.
+type CartLine struct {
+	ProductID    uint           `json:"product_id"`
+	Product      models.Product `json:"product"`
+	Quantity     int            `json:"quantity"`
+	UnitPrice    models.Money   `json:"unit_price"`
+	LineSubtotal models.Money   `json:"line_subtotal"`
+	// ReservedUntil is when the stock held for this line goes back on sale.
+	ReservedUntil *time.Time    `json:"reserved_until,omitempty"`
+	Warnings      []CartWarning `json:"warnings,omitempty"`
+}
+
+// CartView is a cart priced in one currency. Tax is charged at TaxRate on
+// the subtotal less discounts, and Total is what checkout would charge.
+// CouponError explains why an applied coupon gives no discount right now.
+type CartView struct {
+	Items       []CartLine   `json:"items"`
+	ItemCount   int          `json:"item_count"`
+	Currency    string       `json:"currency"`
+	Subtotal    models.Money `json:"subtotal"`
+	Discounts   []Discount   `json:"discounts"`
+	TaxRate     float64      `json:"tax_rate"`
+	Tax         models.Money `json:"tax"`
+	Total       models.Money `json:"total"`
+	HasWarnings bool         `json:"has_warnings"`
+	CouponError string       `json:"coupon_error,omitempty"`
+
+	couponErr error
+}
+
+// cartPricer prices carts. GET /cart and checkout share it, so the cart
+// always shows what checkout charges.
+type cartPricer struct {
+	rates   *pricing.ExchangeRates
+	taxRate float64
+}
+
+// price works out items in currency at now. coupon may be nil; one that
+// doesn't apply leaves the cart undiscounted and is reported in CouponError.
+func (p cartPricer) price(items []models.CartItem, coupon *models.Coupon, currency string, now time.Time) (*CartView, error) {
+	view := &CartView{Items: []CartLine{}, Currency: currency, Discounts: []Discount{}, TaxRate: p.taxRate}
+
+	var lines []cartLine
+	for _, item := range items {
+		line := CartLine{ProductID: item.ProductID, Product: item.Product, Quantity: item.Quantity}
+		view.ItemCount += item.Quantity
+
+		if item.Product.ID == 0 || item.Product.DeletedAt.Valid {
+			line.Warnings = append(line.Warnings, CartWarning{
+				Code:    WarningProductDeleted,
+				Message: "This product is no longer sold. Remove it to check out.",
+			})
+			view.Items = append(view.Items, line)
+			view.HasWarnings = true
+			continue
+		}
+
+		if err := p.rates.ConvertProduct(&line.Product, currency); err != nil {
+			return nil, err
+		}
+		line.UnitPrice = line.Product.Price
+		line.LineSubtotal = models.Money(item.Quantity) * line.UnitPrice
+		line.Warnings = p.lineWarnings(item, line, currency)
+		view.HasWarnings = view.HasWarnings || len(line.Warnings) > 0
+
+		view.Subtotal += line.LineSubtotal
+		view.Items = append(view.Items, line)
+		lines = append(lines, cartLine{
+			ProductID:   item.ProductID,
+			CategoryIDs: categoryIDs(item.Product),
+			Quantity:    item.Quantity,
+			Price:       line.UnitPrice,
+		})
+	}
+
+	var discount models.Money
+	if coupon != nil {
+		amount, err := couponDiscount(coupon, lines, currency, p.rates, now)
+		switch {
+		case err == nil:
+			discount = amount
+			view.Discounts = append(view.Discounts, discountLine(coupon, amount))
+		case isCouponError(err):
+			view.couponErr = err
+			view.CouponError = err.Error()
+		default:
+			return nil, err
+		}
+	}
+
+	taxable := view.Subtotal - discount
+	view.Tax = models.Money(math.Round(float64(taxable) * p.taxRate))
+	view.Total = taxable + view.Tax
+	return view, nil
+}
+
+func categoryIDs(product models.Product) []uint {
+	ids := make([]uint, len(product.Categories))
+	for i, category := range product.Categories {
+		ids[i] = category.ID
+	}
+	return ids
+}
+
+// lineWarnings checks a line against the stock available to the customer
+// and the price it was added at.
+func (p cartPricer) lineWarnings(item models.CartItem, line CartLine, currency string) []CartWarning {
+	var warnings []CartWarning
+	switch stock := item.Product.Available; {
+	case stock <= 0:
+		warnings = append(warnings, CartWarning{Code: WarningOutOfStock, Message: "Out of stock."})
+	case stock < item.Quantity:
+		warnings = append(warnings, CartWarning{
+			Code:    WarningInsufficientStock,
+			Message: fmt.Sprintf("Only %d left in stock.", stock),
+		})
+	}
+
+	if item.AddedCurrency != "" && (item.AddedPrice != item.Product.Price || item.AddedCurrency != item.Product.Currency) {
+		was, err := p.rates.Convert(item.AddedPrice, item.AddedCurrency, currency)
+		if err == nil && was != line.UnitPrice {
+			warnings = append(warnings, CartWarning{
+				Code: WarningPriceChanged,
+				Message: fmt.Sprintf("Price changed from %s to %s %s since you added it.",
+					was.Format(currency), line.UnitPrice.Format(currency), currency),
+			})
+		}
+	}
+	return warnings
+}
@@ -1,37 +1,268 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"game-store-api/internal/models"
+	"game-store-api/internal/pricing"
 	"game-store-api/internal/repository"
+	"time"
+
+	"gorm.io/gorm"
 )
 
+var ErrNotEnoughStock = errors.New("not enough stock")
+
 type CartService struct {
-	cartRepo    repository.CartRepository
-	productRepo repository.ProductRepository
+	cartRepo        repository.CartRepository
+	productRepo     repository.ProductRepository
+	couponRepo      repository.CouponRepository
+	reservationRepo repository.ReservationRepository
+	db              *gorm.DB
+	rates           *pricing.ExchangeRates
+	pricer          cartPricer
+	reservationTTL  time.Duration
 }
 
-func NewCartService(cartRepo repository.CartRepository, productRepo repository.ProductRepository) *CartService {
-	return &CartService{cartRepo: cartRepo, productRepo: productRepo}
+// NewCartService prices carts with rates and adds tax at taxRate, a fraction
+// of the discounted subtotal. Stock put in a cart is held for reservationTTL
+// after the line was last changed.
+func NewCartService(
+	cartRepo repository.CartRepository,
+	productRepo repository.ProductRepository,
+	couponRepo repository.CouponRepository,
+	reservationRepo repository.ReservationRepository,
+	db *gorm.DB,
+	rates *pricing.ExchangeRates,
+	taxRate float64,
+	reservationTTL time.Duration) *CartService {
+	return &CartService{
+		cartRepo:        cartRepo,
+		productRepo:     productRepo,
+		couponRepo:      couponRepo,
+		reservationRepo: reservationRepo,
+		db:              db,
+		rates:           rates,
+		pricer:          cartPricer{rates: rates, taxRate: taxRate},
+		reservationTTL:  reservationTTL,
+	}
 }
 
+// AddToCart changes the quantity of a product in the cart by quantity and
+// holds that much stock for the user. Adding more than other carts leave
+// available fails with ErrNotEnoughStock; taking items out always works.
 func (s *CartService) AddToCart(userID, productID uint, quantity int) error {
-	_, err := s.productRepo.GetProductByID(productID)
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// The product lock serialises carts competing for the same stock
+	product, err := s.productRepo.GetProductByIDForUpdate(tx, productID)
 	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrProductNotFound
+		}
 		return err
 	}
 
-	item := models.CartItem{
+	item, err := s.cartRepo.GetItem(tx, userID, productID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	newQuantity := item.Quantity + quantity
+	if newQuantity <= 0 {
+		if item.ID != 0 {
+			if err := s.cartRepo.DeleteItem(tx, item); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if err := s.reservationRepo.DeleteReservation(tx, userID, productID); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit().Error
+	}
+
+	now := time.Now()
+	if quantity > 0 {
+		reserved, err := s.reservationRepo.ReservedByOthers(tx, productID, userID, now)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if available := product.Stock - reserved; newQuantity > available {
+			tx.Rollback()
+			return fmt.Errorf("%w: only %d of %s available", ErrNotEnoughStock, max(available, 0), product.Name)
+		}
+	}
+
+	item.Quantity = newQuantity
+	item.AddedPrice = product.Price
+	item.AddedCurrency = product.Currency
+	if err := s.cartRepo.SaveItem(tx, item); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := s.reservationRepo.SaveReservation(tx, &models.StockReservation{
 		UserID:    userID,
 		ProductID: productID,
-		Quantity:  quantity,
+		Quantity:  newQuantity,
+		ExpiresAt: now.Add(s.reservationTTL),
+	}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// GetCart returns the user's cart priced in currency, or in the base
+// currency when currency is empty.
+func (s *CartService) GetCart(userID uint, currency string) (*CartView, error) {
+	if currency == "" {
+		currency = s.rates.Base
+	}
+	if !s.rates.Supports(currency) {
+		return nil, ErrUnsupportedCurrency
+	}
+
+	coupon, err := s.cartRepo.GetCoupon(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.priceCart(userID, coupon, currency)
+}
+
+// ApplyCoupon puts the coupon with code on the user's cart if it gives a
+// discount on the cart as it is now, and returns the cart priced in currency.
+func (s *CartService) ApplyCoupon(userID uint, code, currency string) (*CartView, error) {
+	if currency == "" {
+		currency = s.rates.Base
+	}
+	if !s.rates.Supports(currency) {
+		return nil, ErrUnsupportedCurrency
+	}
+
+	coupon, err := s.couponRepo.GetCouponByCode(NormalizeCouponCode(code))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCouponNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	view, err := s.priceCart(userID, coupon, currency)
+	if err != nil {
+		return nil, err
 	}
-	return s.cartRepo.AddItem(&item)
+	if view.couponErr != nil {
+		return nil, view.couponErr
+	}
+
+	if err := s.cartRepo.SetCoupon(userID, coupon.ID); err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+func (s *CartService) RemoveCoupon(userID uint) error {
+	return s.cartRepo.RemoveCoupon(userID)
+}
+
+// priceCart prices the user's cart in currency with coupon, which is left
+// out when the user has no uses of it left.
+func (s *CartService) priceCart(userID uint, coupon *models.Coupon, currency string) (*CartView, error) {
+	items, err := s.cartRepo.GetCartByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	held, err := s.setAvailable(userID, items, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var usageErr error
+	if coupon != nil {
+		usageErr = checkCouponUsage(s.db, s.couponRepo, coupon, userID)
+		if usageErr != nil && !isCouponError(usageErr) {
+			return nil, usageErr
+		}
+	}
+	if usageErr != nil {
+		coupon = nil
+	}
+
+	view, err := s.pricer.price(items, coupon, currency, now)
+	if err != nil {
+		return nil, err
+	}
+	if usageErr != nil {
+		view.couponErr = usageErr
+		view.CouponError = usageErr.Error()
+	}
+	for i := range view.Items {
+		if reservation, ok := held[view.Items[i].ProductID]; ok {
+			view.Items[i].ReservedUntil = &reservation.ExpiresAt
+		}
+	}
+	return view, nil
 }
 
-func (s *CartService) GetCart(userID uint) ([]models.CartItem, error) {
-	return s.cartRepo.GetCartByUserID(userID)
+// setAvailable works out how much of each cart item's product the user can
+// still buy once other carts' holds are taken out, and returns the user's
+// own holds by product.
+func (s *CartService) setAvailable(userID uint, items []models.CartItem, now time.Time) (map[uint]models.StockReservation, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	productIDs := make([]uint, len(items))
+	for i, item := range items {
+		productIDs[i] = item.ProductID
+	}
+
+	reserved, err := s.reservationRepo.ReservedQuantities(productIDs, now)
+	if err != nil {
+		return nil, err
+	}
+	own, err := s.reservationRepo.GetUserReservations(userID, now)
+	if err != nil {
+		return nil, err
+	}
+	held := make(map[uint]models.StockReservation, len(own))
+	for _, reservation := range own {
+		held[reservation.ProductID] = reservation
+		reserved[reservation.ProductID] -= reservation.Quantity
+	}
+
+	for i := range items {
+		items[i].Product.Available = max(items[i].Product.Stock-reserved[items[i].ProductID], 0)
+	}
+	return held, nil
+}
+
+// isCouponError reports whether err says a coupon can't be used, as opposed
+// to a failure looking it up.
+func isCouponError(err error) bool {
+	for _, target := range []error{ErrCouponNotStarted, ErrCouponExpired, ErrCouponUsedUp, ErrCouponUserLimit, ErrCouponMinSpend, ErrCouponNotApplicable} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
 }
 
+// RemoveItem takes a product out of the cart and releases its stock.
 func (s *CartService) RemoveItem(userID, productID uint) error {
-	return s.cartRepo.RemoveItem(userID, productID)
+	if err := s.cartRepo.RemoveItem(userID, productID); err != nil {
+		return err
+	}
+	return s.reservationRepo.DeleteReservation(s.db, userID, productID)
 }
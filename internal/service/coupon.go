@@ -0,0 +1,247 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"game-store-api/internal/models"
+	"game-store-api/internal/pricing"
+	"game-store-api/internal/repository"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCouponNotFound      = errors.New("coupon not found")
+	ErrDuplicateCoupon     = errors.New("a coupon with this code already exists")
+	ErrInvalidCoupon       = errors.New("percentage coupons take a value from 1 to 100")
+	ErrInvalidCouponPeriod = errors.New("a coupon must expire after it starts")
+	ErrCouponNotStarted    = errors.New("coupon is not active yet")
+	ErrCouponExpired       = errors.New("coupon has expired")
+	ErrCouponUsedUp        = errors.New("coupon has reached its usage limit")
+	ErrCouponUserLimit     = errors.New("you have already used this coupon as often as allowed")
+	ErrCouponMinSpend      = errors.New("cart total is below the coupon's minimum spend")
+	ErrCouponNotApplicable = errors.New("coupon doesn't apply to any item in the cart")
+)
+
+// Discount is a discount line of a cart or order, in the cart's currency.
+type Discount struct {
+	Code        string       `json:"code"`
+	Description string       `json:"description"`
+	Amount      models.Money `json:"amount"`
+}
+
+// cartLine is a cart item priced in the currency of the cart.
+type cartLine struct {
+	ProductID   uint
+	CategoryIDs []uint
+	Quantity    int
+	Price       models.Money
+}
+
+// NormalizeCouponCode makes coupon codes case-insensitive.
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// couponDiscount works out what coupon takes off lines priced in currency at
+// now, or why it can't be used. Usage limits are checked separately by
+// checkCouponUsage.
+func couponDiscount(coupon *models.Coupon, lines []cartLine, currency string, rates *pricing.ExchangeRates, now time.Time) (models.Money, error) {
+	if coupon.StartsAt != nil && now.Before(*coupon.StartsAt) {
+		return 0, ErrCouponNotStarted
+	}
+	if coupon.ExpiresAt != nil && !now.Before(*coupon.ExpiresAt) {
+		return 0, ErrCouponExpired
+	}
+
+	var subtotal, eligible models.Money
+	for _, line := range lines {
+		amount := models.Money(line.Quantity) * line.Price
+		subtotal += amount
+		if inCouponScope(coupon, line) {
+			eligible += amount
+		}
+	}
+
+	minSpend, err := rates.Convert(coupon.MinSpend, coupon.Currency, currency)
+	if err != nil {
+		return 0, err
+	}
+	if subtotal < minSpend {
+		return 0, fmt.Errorf("%w: spend at least %s %s", ErrCouponMinSpend, minSpend.Format(currency), currency)
+	}
+	if eligible == 0 {
+		return 0, ErrCouponNotApplicable
+	}
+
+	var discount models.Money
+	switch coupon.Type {
+	case models.CouponTypePercentage:
+		discount = eligible * models.Money(coupon.Value) / 100
+	default:
+		discount, err = rates.Convert(models.Money(coupon.Value), coupon.Currency, currency)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return min(discount, eligible), nil
+}
+
+// inCouponScope reports whether coupon discounts line.
+func inCouponScope(coupon *models.Coupon, line cartLine) bool {
+	if len(coupon.Products) == 0 && len(coupon.Categories) == 0 {
+		return true
+	}
+	for _, product := range coupon.Products {
+		if product.ID == line.ProductID {
+			return true
+		}
+	}
+	for _, category := range coupon.Categories {
+		if slices.Contains(line.CategoryIDs, category.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCouponUsage fails when coupon has no uses left overall or for userID.
+func checkCouponUsage(tx *gorm.DB, couponRepo repository.CouponRepository, coupon *models.Coupon, userID uint) error {
+	if coupon.MaxUses > 0 && coupon.Uses >= coupon.MaxUses {
+		return ErrCouponUsedUp
+	}
+	if coupon.MaxUsesPerUser == 0 {
+		return nil
+	}
+	used, err := couponRepo.CountRedemptions(tx, coupon.ID, userID)
+	if err != nil {
+		return err
+	}
+	if used >= int64(coupon.MaxUsesPerUser) {
+		return ErrCouponUserLimit
+	}
+	return nil
+}
+
+// discountLine describes what coupon took off.
+func discountLine(coupon *models.Coupon, amount models.Money) Discount {
+	description := coupon.Description
+	if description == "" {
+		if coupon.Type == models.CouponTypePercentage {
+			description = fmt.Sprintf("%d%% off", coupon.Value)
+		} else {
+			description = fmt.Sprintf("%s %s off", models.Money(coupon.Value).Format(coupon.Currency), coupon.Currency)
+		}
+	}
+	return Discount{Code: coupon.Code, Description: description, Amount: amount}
+}
+
+// CouponInput holds the settings of a new coupon.
+type CouponInput struct {
+	Code           string
+	Description    string
+	Type           string
+	Value          int64
+	Currency       string
+	MinSpend       models.Money
+	ProductIDs     []uint
+	CategoryIDs    []uint
+	StartsAt       *time.Time
+	ExpiresAt      *time.Time
+	MaxUses        int
+	MaxUsesPerUser int
+}
+
+type CouponService struct {
+	couponRepo   repository.CouponRepository
+	productRepo  repository.ProductRepository
+	taxonomyRepo repository.TaxonomyRepository
+	db           *gorm.DB
+}
+
+func NewCouponService(couponRepo repository.CouponRepository, productRepo repository.ProductRepository, taxonomyRepo repository.TaxonomyRepository, db *gorm.DB) *CouponService {
+	return &CouponService{couponRepo: couponRepo, productRepo: productRepo, taxonomyRepo: taxonomyRepo, db: db}
+}
+
+func (s *CouponService) CreateCoupon(input CouponInput) (*models.Coupon, error) {
+	if _, ok := models.CurrencyExponent(input.Currency); !ok {
+		return nil, ErrUnsupportedCurrency
+	}
+	if input.Type == models.CouponTypePercentage && input.Value > 100 {
+		return nil, ErrInvalidCoupon
+	}
+	if input.StartsAt != nil && input.ExpiresAt != nil && !input.ExpiresAt.After(*input.StartsAt) {
+		return nil, ErrInvalidCouponPeriod
+	}
+
+	coupon := models.Coupon{
+		Code:           NormalizeCouponCode(input.Code),
+		Description:    input.Description,
+		Type:           input.Type,
+		Value:          input.Value,
+		Currency:       input.Currency,
+		MinSpend:       input.MinSpend,
+		StartsAt:       input.StartsAt,
+		ExpiresAt:      input.ExpiresAt,
+		MaxUses:        input.MaxUses,
+		MaxUsesPerUser: input.MaxUsesPerUser,
+	}
+	if len(input.ProductIDs) > 0 {
+		products, err := s.productRepo.GetProductsByIDs(input.ProductIDs)
+		if err != nil {
+			return nil, err
+		}
+		if len(products) != len(uniqueIDs(input.ProductIDs)) {
+			return nil, ErrProductNotFound
+		}
+		coupon.Products = products
+	}
+	if len(input.CategoryIDs) > 0 {
+		terms, err := s.taxonomyRepo.GetTermsByIDs(s.db, repository.Categories, input.CategoryIDs)
+		if err != nil {
+			return nil, err
+		}
+		if missing := missingTerm(input.CategoryIDs, terms); missing != 0 {
+			return nil, fmt.Errorf("%w: categories has no id %d", ErrUnknownTerm, missing)
+		}
+		for _, term := range terms {
+			coupon.Categories = append(coupon.Categories, models.Category{Term: term})
+		}
+	}
+
+	if err := s.couponRepo.CreateCoupon(&coupon); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrDuplicateCoupon
+		}
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+func (s *CouponService) ListCoupons(page, limit int) ([]models.Coupon, int64, error) {
+	return s.couponRepo.ListCoupons(limit, (page-1)*limit)
+}
+
+// DeleteCoupon retires a coupon. Carts it was applied to stop using it, and
+// orders keep the discount they got.
+func (s *CouponService) DeleteCoupon(id uint) error {
+	deleted, err := s.couponRepo.DeleteCoupon(id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrCouponNotFound
+	}
+	return nil
+}
+
+func uniqueIDs(ids []uint) map[uint]bool {
+	set := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
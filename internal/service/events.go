@@ -0,0 +1,65 @@
+package service
+
+import (
+	"encoding/json"
+	"game-store-api/internal/models"
+	"game-store-api/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// recordEvent writes a domain event to the outbox as part of tx, so it is
+// only ever published if tx commits.
+func recordEvent(tx *gorm.DB, outboxRepo repository.OutboxRepository, eventType string, aggregateID uint, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return outboxRepo.AddEvent(tx, &models.OutboxEvent{
+		Type:        eventType,
+		AggregateID: aggregateID,
+		Payload:     string(body),
+	})
+}
+
+// recordStockChange records EventStockChanged when a product's stock moved.
+func recordStockChange(tx *gorm.DB, outboxRepo repository.OutboxRepository, product *models.Product, previousStock int) error {
+	if product.Stock == previousStock {
+		return nil
+	}
+	return recordEvent(tx, outboxRepo, models.EventStockChanged, product.ID, models.StockChangedEvent{
+		ProductID:     product.ID,
+		PreviousStock: previousStock,
+		Stock:         product.Stock,
+	})
+}
+
+// recordPriceChange records EventPriceChanged when a product's price or its
+// currency changed.
+func recordPriceChange(tx *gorm.DB, outboxRepo repository.OutboxRepository, product *models.Product, previousPrice models.Money, previousCurrency string) error {
+	if product.Price == previousPrice && product.Currency == previousCurrency {
+		return nil
+	}
+	return recordEvent(tx, outboxRepo, models.EventPriceChanged, product.ID, models.PriceChangedEvent{
+		ProductID:        product.ID,
+		PreviousPrice:    previousPrice,
+		PreviousCurrency: previousCurrency,
+		Price:            product.Price,
+		Currency:         product.Currency,
+	})
+}
+
+// orderEvent builds the payload of an order event.
+func orderEvent(order *models.Order, user *models.User, previousStatus string) models.OrderEvent {
+	return models.OrderEvent{
+		OrderID:        order.ID,
+		UserID:         order.UserID,
+		Email:          user.Email,
+		Total:          order.TotalCents,
+		Currency:       order.Currency,
+		Status:         order.Status,
+		PreviousStatus: previousStatus,
+		Carrier:        order.Carrier,
+		TrackingNumber: order.TrackingNumber,
+	}
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"game-store-api/internal/models"
+	"game-store-api/internal/repository"
+	"log/slog"
+)
+
+var (
+	ErrIdempotencyKeyInUse  = errors.New("a request with this idempotency key is still in progress")
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+)
+
+// IdempotencyService lets clients retry a request safely: the first request
+// with a key claims it, and repeats get the stored response instead of running
+// again.
+type IdempotencyService struct {
+	repo repository.IdempotencyRepository
+}
+
+func NewIdempotencyService(repo repository.IdempotencyRepository) *IdempotencyService {
+	return &IdempotencyService{repo: repo}
+}
+
+// Begin claims key for a request with the given fingerprint. It returns a new,
+// incomplete record when the caller should run the request, or the completed
+// record to replay. A key claimed with another fingerprint is never replayed,
+// and one whose request is still running can only be taken over once its
+// lease has run out.
+func (s *IdempotencyService) Begin(userID uint, key, fingerprint string) (*models.IdempotencyKey, error) {
+	if fingerprint == "" {
+		return nil, errors.New("idempotency key claimed without a fingerprint")
+	}
+	record, claimed, err := s.repo.Claim(&models.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		Fingerprint: fingerprint,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claimed {
+		return record, nil
+	}
+
+	if fingerprint != record.Fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if !record.Completed() {
+		return nil, ErrIdempotencyKeyInUse
+	}
+	return record, nil
+}
+
+// Find returns the record stored under key, or nil when there is none.
+func (s *IdempotencyService) Find(userID uint, key string) (*models.IdempotencyKey, error) {
+	return s.repo.Get(userID, key)
+}
+
+// Complete stores the response of a successful request for replay.
+func (s *IdempotencyService) Complete(record *models.IdempotencyKey, orderID uint, statusCode int, response interface{}) error {
+	body, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	record.OrderID = &orderID
+	record.StatusCode = statusCode
+	record.Response = string(body)
+	return s.repo.Complete(record)
+}
+
+// Release frees the key of a failed request so the client can retry with it.
+func (s *IdempotencyService) Release(record *models.IdempotencyKey) {
+	if err := s.repo.Release(record.UserID, record.Key); err != nil {
+		slog.Error("Failed to release idempotency key", "user_id", record.UserID, "key", record.Key, "error", err)
+	}
+}
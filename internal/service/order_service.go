@@ -2,69 +2,261 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	pb "game-store-api/internal/grpc/payment"
 	"game-store-api/internal/models"
+	"game-store-api/internal/pricing"
 	"game-store-api/internal/repository"
+	"log/slog"
+	"sort"
+	"strings"
 	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrOrderNotFound            = errors.New("order not found")
+	ErrOrderNotPaid             = errors.New("order has no captured payment")
+	ErrPaymentUnavailable       = errors.New("payment service unavailable")
+	ErrPaymentRejected          = errors.New("payment service rejected the request")
+	ErrEmailNotVerified         = errors.New("verify your email address before checking out")
+	ErrCancellationWindowClosed = errors.New("order can no longer be cancelled")
+)
+
+// OrderPayment is the payment service's view of the charge behind an order.
+// Amounts are in the minor unit of Currency.
+type OrderPayment struct {
+	TransactionID string       `json:"transaction_id"`
+	OrderID       uint         `json:"order_id"`
+	Amount        models.Money `json:"amount"`
+	Refunded      models.Money `json:"refunded"`
+	Currency      string       `json:"currency"`
+	Status        string       `json:"status"`
+}
+
 type OrderService struct {
-	orderRepo     repository.OrderRepository
-	productRepo   repository.ProductRepository
-	cartRepo      repository.CartRepository
-	paymentClient pb.PaymentServiceClient
-	db            *gorm.DB
+	orderRepo       repository.OrderRepository
+	userRepo        repository.UserRepository
+	productRepo     repository.ProductRepository
+	cartRepo        repository.CartRepository
+	paymentRepo     repository.PaymentRepository
+	outboxRepo      repository.OutboxRepository
+	keyRepo         repository.GameKeyRepository
+	couponRepo      repository.CouponRepository
+	reservationRepo repository.ReservationRepository
+	paymentClient   pb.PaymentServiceClient
+	db              *gorm.DB
+	rates           *pricing.ExchangeRates
+	pricer          cartPricer
 }
 
 func NewOrderService(
 	orderRepo repository.OrderRepository,
+	userRepo repository.UserRepository,
 	productRepo repository.ProductRepository,
 	cartRepo repository.CartRepository,
+	paymentRepo repository.PaymentRepository,
+	outboxRepo repository.OutboxRepository,
+	keyRepo repository.GameKeyRepository,
+	couponRepo repository.CouponRepository,
+	reservationRepo repository.ReservationRepository,
 	paymentClient pb.PaymentServiceClient,
-	db *gorm.DB) *OrderService {
+	db *gorm.DB,
+	rates *pricing.ExchangeRates,
+	taxRate float64) *OrderService {
 	return &OrderService{
-		orderRepo:     orderRepo,
-		productRepo:   productRepo,
-		cartRepo:      cartRepo,
-		paymentClient: paymentClient,
-		db:            db,
+		orderRepo:       orderRepo,
+		userRepo:        userRepo,
+		productRepo:     productRepo,
+		cartRepo:        cartRepo,
+		paymentRepo:     paymentRepo,
+		outboxRepo:      outboxRepo,
+		keyRepo:         keyRepo,
+		couponRepo:      couponRepo,
+		reservationRepo: reservationRepo,
+		paymentClient:   paymentClient,
+		db:              db,
+		rates:           rates,
+		pricer:          cartPricer{rates: rates, taxRate: taxRate},
 	}
 }
 
-func (s *OrderService) Checkout(userID uint) (*models.Order, error) {
+// Checkout runs the order saga: reserve stock on a pending order, charge the
+// customer, then mark the order paid. Every step compensates for the ones
+// before it when it fails, so stock is never lost and a charge is never kept
+// without an order. A charge that can't be refunded right away is retried by
+// the event workers, and its order keeps the stock until then. The order is priced and charged in currency, defaulting
+// to the base currency when empty. Only users with a verified email can
+// check out.
+func (s *OrderService) Checkout(userID uint, currency string) (*models.Order, error) {
+	if currency == "" {
+		currency = s.rates.Base
+	}
+	if !s.rates.Supports(currency) {
+		return nil, ErrUnsupportedCurrency
+	}
+
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if !user.EmailVerified() {
+		return nil, ErrEmailNotVerified
+	}
+
 	cartItems, err := s.cartRepo.GetCartByUserID(userID)
 	if err != nil || len(cartItems) == 0 {
 		return nil, errors.New("cart is empty")
 	}
+	coupon, err := s.cartRepo.GetCoupon(userID)
+	if err != nil {
+		return nil, err
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// --- Reserve stock on a pending order ---
+	order, err := s.reserveOrder(userID, cartItems, coupon, currency)
+	if err != nil {
+		return nil, err
+	}
 
 	// --- Make a payment request ---
-	totalCents := 0
-	for _, item := range cartItems {
-		totalCents += item.Quantity * item.Product.Price
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
 	paymentReq := &pb.PaymentRequest{
-		OrderId:        int64(userID),
-		Amount:         float32(totalCents) / 100.0,
-		Currency:       "USD",
+		OrderId:        int64(order.ID),
+		Amount:         toPaymentMoney(order.TotalCents, order.Currency),
 		CredCardNumber: "1212-1212-1212-1212",
 	}
 
 	paymentRes, err := s.paymentClient.ProcessPayment(ctx, paymentReq)
 	if err != nil {
+		s.recordPayment(order, nil, models.PaymentStatusFailed, err.Error())
+		s.releaseOrderOrLog(order, "payment failed")
 		return nil, errors.New("payment service unavailable")
 	}
 
 	if !paymentRes.Success {
+		s.recordPayment(order, nil, models.PaymentStatusDeclined, paymentRes.Message)
+		s.releaseOrderOrLog(order, "payment declined")
 		return nil, errors.New("payment declined: " + paymentRes.Message)
 	}
 
+	// The charge is recorded before anything else can fail, so it can always
+	// be reconciled.
+	payment := s.recordPayment(order, &paymentRes.TransactionId, models.PaymentStatusCaptured, paymentRes.Message)
+
+	// --- Mark the order paid ---
+	if err := s.completeOrder(order, user); err != nil {
+		slog.Error("Failed to complete paid order", "order_id", order.ID, "error", err)
+		// The order stays pending, holding its stock, until the charge is
+		// refunded
+		if err := s.refundLater(payment); err != nil {
+			slog.Error("Failed to record pending refund, manual reconciliation required",
+				"order_id", order.ID, "transaction_id", paymentRes.TransactionId, "error", err)
+			return nil, errors.New("failed to complete order, refund is pending")
+		}
+		if err := s.settlePayment(payment); err != nil {
+			settleLater(payment, err)
+			return nil, errors.New("failed to complete order, refund is pending")
+		}
+		return nil, errors.New("failed to complete order, payment has been refunded")
+	}
+
+	return order, nil
+}
+
+// CheckoutFingerprint identifies what a checkout would buy: the cart's
+// products and quantities, its coupon and the currency. It returns an empty
+// string for an empty cart.
+func (s *OrderService) CheckoutFingerprint(userID uint, currency string) (string, error) {
+	cartItems, err := s.cartRepo.GetCartByUserID(userID)
+	if err != nil {
+		return "", err
+	}
+	if len(cartItems) == 0 {
+		return "", nil
+	}
+
+	coupon, err := s.cartRepo.GetCoupon(userID)
+	if err != nil {
+		return "", err
+	}
+	couponCode := ""
+	if coupon != nil {
+		couponCode = coupon.Code
+	}
+
+	quantities := make(map[uint]int, len(cartItems))
+	for _, item := range cartItems {
+		quantities[item.ProductID] += item.Quantity
+	}
+	return s.checkoutFingerprint(currency, couponCode, quantities), nil
+}
+
+// OrderFingerprint is the CheckoutFingerprint of the cart that placed one of
+// the user's orders, checked out in currency. A checkout empties the cart, so
+// a retry of it is matched on its order instead.
+func (s *OrderService) OrderFingerprint(userID, orderID uint, currency string) (string, error) {
+	order, err := s.orderRepo.GetOrderByUserID(userID, orderID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrOrderNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	quantities := make(map[uint]int, len(order.Items))
+	for _, item := range order.Items {
+		quantities[item.ProductID] += item.Quantity
+	}
+	return s.checkoutFingerprint(currency, order.CouponCode, quantities), nil
+}
+
+// checkoutFingerprint hashes the currency, the coupon code and the quantity
+// of each product.
+func (s *OrderService) checkoutFingerprint(currency, couponCode string, quantities map[uint]int) string {
+	if currency == "" {
+		currency = s.rates.Base
+	}
+	productIDs := make([]uint, 0, len(quantities))
+	for productID := range quantities {
+		productIDs = append(productIDs, productID)
+	}
+	sort.Slice(productIDs, func(i, j int) bool {
+		return productIDs[i] < productIDs[j]
+	})
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "currency=%s\n", currency)
+	if couponCode != "" {
+		fmt.Fprintf(hash, "coupon=%s\n", couponCode)
+	}
+	for _, productID := range productIDs {
+		fmt.Fprintf(hash, "%d:%d\n", productID, quantities[productID])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// reserveOrder locks and decrements stock for every cart item and records a
+// pending order priced in currency, all in one transaction. Items of digital
+// products get their keys while the product rows are still locked. A coupon
+// is checked again under its row lock and its use recorded with the order.
+// The order is priced exactly as GET /cart shows the cart. Stock other
+// customers hold in their carts can't be bought, and the user's own holds
+// end once the stock is taken.
+func (s *OrderService) reserveOrder(userID uint, cartItems []models.CartItem, coupon *models.Coupon, currency string) (*models.Order, error) {
+	exchangeRate, err := s.rates.Rate(s.rates.Base, currency)
+	if err != nil {
+		return nil, err
+	}
+
 	tx := s.db.Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -72,37 +264,91 @@ func (s *OrderService) Checkout(userID uint) (*models.Order, error) {
 		}
 	}()
 
-	var orderItems []models.OrderItem
-	for _, item := range cartItems {
+	now := time.Now()
+	digital := make(map[uint]string)
+	for i, item := range cartItems {
 		product, err := s.productRepo.GetProductByIDForUpdate(tx, item.ProductID)
 		if err != nil {
 			tx.Rollback()
 			return nil, errors.New("product not found: " + item.Product.Name)
 		}
 
-		if product.Stock <= item.Quantity {
+		reserved, err := s.reservationRepo.ReservedByOthers(tx, product.ID, userID, now)
+		if err != nil {
 			tx.Rollback()
-			return nil, errors.New("not enough stock for: " + item.Product.Name)
+			return nil, err
 		}
+		product.Available = product.Stock - reserved
+		if product.Available < item.Quantity {
+			tx.Rollback()
+			return nil, errors.New("not enough stock for: " + product.Name)
+		}
+		// Price what was locked, not what the cart loaded earlier
+		product.Categories = item.Product.Categories
+		cartItems[i].Product = *product
+
+		previousStock := product.Stock
 		product.Stock -= item.Quantity
+		if product.Digital {
+			digital[product.ID] = product.Name
+		}
 
 		if err := s.productRepo.UpdateProduct(tx, product); err != nil {
 			tx.Rollback()
-			return nil, errors.New("product update failed: " + item.Product.Name)
+			return nil, errors.New("product update failed: " + product.Name)
 		}
+		if err := recordStockChange(tx, s.outboxRepo, product, previousStock); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
 
-		orderItems = append(orderItems, models.OrderItem{
-			ProductID: product.ID,
-			Quantity:  item.Quantity,
-			Price:     product.Price,
-		})
+	if coupon != nil {
+		coupon, err = s.couponRepo.GetCouponByIDForUpdate(tx, coupon.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			tx.Rollback()
+			return nil, ErrCouponNotFound
+		}
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := checkCouponUsage(tx, s.couponRepo, coupon, userID); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	cart, err := s.pricer.price(cartItems, coupon, currency, now)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if cart.couponErr != nil {
+		tx.Rollback()
+		return nil, cart.couponErr
 	}
 
 	order := models.Order{
-		UserID:     userID,
-		TotalCents: totalCents,
-		Status:     "paid",
-		Items:      orderItems,
+		UserID:        userID,
+		TotalCents:    cart.Total,
+		SubtotalCents: cart.Subtotal,
+		TaxCents:      cart.Tax,
+		Currency:      currency,
+		BaseCurrency:  s.rates.Base,
+		ExchangeRate:  exchangeRate,
+		Status:        models.OrderStatusPending,
+	}
+	for _, line := range cart.Items {
+		order.Items = append(order.Items, models.OrderItem{
+			ProductID: line.ProductID,
+			Quantity:  line.Quantity,
+			Price:     line.UnitPrice,
+		})
+	}
+	for _, discount := range cart.Discounts {
+		order.DiscountCents += discount.Amount
+		order.CouponCode = discount.Code
 	}
 
 	if err := s.orderRepo.CreateOrder(tx, &order); err != nil {
@@ -110,11 +356,467 @@ func (s *OrderService) Checkout(userID uint) (*models.Order, error) {
 		return nil, errors.New("failed to create order: " + err.Error())
 	}
 
-	if err := s.cartRepo.ClearCart(tx, userID); err != nil {
+	if coupon != nil {
+		if err := s.couponRepo.AddRedemption(tx, &models.CouponRedemption{
+			CouponID: coupon.ID,
+			UserID:   userID,
+			OrderID:  order.ID,
+			Discount: order.DiscountCents,
+			Currency: currency,
+		}); err != nil {
+			tx.Rollback()
+			return nil, errors.New("failed to redeem coupon: " + err.Error())
+		}
+	}
+
+	for _, item := range order.Items {
+		name, ok := digital[item.ProductID]
+		if !ok {
+			continue
+		}
+		assigned, err := s.keyRepo.AssignKeys(tx, item.ProductID, item.ID, item.Quantity)
+		if err != nil {
+			tx.Rollback()
+			return nil, errors.New("failed to assign keys for: " + name)
+		}
+		if assigned < int64(item.Quantity) {
+			tx.Rollback()
+			return nil, errors.New("not enough stock for: " + name)
+		}
+	}
+
+	if err := s.reservationRepo.DeleteUserReservations(tx, userID); err != nil {
 		tx.Rollback()
-		return nil, errors.New("failed to clear cart: " + err.Error())
+		return nil, err
 	}
 
-	tx.Commit()
+	if err := tx.Commit().Error; err != nil {
+		return nil, errors.New("failed to reserve stock: " + err.Error())
+	}
 	return &order, nil
 }
+
+// recordPayment stores the outcome of a charge attempt. Failing to store it
+// must not undo a charge that already happened, so errors are only logged.
+func (s *OrderService) recordPayment(order *models.Order, transactionID *string, paymentStatus, message string) *models.Payment {
+	payment := &models.Payment{
+		OrderID:         order.ID,
+		TransactionID:   transactionID,
+		Amount:          order.TotalCents,
+		Currency:        order.Currency,
+		Status:          paymentStatus,
+		ProviderMessage: message,
+	}
+	if err := s.paymentRepo.CreatePayment(s.db, payment); err != nil {
+		slog.Error("Failed to record payment, manual reconciliation required",
+			"order_id", order.ID, "transaction_id", transactionID, "status", paymentStatus, "error", err)
+	}
+	return payment
+}
+
+// completeOrder marks a reserved order as paid and empties the cart.
+func (s *OrderService) completeOrder(order *models.Order, user *models.User) error {
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	customer := Actor{UserID: user.ID, Role: models.ActorUser}
+	if err := transitionOrder(tx, s.orderRepo, s.outboxRepo, order, user, models.OrderStatusPaid, customer, ""); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := s.cartRepo.ClearCart(tx, order.UserID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	order.Status = models.OrderStatusPaid
+	return nil
+}
+
+// releaseOrder compensates reserveOrder: it puts the reserved stock back,
+// gives the coupon use back and cancels the order on behalf of actor.
+func (s *OrderService) releaseOrder(order *models.Order, actor Actor, note string) error {
+	user, err := s.userRepo.GetUserByID(order.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to load customer of order %d: %w", order.ID, err)
+	}
+
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := s.cancelOrder(tx, order, user, actor, note); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	order.Status = models.OrderStatusCancelled
+	return nil
+}
+
+// cancelOrder puts an order's stock back, gives the coupon use back and
+// cancels the order on behalf of actor as part of tx.
+func (s *OrderService) cancelOrder(tx *gorm.DB, order *models.Order, user *models.User, actor Actor, note string) error {
+	if err := s.restockOrder(tx, order); err != nil {
+		return err
+	}
+	if err := s.couponRepo.RemoveRedemption(tx, order.ID); err != nil {
+		return err
+	}
+	return transitionOrder(tx, s.orderRepo, s.outboxRepo, order, user, models.OrderStatusCancelled, actor, note)
+}
+
+// restockOrder puts every item of an order back into stock as part of tx.
+// Keys of a pending order were never shown to the customer and go back to
+// the pool. Keys of a paid order are revoked and don't come back.
+func (s *OrderService) restockOrder(tx *gorm.DB, order *models.Order) error {
+	for _, item := range order.Items {
+		product, err := s.productRepo.GetProductByIDForUpdate(tx, item.ProductID)
+		if err != nil {
+			return fmt.Errorf("failed to release stock for product %d: %w", item.ProductID, err)
+		}
+
+		previousStock := product.Stock
+		if product.Digital {
+			if err := s.releaseKeys(tx, order, item, product); err != nil {
+				return err
+			}
+		} else {
+			product.Stock += item.Quantity
+		}
+		if err := s.productRepo.UpdateProduct(tx, product); err != nil {
+			return fmt.Errorf("failed to release stock for product %d: %w", item.ProductID, err)
+		}
+		if err := recordStockChange(tx, s.outboxRepo, product, previousStock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseKeys gives back or revokes the keys of an order item and sets the
+// product's stock to the keys left.
+func (s *OrderService) releaseKeys(tx *gorm.DB, order *models.Order, item models.OrderItem, product *models.Product) error {
+	release := s.keyRepo.RevokeKeys
+	if order.Status == models.OrderStatusPending {
+		release = s.keyRepo.ReleaseKeys
+	}
+	if err := release(tx, item.ID); err != nil {
+		return fmt.Errorf("failed to release keys of order item %d: %w", item.ID, err)
+	}
+
+	available, err := s.keyRepo.CountAvailableKeys(tx, product.ID)
+	if err != nil {
+		return fmt.Errorf("failed to count keys of product %d: %w", product.ID, err)
+	}
+	product.Stock = int(available)
+	return nil
+}
+
+// releaseOrderOrLog is releaseOrder by the system for checkout paths that are
+// already handling an earlier error and can only report a failed
+// compensation.
+func (s *OrderService) releaseOrderOrLog(order *models.Order, note string) {
+	if err := s.releaseOrder(order, SystemActor, note); err != nil {
+		slog.Error("Failed to release order", "order_id", order.ID, "error", err)
+	}
+}
+
+// refundLater leaves a charge pending a full refund.
+func (s *OrderService) refundLater(payment *models.Payment) error {
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := s.leavePaymentPending(tx, payment, models.PaymentStatusRefundPending); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (s *OrderService) GetOrders(userID uint, page, limit int) ([]models.Order, int64, error) {
+	return s.orderRepo.GetOrdersByUserID(userID, limit, (page-1)*limit)
+}
+
+// GetOrder returns one of the customer's orders. Game keys are only included
+// while the order is paid for; before payment and after a cancellation or
+// refund they stay hidden.
+func (s *OrderService) GetOrder(userID, orderID uint) (*models.Order, error) {
+	order, err := s.orderRepo.GetOrderByUserID(userID, orderID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !keysRevealed[order.Status] {
+		for i := range order.Items {
+			order.Items[i].Keys = nil
+		}
+	}
+	return order, nil
+}
+
+// keysRevealed lists the order statuses in which the customer sees their keys.
+var keysRevealed = map[string]bool{
+	models.OrderStatusPaid:      true,
+	models.OrderStatusFulfilled: true,
+	models.OrderStatusShipped:   true,
+	models.OrderStatusDelivered: true,
+}
+
+// GetOrderPayment asks the payment service for the current state of an order's charge.
+func (s *OrderService) GetOrderPayment(orderID uint) (*OrderPayment, error) {
+	order, payment, err := s.getPaidOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	details, err := s.paymentClient.GetPayment(ctx, &pb.GetPaymentRequest{TransactionId: *payment.TransactionID})
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrOrderNotPaid
+	}
+	if err != nil {
+		return nil, ErrPaymentUnavailable
+	}
+
+	return &OrderPayment{
+		TransactionID: details.TransactionId,
+		OrderID:       order.ID,
+		Amount:        models.Money(details.GetAmount().GetAmountMinor()),
+		Refunded:      models.Money(details.GetRefunded().GetAmountMinor()),
+		Currency:      details.GetAmount().GetCurrency(),
+		Status:        paymentStatusName(details.Status),
+	}, nil
+}
+
+// RefundOrder refunds amount (in the order's currency) of an order's charge, or
+// everything that is left when amount is zero, on behalf of actor. A fully
+// refunded order is marked refunded.
+func (s *OrderService) RefundOrder(orderID uint, amount models.Money, actor Actor) (*OrderPayment, error) {
+	order, payment, err := s.getPaidOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if !CanTransition(order.Status, models.OrderStatusRefunded) {
+		return nil, fmt.Errorf("%w: a %s order can't be refunded", ErrInvalidStatusTransition, order.Status)
+	}
+	user, err := s.userRepo.GetUserByID(order.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	refundRes, err := s.paymentClient.RefundPayment(ctx, &pb.RefundRequest{
+		TransactionId: *payment.TransactionID,
+		OrderId:       int64(order.ID),
+		Amount:        toPaymentMoney(amount, order.Currency),
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrOrderNotPaid
+	}
+	if err != nil {
+		return nil, ErrPaymentUnavailable
+	}
+	if !refundRes.Success {
+		return nil, fmt.Errorf("%w: %s", ErrPaymentRejected, refundRes.Message)
+	}
+
+	payment.Refunded += models.Money(refundRes.GetRefunded().GetAmountMinor())
+	payment.Status = paymentStatusName(refundRes.Status)
+
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := s.paymentRepo.UpdatePayment(tx, payment); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if refundRes.Status == pb.PaymentStatus_PAYMENT_STATUS_REFUNDED {
+		err := transitionOrder(tx, s.orderRepo, s.outboxRepo, order, user, models.OrderStatusRefunded, actor, "")
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return s.GetOrderPayment(order.ID)
+}
+
+// VoidOrder cancels an order on behalf of actor, releasing its stock, then
+// voids its charge. Only orders that haven't been fulfilled yet, with nothing
+// refunded, can be voided. The order is cancelled and the payment left
+// pending in one transaction before the payment service is called, so a void
+// that fails is retried by the event workers instead of being lost.
+func (s *OrderService) VoidOrder(orderID uint, actor Actor) (*OrderPayment, error) {
+	order, payment, err := s.getPaidOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if !CanTransition(order.Status, models.OrderStatusCancelled) {
+		return nil, fmt.Errorf("%w: a %s order can't be voided", ErrInvalidStatusTransition, order.Status)
+	}
+	if payment.Status != models.PaymentStatusCaptured {
+		return nil, fmt.Errorf("%w: a %s payment can't be voided", ErrPaymentRejected, payment.Status)
+	}
+	user, err := s.userRepo.GetUserByID(order.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := s.cancelOrder(tx, order, user, actor, "payment voided"); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := s.leavePaymentPending(tx, payment, models.PaymentStatusVoidPending); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.settlePayment(payment); err != nil {
+		settleLater(payment, err)
+		return nil, fmt.Errorf("order cancelled, the payment will be voided later: %w", err)
+	}
+	return s.GetOrderPayment(order.ID)
+}
+
+// CancelOrder lets a customer cancel their own order while it is paid but not
+// yet fulfilled. The stock is put back, the order is cancelled and a coupon
+// use is given back, then the charge is refunded in full. The refund is made
+// after the transaction commits, so no row stays locked while the payment
+// service is called; the payment is left pending a refund, which the event
+// workers retry if it fails.
+func (s *OrderService) CancelOrder(userID, orderID uint) (*models.Order, error) {
+	order, err := s.orderRepo.GetOrderByUserID(userID, orderID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != models.OrderStatusPaid {
+		return nil, cancellationWindowError(order.Status)
+	}
+
+	payment, err := s.paymentRepo.GetCapturedPaymentByOrderID(order.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrderNotPaid
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Fails if an admin fulfilled the order in the meantime
+	customer := Actor{UserID: userID, Role: models.ActorUser}
+	if err := s.cancelOrder(tx, order, user, customer, "cancelled by customer"); err != nil {
+		tx.Rollback()
+		if errors.Is(err, ErrInvalidStatusTransition) {
+			return nil, ErrCancellationWindowClosed
+		}
+		return nil, err
+	}
+	if err := s.leavePaymentPending(tx, payment, models.PaymentStatusRefundPending); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.settlePayment(payment); err != nil {
+		settleLater(payment, err)
+	}
+	return s.GetOrder(userID, order.ID)
+}
+
+// cancellationWindowError explains why an order in status can't be cancelled
+// by its customer.
+func cancellationWindowError(status string) error {
+	switch status {
+	case models.OrderStatusPending:
+		return fmt.Errorf("%w: payment is still being processed", ErrCancellationWindowClosed)
+	case models.OrderStatusCancelled, models.OrderStatusRefunded:
+		return fmt.Errorf("%w: it is already %s", ErrCancellationWindowClosed, status)
+	default:
+		return fmt.Errorf("%w: it has already been %s", ErrCancellationWindowClosed, status)
+	}
+}
+
+// getPaidOrder loads an order together with the payment that captured it.
+func (s *OrderService) getPaidOrder(orderID uint) (*models.Order, *models.Payment, error) {
+	order, err := s.orderRepo.GetOrderByID(orderID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payment, err := s.paymentRepo.GetCapturedPaymentByOrderID(order.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrOrderNotPaid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return order, payment, nil
+}
+
+func paymentStatusName(paymentStatus pb.PaymentStatus) string {
+	return strings.ToLower(strings.TrimPrefix(paymentStatus.String(), "PAYMENT_STATUS_"))
+}
+
+func toPaymentMoney(amount models.Money, currency string) *pb.Money {
+	return &pb.Money{AmountMinor: int64(amount), Currency: currency}
+}
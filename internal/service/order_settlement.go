@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	pb "game-store-api/internal/grpc/payment"
+	"game-store-api/internal/models"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// leavePaymentPending moves a payment to pendingStatus and records
+// EventPaymentPending as part of tx, so the event workers keep settling it
+// with the payment service until it goes through.
+func (s *OrderService) leavePaymentPending(tx *gorm.DB, payment *models.Payment, pendingStatus string) error {
+	payment.Status = pendingStatus
+	if err := s.paymentRepo.UpdatePayment(tx, payment); err != nil {
+		return err
+	}
+	return recordEvent(tx, s.outboxRepo, models.EventPaymentPending, payment.OrderID, models.PaymentPendingEvent{
+		PaymentID: payment.ID,
+		OrderID:   payment.OrderID,
+	})
+}
+
+// SettlePayment refunds or voids a pending payment with the payment service.
+// A checkout that couldn't complete keeps its order pending,
+// holding the stock, until its charge is refunded; the order is released
+// then. Settling a payment twice is harmless, so it can be retried.
+func (s *OrderService) SettlePayment(paymentID uint) error {
+	payment, err := s.paymentRepo.GetPaymentByID(paymentID)
+	if err != nil {
+		return fmt.Errorf("failed to load payment %d: %w", paymentID, err)
+	}
+	return s.settlePayment(payment)
+}
+
+func (s *OrderService) settlePayment(payment *models.Payment) error {
+	switch payment.Status {
+	case models.PaymentStatusRefundPending:
+		if err := s.settleRefund(payment); err != nil {
+			return err
+		}
+	case models.PaymentStatusVoidPending:
+		if err := s.settleVoid(payment); err != nil {
+			return err
+		}
+	}
+	if payment.Status != models.PaymentStatusRefunded && payment.Status != models.PaymentStatusVoided {
+		return nil
+	}
+
+	order, err := s.orderRepo.GetOrderByID(payment.OrderID)
+	if err != nil {
+		return fmt.Errorf("failed to load order %d: %w", payment.OrderID, err)
+	}
+	if order.Status != models.OrderStatusPending {
+		return nil
+	}
+	return s.releaseOrder(order, SystemActor, "order could not be completed")
+}
+
+// settleRefund refunds whatever is left of a charge. The payment service
+// rejects a refund that already went through, which counts as settled.
+func (s *OrderService) settleRefund(payment *models.Payment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	refundRes, err := s.paymentClient.RefundPayment(ctx, &pb.RefundRequest{
+		TransactionId: *payment.TransactionID,
+		OrderId:       int64(payment.OrderID),
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPaymentUnavailable, err)
+	}
+	if !refundRes.Success && refundRes.Status != pb.PaymentStatus_PAYMENT_STATUS_REFUNDED {
+		return fmt.Errorf("%w: %s", ErrPaymentRejected, refundRes.Message)
+	}
+
+	payment.Refunded = payment.Amount
+	payment.Status = models.PaymentStatusRefunded
+	if err := s.paymentRepo.UpdatePayment(s.db, payment); err != nil {
+		slog.Error("Refunded a payment but failed to record it", "transaction_id", *payment.TransactionID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// settleVoid voids a charge. The payment service rejects a void that already
+// went through, which counts as settled.
+func (s *OrderService) settleVoid(payment *models.Payment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cancelRes, err := s.paymentClient.CancelPayment(ctx, &pb.CancelRequest{
+		TransactionId: *payment.TransactionID,
+		OrderId:       int64(payment.OrderID),
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPaymentUnavailable, err)
+	}
+	if !cancelRes.Success && cancelRes.Status != pb.PaymentStatus_PAYMENT_STATUS_VOIDED {
+		return fmt.Errorf("%w: %s", ErrPaymentRejected, cancelRes.Message)
+	}
+
+	payment.Status = models.PaymentStatusVoided
+	if err := s.paymentRepo.UpdatePayment(s.db, payment); err != nil {
+		slog.Error("Voided a payment but failed to record it", "transaction_id", *payment.TransactionID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// settleLater logs why a pending payment couldn't be settled right away. The
+// event workers retry it.
+func settleLater(payment *models.Payment, err error) {
+	slog.Warn("Failed to settle payment, it will be retried",
+		"payment_id", payment.ID, "order_id", payment.OrderID, "status", payment.Status, "error", err)
+}
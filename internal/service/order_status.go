@@ -0,0 +1,173 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"game-store-api/internal/models"
+	"game-store-api/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidStatusTransition = errors.New("order cannot move to that status")
+	ErrStatusNeedsPayment      = errors.New("cancel or refund an order through the void or refund endpoints")
+	ErrUnknownOrderStatus      = errors.New("unknown order status")
+)
+
+// orderTransitions lists the statuses each status can move to. Cancelled
+// and refunded orders are final.
+var orderTransitions = map[string][]string{
+	models.OrderStatusPending:   {models.OrderStatusPaid, models.OrderStatusCancelled},
+	models.OrderStatusPaid:      {models.OrderStatusFulfilled, models.OrderStatusCancelled, models.OrderStatusRefunded},
+	models.OrderStatusFulfilled: {models.OrderStatusShipped, models.OrderStatusDelivered, models.OrderStatusRefunded},
+	models.OrderStatusShipped:   {models.OrderStatusDelivered, models.OrderStatusRefunded},
+	models.OrderStatusDelivered: {models.OrderStatusRefunded},
+}
+
+var orderStatuses = map[string]bool{
+	models.OrderStatusPending:   true,
+	models.OrderStatusPaid:      true,
+	models.OrderStatusFulfilled: true,
+	models.OrderStatusShipped:   true,
+	models.OrderStatusDelivered: true,
+	models.OrderStatusCancelled: true,
+	models.OrderStatusRefunded:  true,
+}
+
+// fulfilmentStatuses are the statuses an admin may set directly. The others
+// move money and have endpoints of their own.
+var fulfilmentStatuses = map[string]bool{
+	models.OrderStatusFulfilled: true,
+	models.OrderStatusShipped:   true,
+	models.OrderStatusDelivered: true,
+}
+
+// CanTransition reports whether an order may move from one status to another.
+func CanTransition(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Actor is who changes an order's status. A zero UserID means the system.
+type Actor struct {
+	UserID uint
+	Role   string
+}
+
+var SystemActor = Actor{Role: models.ActorSystem}
+
+// StatusUpdate is an admin's request to move an order along fulfilment.
+// Carrier and TrackingNumber only apply when shipping.
+type StatusUpdate struct {
+	Status         string
+	Carrier        string
+	TrackingNumber string
+	Note           string
+}
+
+// transitionOrder moves order to status as part of tx: it checks the
+// transition is allowed, records who made it and writes the matching order
+// event to the outbox. It fails with ErrInvalidStatusTransition when the
+// order changed status in the meantime.
+func transitionOrder(tx *gorm.DB, orderRepo repository.OrderRepository, outboxRepo repository.OutboxRepository, order *models.Order, user *models.User, status string, actor Actor, note string) error {
+	from := order.Status
+	if !CanTransition(from, status) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidStatusTransition, from, status)
+	}
+
+	updated, err := orderRepo.UpdateOrderStatus(tx, order.ID, from, status)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return fmt.Errorf("%w: order %d is no longer %s", ErrInvalidStatusTransition, order.ID, from)
+	}
+
+	entry := &models.OrderStatusHistory{
+		OrderID:    order.ID,
+		FromStatus: from,
+		ToStatus:   status,
+		ActorRole:  actor.Role,
+		Note:       note,
+	}
+	if actor.UserID != 0 {
+		entry.ActorID = &actor.UserID
+	}
+	if err := orderRepo.AddStatusHistory(tx, entry); err != nil {
+		return err
+	}
+
+	changed := *order
+	changed.Status = status
+	return recordEvent(tx, outboxRepo, models.OrderStatusEvent(status), order.ID, orderEvent(&changed, user, from))
+}
+
+// ListOrders returns a page of every customer's orders for admins.
+func (s *OrderService) ListOrders(filter repository.OrderFilter) ([]models.Order, int64, error) {
+	if filter.Status != "" && !orderStatuses[filter.Status] {
+		return nil, 0, ErrUnknownOrderStatus
+	}
+	return s.orderRepo.ListOrders(filter)
+}
+
+// GetOrderDetails returns any order with its payments and status history.
+func (s *OrderService) GetOrderDetails(orderID uint) (*models.Order, error) {
+	order, err := s.orderRepo.GetOrderDetails(orderID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrderNotFound
+	}
+	return order, err
+}
+
+// UpdateOrderStatus moves a paid order along fulfilment on behalf of an admin.
+func (s *OrderService) UpdateOrderStatus(orderID uint, update StatusUpdate, actor Actor) (*models.Order, error) {
+	if !orderStatuses[update.Status] {
+		return nil, ErrUnknownOrderStatus
+	}
+	if !fulfilmentStatuses[update.Status] {
+		return nil, ErrStatusNeedsPayment
+	}
+
+	order, err := s.orderRepo.GetOrderByID(orderID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetUserByID(order.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if update.Status == models.OrderStatusShipped {
+		if err := s.orderRepo.UpdateOrderShipping(tx, order.ID, update.Carrier, update.TrackingNumber); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		order.Carrier = update.Carrier
+		order.TrackingNumber = update.TrackingNumber
+	}
+
+	if err := transitionOrder(tx, s.orderRepo, s.outboxRepo, order, user, update.Status, actor, update.Note); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return s.GetOrderDetails(order.ID)
+}
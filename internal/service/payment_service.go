@@ -0,0 +1,29 @@
+package service
+
+import (
+	"errors"
+	"game-store-api/internal/models"
+	"game-store-api/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var ErrPaymentNotFound = errors.New("payment not found")
+
+type PaymentService struct {
+	repo repository.PaymentRepository
+}
+
+func NewPaymentService(repo repository.PaymentRepository) *PaymentService {
+	return &PaymentService{repo: repo}
+}
+
+// GetPaymentByTransactionID looks up a recorded charge by the payment
+// service's transaction ID, for reconciliation.
+func (s *PaymentService) GetPaymentByTransactionID(transactionID string) (*models.Payment, error) {
+	payment, err := s.repo.GetPaymentByTransactionID(transactionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPaymentNotFound
+	}
+	return payment, err
+}
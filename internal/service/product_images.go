@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"game-store-api/internal/media"
+	"game-store-api/internal/models"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrImageNotFound    = errors.New("image not found")
+	ErrInvalidImageKind = errors.New("kind must be cover or screenshot")
+	ErrTooManyImages    = fmt.Errorf("a product can have at most %d screenshots", maxScreenshots)
+	ErrUnsupportedImage = media.ErrUnsupportedImage
+	ErrImageTooLarge    = media.ErrImageTooLarge
+)
+
+const (
+	maxScreenshots = 12
+	// storageTimeout bounds the uploads and deletions of one request.
+	storageTimeout = 30 * time.Second
+)
+
+// AddImage stores an image of the product with its thumbnail. kind is
+// "cover" or "screenshot", and defaults to a screenshot. A new cover
+// replaces the old one.
+func (s *ProductService) AddImage(productID uint, kind string, data []byte) (*models.ProductImage, error) {
+	if kind == "" {
+		kind = models.ImageKindScreenshot
+	}
+	if kind != models.ImageKindCover && kind != models.ImageKindScreenshot {
+		return nil, ErrInvalidImageKind
+	}
+	processed, err := media.Process(data)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.productRepo.GetProductByID(productID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return nil, err
+	}
+	image := models.ProductImage{
+		ProductID:    productID,
+		Kind:         kind,
+		Key:          fmt.Sprintf("products/%d/%s%s", productID, name, processed.Ext),
+		ThumbnailKey: fmt.Sprintf("products/%d/%s-thumb.jpg", productID, name),
+		ContentType:  processed.ContentType,
+		Size:         len(data),
+		Width:        processed.Width,
+		Height:       processed.Height,
+	}
+
+	// Files go up before the row is written, so an image never points at
+	// files that aren't there
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	if err := s.storage.Put(ctx, image.Key, image.ContentType, data); err != nil {
+		return nil, err
+	}
+	if err := s.storage.Put(ctx, image.ThumbnailKey, "image/jpeg", processed.Thumbnail); err != nil {
+		s.deleteFiles(&image)
+		return nil, err
+	}
+
+	replaced, err := s.saveImage(&image)
+	if err != nil {
+		s.deleteFiles(&image)
+		return nil, err
+	}
+	if replaced != nil {
+		s.deleteFiles(replaced)
+	}
+
+	s.setURLs(&image)
+	return &image, nil
+}
+
+// saveImage adds the image at the end of the product's images, or at the
+// front when it is the cover, and returns the cover it replaced.
+func (s *ProductService) saveImage(image *models.ProductImage) (*models.ProductImage, error) {
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// The product lock serialises uploads, which keeps positions unique
+	if _, err := s.productRepo.GetProductByIDForUpdate(tx, image.ProductID); err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+
+	var replaced *models.ProductImage
+	if image.Kind == models.ImageKindCover {
+		cover, err := s.imageRepo.GetCover(tx, image.ProductID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if cover != nil {
+			if err := s.imageRepo.DeleteImage(tx, cover); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			replaced = cover
+		}
+		image.Position = 0
+	} else {
+		count, err := s.imageRepo.CountScreenshots(tx, image.ProductID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if count >= maxScreenshots {
+			tx.Rollback()
+			return nil, ErrTooManyImages
+		}
+		last, err := s.imageRepo.LastPosition(tx, image.ProductID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		image.Position = last + 1
+	}
+
+	if err := s.imageRepo.CreateImage(tx, image); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return replaced, nil
+}
+
+// DeleteImage removes an image of the product and its files.
+func (s *ProductService) DeleteImage(productID, imageID uint) error {
+	image, err := s.imageRepo.GetImage(productID, imageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrImageNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.imageRepo.DeleteImage(s.db, image); err != nil {
+		return err
+	}
+	s.deleteFiles(image)
+	return nil
+}
+
+// deleteFiles removes the files of an image that is gone or was never saved.
+// A file that can't be removed is only logged; it is no longer referenced.
+func (s *ProductService) deleteFiles(image *models.ProductImage) {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	for _, key := range []string{image.Key, image.ThumbnailKey} {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			slog.Error("Failed to delete image file", "image_id", image.ID, "key", key, "error", err)
+		}
+	}
+}
+
+// setImageURLs fills in where the images of products can be downloaded.
+func (s *ProductService) setImageURLs(products ...*models.Product) {
+	for _, product := range products {
+		for i := range product.Images {
+			s.setURLs(&product.Images[i])
+		}
+	}
+}
+
+func (s *ProductService) setURLs(image *models.ProductImage) {
+	image.URL = s.storage.URL(image.Key)
+	image.ThumbnailURL = s.storage.URL(image.ThumbnailKey)
+}
+
+// randomName makes file names that can't be guessed or collide.
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -1,33 +1,502 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"game-store-api/internal/models"
+	"game-store-api/internal/pricing"
 	"game-store-api/internal/repository"
+	"game-store-api/internal/storage"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrProductNotFound     = errors.New("product not found")
+	ErrDuplicateSKU        = errors.New("a product with this SKU already exists")
+	ErrProductNotDeleted   = errors.New("product is not deleted")
+	ErrUnsupportedCurrency = pricing.ErrUnsupportedCurrency
+	ErrStockManagedByKeys  = errors.New("the stock of a digital product is the number of unused keys; upload keys instead")
+	ErrProductNotDigital   = errors.New("keys can only be added to digital products")
+	ErrNoKeys              = errors.New("no keys to add")
+	ErrInvalidKey          = errors.New("keys must be at most 255 characters")
 )
 
+// maxKeyLength matches the size of the game_keys.code column.
+const maxKeyLength = 255
+
+// KeyUpload is the outcome of adding keys to a product. Duplicates counts
+// the keys the product already had or that appeared twice in the upload.
+type KeyUpload struct {
+	Added      int `json:"added"`
+	Duplicates int `json:"duplicates"`
+	Stock      int `json:"stock"`
+}
+
+// ProductInput holds the fields of a new product. Terms lists the IDs of
+// the product's terms in each taxonomy.
+type ProductInput struct {
+	Name        string
+	Description string
+	SKU         string
+	Price       models.Money
+	Currency    string
+	Stock       int
+	Digital     bool
+	Developer   string
+	ReleaseDate *time.Time
+	AgeRating   string
+	Terms       map[repository.Taxonomy][]uint
+}
+
+// ProductUpdate holds the fields to change on a product. Nil fields are left
+// untouched, and a zero ReleaseDate clears it. Terms replaces the product's
+// terms in each taxonomy it has a key for.
+type ProductUpdate struct {
+	Name        *string
+	Description *string
+	SKU         *string
+	Price       *models.Money
+	Currency    *string
+	Stock       *int
+	Digital     *bool
+	Developer   *string
+	ReleaseDate *time.Time
+	AgeRating   *string
+	Terms       map[repository.Taxonomy][]uint
+}
+
 type ProductService struct {
-	productRepo repository.ProductRepository
+	productRepo     repository.ProductRepository
+	outboxRepo      repository.OutboxRepository
+	keyRepo         repository.GameKeyRepository
+	reservationRepo repository.ReservationRepository
+	taxonomyRepo    repository.TaxonomyRepository
+	imageRepo       repository.ProductImageRepository
+	storage         storage.Storage
+	db              *gorm.DB
+	rates           *pricing.ExchangeRates
 }
 
-func NewProductService(productRepo repository.ProductRepository) *ProductService {
-	return &ProductService{productRepo: productRepo}
+// NewProductService keeps the files of product images in store.
+func NewProductService(productRepo repository.ProductRepository, outboxRepo repository.OutboxRepository, keyRepo repository.GameKeyRepository, reservationRepo repository.ReservationRepository, taxonomyRepo repository.TaxonomyRepository, imageRepo repository.ProductImageRepository, store storage.Storage, db *gorm.DB, rates *pricing.ExchangeRates) *ProductService {
+	return &ProductService{productRepo: productRepo, outboxRepo: outboxRepo, keyRepo: keyRepo, reservationRepo: reservationRepo, taxonomyRepo: taxonomyRepo, imageRepo: imageRepo, storage: store, db: db, rates: rates}
 }
 
-func (s ProductService) CreateProduct(name, description, sku string, price, stock int) error {
+// setAvailable works out the stock of products that carts don't hold.
+func (s *ProductService) setAvailable(products ...*models.Product) error {
+	productIDs := make([]uint, len(products))
+	for i, product := range products {
+		productIDs[i] = product.ID
+	}
+	reserved, err := s.reservationRepo.ReservedQuantities(productIDs, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, product := range products {
+		product.Available = max(product.Stock-reserved[product.ID], 0)
+	}
+	return nil
+}
+
+// CreateProduct adds a product to the catalogue. A digital product starts
+// without stock until keys are uploaded for it.
+func (s *ProductService) CreateProduct(input ProductInput) (*models.Product, error) {
+	if !s.rates.Supports(input.Currency) {
+		return nil, ErrUnsupportedCurrency
+	}
+	if input.Digital && input.Stock != 0 {
+		return nil, ErrStockManagedByKeys
+	}
+
 	product := models.Product{
-		Name:        name,
-		Description: description,
-		Price:       price,
-		Stock:       stock,
-		SKU:         sku,
+		Name:        input.Name,
+		Description: input.Description,
+		Price:       input.Price,
+		Currency:    input.Currency,
+		Stock:       input.Stock,
+		SKU:         input.SKU,
+		Digital:     input.Digital,
+		Developer:   input.Developer,
+		ReleaseDate: input.ReleaseDate,
+		AgeRating:   input.AgeRating,
 	}
-	return s.productRepo.CreateProduct(&product)
+	if err := s.setBasePrice(&product); err != nil {
+		return nil, err
+	}
+
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := s.productRepo.CreateProduct(tx, &product); err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrDuplicateSKU
+		}
+		return nil, err
+	}
+	if err := s.setTerms(tx, product.ID, input.Terms); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return s.GetProductByID(product.ID, "")
 }
 
-func (s *ProductService) GetAllProducts() ([]models.Product, error) {
-	return s.productRepo.GetAllProducts()
+// setBasePrice converts the product's price into the base currency.
+func (s *ProductService) setBasePrice(product *models.Product) error {
+	basePrice, err := s.rates.Convert(product.Price, product.Currency, s.rates.Base)
+	if err != nil {
+		return err
+	}
+	product.BasePrice = basePrice
+	return nil
+}
+
+// RefreshBasePrices recomputes the base price of every product from the
+// current exchange rates. It runs at startup, so changed rates and products
+// saved before base prices existed are priced right in listings. Products
+// in a currency without a rate keep their base price.
+func (s *ProductService) RefreshBasePrices() error {
+	products, err := s.productRepo.ListPrices()
+	if err != nil {
+		return err
+	}
+	for i := range products {
+		product := &products[i]
+		previous := product.BasePrice
+		if err := s.setBasePrice(product); err != nil {
+			slog.Warn("Cannot convert product price to the base currency", "product_id", product.ID, "currency", product.Currency, "error", err)
+			continue
+		}
+		if product.BasePrice == previous {
+			continue
+		}
+		if err := s.productRepo.UpdateBasePrice(product.ID, product.BasePrice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setTerms replaces the product's terms in each taxonomy of terms, failing
+// with ErrUnknownTerm if one of them doesn't exist.
+func (s *ProductService) setTerms(tx *gorm.DB, productID uint, terms map[repository.Taxonomy][]uint) error {
+	for taxonomy, ids := range terms {
+		if len(ids) > 0 {
+			found, err := s.taxonomyRepo.GetTermsByIDs(tx, taxonomy, ids)
+			if err != nil {
+				return err
+			}
+			if missing := missingTerm(ids, found); missing != 0 {
+				return fmt.Errorf("%w: %s has no id %d", ErrUnknownTerm, taxonomy, missing)
+			}
+		}
+		if err := s.taxonomyRepo.SetProductTerms(tx, taxonomy, productID, ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// missingTerm returns the first of ids that isn't in found, or 0.
+func missingTerm(ids []uint, found []models.Term) uint {
+	exists := make(map[uint]bool, len(found))
+	for _, term := range found {
+		exists[term.ID] = true
+	}
+	for _, id := range ids {
+		if !exists[id] {
+			return id
+		}
+	}
+	return 0
+}
+
+// ListProducts returns a page of the catalogue priced in currency. Price
+// bounds in the filter are given in currency too, or in the base currency
+// when it is empty, and compared against the products' base prices, as is
+// the price sort. An empty currency leaves prices as stored.
+func (s *ProductService) ListProducts(filter repository.ProductFilter, currency string) ([]models.Product, int64, error) {
+	if currency != "" {
+		if !s.rates.Supports(currency) {
+			return nil, 0, ErrUnsupportedCurrency
+		}
+		for _, bound := range []*models.Money{filter.MinPrice, filter.MaxPrice} {
+			if bound != nil {
+				converted, err := s.rates.Convert(*bound, currency, s.rates.Base)
+				if err != nil {
+					return nil, 0, err
+				}
+				*bound = converted
+			}
+		}
+	}
+
+	products, total, err := s.productRepo.ListProducts(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	page := make([]*models.Product, len(products))
+	for i := range products {
+		page[i] = &products[i]
+		if currency == "" {
+			continue
+		}
+		if err := s.rates.ConvertProduct(page[i], currency); err != nil {
+			return nil, 0, err
+		}
+	}
+	if err := s.setAvailable(page...); err != nil {
+		return nil, 0, err
+	}
+	s.setImageURLs(page...)
+	return products, total, nil
+}
+
+// GetProductByID finds a product priced in currency, or as stored when currency is empty.
+func (s *ProductService) GetProductByID(id uint, currency string) (*models.Product, error) {
+	product, err := s.productRepo.GetProductByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setAvailable(product); err != nil {
+		return nil, err
+	}
+	s.setImageURLs(product)
+	if currency == "" {
+		return product, nil
+	}
+
+	if err := s.rates.ConvertProduct(product, currency); err != nil {
+		return nil, err
+	}
+	return product, nil
 }
 
-func (s *ProductService) GetProductByID(id uint) (*models.Product, error) {
-	return s.productRepo.GetProductByID(id)
+func (s *ProductService) UpdateProduct(id uint, update ProductUpdate) (*models.Product, error) {
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	product, err := s.productRepo.GetProductByIDForUpdate(tx, id)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+
+	previousStock := product.Stock
+	previousPrice, previousCurrency := product.Price, product.Currency
+	if update.Name != nil {
+		product.Name = *update.Name
+	}
+	if update.Description != nil {
+		product.Description = *update.Description
+	}
+	if update.SKU != nil {
+		product.SKU = *update.SKU
+	}
+	if update.Price != nil {
+		product.Price = *update.Price
+	}
+	if update.Currency != nil {
+		if !s.rates.Supports(*update.Currency) {
+			tx.Rollback()
+			return nil, ErrUnsupportedCurrency
+		}
+		product.Currency = *update.Currency
+	}
+	if err := s.setBasePrice(product); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if update.Digital != nil {
+		product.Digital = *update.Digital
+	}
+	if update.Developer != nil {
+		product.Developer = *update.Developer
+	}
+	if update.ReleaseDate != nil {
+		product.ReleaseDate = update.ReleaseDate
+		if update.ReleaseDate.IsZero() {
+			product.ReleaseDate = nil
+		}
+	}
+	if update.AgeRating != nil {
+		product.AgeRating = *update.AgeRating
+	}
+	if product.Digital {
+		if update.Stock != nil && *update.Stock != previousStock {
+			tx.Rollback()
+			return nil, ErrStockManagedByKeys
+		}
+		available, err := s.keyRepo.CountAvailableKeys(tx, product.ID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		product.Stock = int(available)
+	} else if update.Stock != nil {
+		product.Stock = *update.Stock
+	}
+
+	if err := s.productRepo.UpdateProduct(tx, product); err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrDuplicateSKU
+		}
+		return nil, err
+	}
+
+	if err := s.setTerms(tx, product.ID, update.Terms); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := recordStockChange(tx, s.outboxRepo, product, previousStock); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := recordPriceChange(tx, s.outboxRepo, product, previousPrice, previousCurrency); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return s.GetProductByID(product.ID, "")
+}
+
+// AddKeys uploads keys for a digital product and sets its stock to the keys
+// now available. Blank codes are skipped, and codes the product already has
+// are counted as duplicates instead of failing the upload.
+func (s *ProductService) AddKeys(productID uint, codes []string) (*KeyUpload, error) {
+	seen := make(map[string]bool, len(codes))
+	var keys []models.GameKey
+	duplicates := 0
+	for _, code := range codes {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue
+		}
+		if len(code) > maxKeyLength {
+			return nil, ErrInvalidKey
+		}
+		if seen[code] {
+			duplicates++
+			continue
+		}
+		seen[code] = true
+		keys = append(keys, models.GameKey{ProductID: productID, Code: code})
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	product, err := s.productRepo.GetProductByIDForUpdate(tx, productID)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	if !product.Digital {
+		tx.Rollback()
+		return nil, ErrProductNotDigital
+	}
+
+	added, err := s.keyRepo.AddKeys(tx, keys)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	available, err := s.keyRepo.CountAvailableKeys(tx, product.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	previousStock := product.Stock
+	product.Stock = int(available)
+	if err := s.productRepo.UpdateProduct(tx, product); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := recordStockChange(tx, s.outboxRepo, product, previousStock); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return &KeyUpload{
+		Added:      int(added),
+		Duplicates: duplicates + len(keys) - int(added),
+		Stock:      product.Stock,
+	}, nil
+}
+
+func (s *ProductService) DeleteProduct(id uint) error {
+	deleted, err := s.productRepo.DeleteProduct(id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrProductNotFound
+	}
+	return nil
+}
+
+func (s *ProductService) RestoreProduct(id uint) (*models.Product, error) {
+	product, err := s.productRepo.GetProductByIDUnscoped(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !product.DeletedAt.Valid {
+		return nil, ErrProductNotDeleted
+	}
+
+	if err := s.productRepo.RestoreProduct(product); err != nil {
+		return nil, err
+	}
+	product.DeletedAt = gorm.DeletedAt{}
+	if err := s.setAvailable(product); err != nil {
+		return nil, err
+	}
+	s.setImageURLs(product)
+	return product, nil
 }
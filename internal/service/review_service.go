@@ -0,0 +1,217 @@
+package service
+
+import (
+	"errors"
+	"game-store-api/internal/models"
+	"game-store-api/internal/repository"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrReviewNotFound   = errors.New("review not found")
+	ErrAlreadyReviewed  = errors.New("you have already reviewed this product; edit your review instead")
+	ErrNotPurchased     = errors.New("only customers who bought this product can review it")
+	ErrNotReviewAuthor  = errors.New("you can only change your own review")
+	ErrInvalidRating    = errors.New("rating must be between 1 and 5")
+	ErrReviewNotVisible = errors.New("review is already hidden")
+	ErrReviewNotHidden  = errors.New("review is not hidden")
+)
+
+type ReviewService struct {
+	reviewRepo  repository.ReviewRepository
+	productRepo repository.ProductRepository
+	db          *gorm.DB
+}
+
+func NewReviewService(reviewRepo repository.ReviewRepository, productRepo repository.ProductRepository, db *gorm.DB) *ReviewService {
+	return &ReviewService{reviewRepo: reviewRepo, productRepo: productRepo, db: db}
+}
+
+// ListReviews returns a page of the product's visible reviews, newest first.
+func (s *ReviewService) ListReviews(productID uint, page, limit int) ([]models.Review, int64, error) {
+	if _, err := s.productRepo.GetProductByID(productID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, ErrProductNotFound
+		}
+		return nil, 0, err
+	}
+	return s.reviewRepo.ListVisibleReviews(productID, limit, (page-1)*limit)
+}
+
+// ListAllReviews lists reviews for moderation, hidden ones included.
+func (s *ReviewService) ListAllReviews(filter repository.ReviewFilter) ([]models.Review, int64, error) {
+	return s.reviewRepo.ListReviews(filter)
+}
+
+// CreateReview adds the user's review of a product they have paid for.
+func (s *ReviewService) CreateReview(userID, productID uint, rating int, comment string) (*models.Review, error) {
+	if rating < 1 || rating > 5 {
+		return nil, ErrInvalidRating
+	}
+	if _, err := s.productRepo.GetProductByID(productID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	purchased, err := s.reviewRepo.HasPurchased(userID, productID)
+	if err != nil {
+		return nil, err
+	}
+	if !purchased {
+		return nil, ErrNotPurchased
+	}
+
+	review := &models.Review{ProductID: productID, UserID: userID, Rating: rating, Comment: comment}
+	err = s.changeReview(productID, func(tx *gorm.DB) error {
+		err := s.reviewRepo.CreateReview(tx, review)
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrAlreadyReviewed
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// UpdateReview changes the rating and comment of the user's own review. A
+// hidden review stays hidden.
+func (s *ReviewService) UpdateReview(userID, productID, reviewID uint, rating int, comment string) (*models.Review, error) {
+	if rating < 1 || rating > 5 {
+		return nil, ErrInvalidRating
+	}
+	review, err := s.ownReview(userID, productID, reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	review.Rating = rating
+	review.Comment = comment
+	err = s.changeReview(productID, func(tx *gorm.DB) error {
+		return s.reviewRepo.UpdateReview(tx, review)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// DeleteReview removes the user's own review, after which they may write a
+// new one.
+func (s *ReviewService) DeleteReview(userID, productID, reviewID uint) error {
+	review, err := s.ownReview(userID, productID, reviewID)
+	if err != nil {
+		return err
+	}
+	return s.changeReview(productID, func(tx *gorm.DB) error {
+		return s.reviewRepo.DeleteReview(tx, review)
+	})
+}
+
+// HideReview takes a review out of listings and of its product's rating.
+func (s *ReviewService) HideReview(reviewID uint, reason string) (*models.Review, error) {
+	review, err := s.getReview(reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review.HiddenAt != nil {
+		return nil, ErrReviewNotVisible
+	}
+
+	now := time.Now()
+	review.HiddenAt = &now
+	review.HiddenReason = reason
+	err = s.changeReview(review.ProductID, func(tx *gorm.DB) error {
+		return s.reviewRepo.UpdateReview(tx, review)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// UnhideReview puts a hidden review back.
+func (s *ReviewService) UnhideReview(reviewID uint) (*models.Review, error) {
+	review, err := s.getReview(reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review.HiddenAt == nil {
+		return nil, ErrReviewNotHidden
+	}
+
+	review.HiddenAt = nil
+	review.HiddenReason = ""
+	err = s.changeReview(review.ProductID, func(tx *gorm.DB) error {
+		return s.reviewRepo.UpdateReview(tx, review)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+func (s *ReviewService) getReview(reviewID uint) (*models.Review, error) {
+	review, err := s.reviewRepo.GetReviewByID(reviewID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrReviewNotFound
+	}
+	return review, err
+}
+
+// ownReview finds a review of the product, failing with ErrNotReviewAuthor
+// when someone else wrote it.
+func (s *ReviewService) ownReview(userID, productID, reviewID uint) (*models.Review, error) {
+	review, err := s.reviewRepo.GetReview(productID, reviewID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if review.UserID != userID {
+		return nil, ErrNotReviewAuthor
+	}
+	return review, nil
+}
+
+// changeReview applies change to a review of the product and updates the
+// product's rating in the same transaction. The product lock keeps
+// concurrent reviews from writing a stale rating.
+func (s *ReviewService) changeReview(productID uint, change func(tx *gorm.DB) error) error {
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err := s.productRepo.GetProductByIDForUpdate(tx, productID); err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrProductNotFound
+		}
+		return err
+	}
+	if err := change(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	average, count, err := s.reviewRepo.RatingSummary(tx, productID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := s.productRepo.UpdateRating(tx, productID, math.Round(average*100)/100, count); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
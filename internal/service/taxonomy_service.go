@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"game-store-api/internal/models"
+	"game-store-api/internal/repository"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTermNotFound  = errors.New("term not found")
+	ErrDuplicateTerm = errors.New("a term with this slug already exists")
+	ErrInvalidSlug   = errors.New("slugs are lowercase letters and digits separated by single hyphens")
+	ErrUnknownTerm   = errors.New("unknown term")
+)
+
+var (
+	slugPattern   = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	slugSeparator = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Slugify turns a term name such as "Role-Playing (RPG)" into a slug such
+// as "role-playing-rpg".
+func Slugify(name string) string {
+	return strings.Trim(slugSeparator.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// TaxonomyService manages the terms of the catalogue's taxonomies:
+// categories, platforms and publishers.
+type TaxonomyService struct {
+	taxonomyRepo repository.TaxonomyRepository
+	db           *gorm.DB
+}
+
+func NewTaxonomyService(taxonomyRepo repository.TaxonomyRepository, db *gorm.DB) *TaxonomyService {
+	return &TaxonomyService{taxonomyRepo: taxonomyRepo, db: db}
+}
+
+func (s *TaxonomyService) ListTerms(taxonomy repository.Taxonomy) ([]models.Term, error) {
+	return s.taxonomyRepo.ListTerms(taxonomy)
+}
+
+// CreateTerm adds a term. An empty slug is made from the name.
+func (s *TaxonomyService) CreateTerm(taxonomy repository.Taxonomy, name, slug string) (*models.Term, error) {
+	term, err := newTerm(name, slug)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.taxonomyRepo.CreateTerm(taxonomy, term); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrDuplicateTerm
+		}
+		return nil, err
+	}
+	return term, nil
+}
+
+// UpdateTerm renames a term. An empty slug is made from the name.
+func (s *TaxonomyService) UpdateTerm(taxonomy repository.Taxonomy, id uint, name, slug string) (*models.Term, error) {
+	term, err := newTerm(name, slug)
+	if err != nil {
+		return nil, err
+	}
+	term.ID = id
+
+	found, err := s.taxonomyRepo.UpdateTerm(taxonomy, term)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrDuplicateTerm
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrTermNotFound
+	}
+	return term, nil
+}
+
+// DeleteTerm deletes a term and takes it off every product and coupon.
+func (s *TaxonomyService) DeleteTerm(taxonomy repository.Taxonomy, id uint) error {
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	deleted, err := s.taxonomyRepo.DeleteTerm(tx, taxonomy, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !deleted {
+		tx.Rollback()
+		return ErrTermNotFound
+	}
+	return tx.Commit().Error
+}
+
+func newTerm(name, slug string) (*models.Term, error) {
+	name = strings.TrimSpace(name)
+	if slug == "" {
+		slug = Slugify(name)
+	}
+	if !slugPattern.MatchString(slug) {
+		return nil, ErrInvalidSlug
+	}
+	return &models.Term{Name: name, Slug: slug}, nil
+}
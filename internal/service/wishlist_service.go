@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"game-store-api/internal/models"
+	"game-store-api/internal/pricing"
+	"game-store-api/internal/repository"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrNotInWishlist = errors.New("product is not in your wishlist")
+
+type WishlistService struct {
+	wishlistRepo    repository.WishlistRepository
+	productRepo     repository.ProductRepository
+	reservationRepo repository.ReservationRepository
+	emailQueue      EmailQueue
+	rates           *pricing.ExchangeRates
+}
+
+func NewWishlistService(
+	wishlistRepo repository.WishlistRepository,
+	productRepo repository.ProductRepository,
+	reservationRepo repository.ReservationRepository,
+	emailQueue EmailQueue,
+	rates *pricing.ExchangeRates) *WishlistService {
+	return &WishlistService{
+		wishlistRepo:    wishlistRepo,
+		productRepo:     productRepo,
+		reservationRepo: reservationRepo,
+		emailQueue:      emailQueue,
+		rates:           rates,
+	}
+}
+
+// ListWishlist returns a page of the user's wishlist with the products
+// priced in currency, or as stored when currency is empty.
+func (s *WishlistService) ListWishlist(userID uint, currency string, page, limit int) ([]models.WishlistItem, int64, error) {
+	if currency != "" && !s.rates.Supports(currency) {
+		return nil, 0, ErrUnsupportedCurrency
+	}
+	items, total, err := s.wishlistRepo.ListItems(userID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(items) == 0 {
+		return items, total, nil
+	}
+
+	productIDs := make([]uint, len(items))
+	for i, item := range items {
+		productIDs[i] = item.ProductID
+	}
+	reserved, err := s.reservationRepo.ReservedQuantities(productIDs, time.Now())
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range items {
+		product := &items[i].Product
+		product.Available = max(product.Stock-reserved[product.ID], 0)
+		if currency == "" {
+			continue
+		}
+		if err := s.rates.ConvertProduct(product, currency); err != nil {
+			return nil, 0, err
+		}
+	}
+	return items, total, nil
+}
+
+func (s *WishlistService) AddToWishlist(userID, productID uint) error {
+	if _, err := s.productRepo.GetProductByID(productID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrProductNotFound
+		}
+		return err
+	}
+	return s.wishlistRepo.AddItem(&models.WishlistItem{UserID: userID, ProductID: productID})
+}
+
+func (s *WishlistService) RemoveFromWishlist(userID, productID uint) error {
+	removed, err := s.wishlistRepo.RemoveItem(userID, productID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrNotInWishlist
+	}
+	return nil
+}
+
+// SendBackInStockAlerts emails everyone wishing for the product that it is
+// back, unless none of it is available by the time the event is handled,
+// because it sold out again or carts hold all of it.
+func (s *WishlistService) SendBackInStockAlerts(eventID, productID uint) error {
+	product, err := s.currentProduct(productID)
+	if err != nil || product == nil {
+		return err
+	}
+	reserved, err := s.reservationRepo.ReservedQuantities([]uint{product.ID}, time.Now())
+	if err != nil {
+		return err
+	}
+	if product.Stock-reserved[product.ID] <= 0 {
+		return nil
+	}
+	return s.sendAlerts(eventID, product, map[string]string{
+		"type":         "wishlist_back_in_stock",
+		"product_id":   fmt.Sprintf("%d", product.ID),
+		"product_name": product.Name,
+		"price":        product.Price.Format(product.Currency),
+		"currency":     product.Currency,
+	})
+}
+
+// SendPriceDropAlerts emails everyone wishing for the product when change
+// lowered its price, as long as the price is still below what it was.
+func (s *WishlistService) SendPriceDropAlerts(eventID uint, change models.PriceChangedEvent) error {
+	if change.Currency != change.PreviousCurrency || change.Price >= change.PreviousPrice {
+		return nil
+	}
+	product, err := s.currentProduct(change.ProductID)
+	if err != nil || product == nil {
+		return err
+	}
+	if product.Currency != change.PreviousCurrency || product.Price >= change.PreviousPrice {
+		return nil
+	}
+	return s.sendAlerts(eventID, product, map[string]string{
+		"type":           "wishlist_price_drop",
+		"product_id":     fmt.Sprintf("%d", product.ID),
+		"product_name":   product.Name,
+		"price":          product.Price.Format(product.Currency),
+		"previous_price": change.PreviousPrice.Format(change.PreviousCurrency),
+		"currency":       product.Currency,
+	})
+}
+
+// currentProduct loads a product for an alert, or nil when it was deleted.
+func (s *WishlistService) currentProduct(productID uint) (*models.Product, error) {
+	product, err := s.productRepo.GetProductByID(productID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return product, err
+}
+
+// sendAlerts queues task for every user wishing for the product who hasn't
+// had it for this event yet. An alert is recorded only once its email is
+// queued, so a retry after a failure emails everyone left, and at worst
+// emails again a user whose alert couldn't be recorded.
+func (s *WishlistService) sendAlerts(eventID uint, product *models.Product, task map[string]string) error {
+	if s.emailQueue == nil {
+		slog.Warn("Email queue unavailable, wishlist alerts not sent", "type", task["type"], "product_id", product.ID)
+		return nil
+	}
+	users, err := s.wishlistRepo.GetWishers(product.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		sent, err := s.wishlistRepo.HasAlert(eventID, user.ID)
+		if err != nil {
+			return err
+		}
+		if sent {
+			continue
+		}
+
+		email := make(map[string]string, len(task)+2)
+		for k, v := range task {
+			email[k] = v
+		}
+		email["email"] = user.Email
+		email["user_id"] = fmt.Sprintf("%d", user.ID)
+		if err := s.emailQueue.Enqueue(email); err != nil {
+			slog.Error("Failed to enqueue wishlist alert", "type", task["type"], "user_id", user.ID, "error", err)
+			return err
+		}
+		if err := s.wishlistRepo.RecordAlert(eventID, user.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Package storage keeps uploaded files, such as product images, on local disk
+// or in an S3-compatible object store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"strings"
+)
+
+// ErrInvalidKey is returned for keys that are empty, absolute or step out of
+// the store with "..".
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Storage saves files under slash-separated keys like "products/1/cover.png"
+// and tells where they can be downloaded from.
+type Storage interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Delete removes the file under key. Deleting a missing file is not an error.
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
+
+// FromEnv picks the Storage configured by STORAGE:
+//
+//   - "s3": S3Storage using S3_ENDPOINT, S3_BUCKET, S3_REGION,
+//     S3_ACCESS_KEY_ID, S3_SECRET_ACCESS_KEY and S3_PUBLIC_URL
+//   - anything else: LocalStorage writing to MEDIA_DIR, to be served under
+//     MEDIA_URL
+func FromEnv() (Storage, error) {
+	switch os.Getenv("STORAGE") {
+	case "s3":
+		return &S3Storage{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Region:          envOr("S3_REGION", "us-east-1"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PublicURL:       os.Getenv("S3_PUBLIC_URL"),
+		}, nil
+	default:
+		return NewLocalStorage(envOr("MEDIA_DIR", "media"), envOr("MEDIA_URL", "/media"))
+	}
+}
+
+// checkKey rejects keys that don't stay inside the store.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
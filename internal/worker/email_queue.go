@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"game-store-api/internal/jobs"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SendEmailJob is the job type the email worker handles. Its payload is the
+// task map, e.g. {"type": "welcome_email", "email": ...}.
+const SendEmailJob = "send_email"
+
+// LegacyEmailQueueKey is the plain Redis list email tasks were pushed to
+// before the job queue.
+const LegacyEmailQueueKey = "send_email_queue"
+
+// EmailQueue adds email tasks to a job queue.
+type EmailQueue struct {
+	queue *jobs.Queue
+}
+
+func NewEmailQueue(queue *jobs.Queue) *EmailQueue {
+	return &EmailQueue{queue: queue}
+}
+
+// Enqueue adds a task such as {"type": "welcome_email", "email": ...}.
+func (q *EmailQueue) Enqueue(task map[string]string) error {
+	_, err := q.queue.Enqueue(context.Background(), SendEmailJob, task)
+	return err
+}
+
+// MigrateLegacyEmailQueue moves tasks still waiting on the legacy list into
+// the job queue, so nothing queued before an upgrade is lost.
+func MigrateLegacyEmailQueue(ctx context.Context, client *redis.Client, queue *jobs.Queue) error {
+	moved := 0
+	for {
+		raw, err := client.LPop(ctx, LegacyEmailQueueKey).Result()
+		if errors.Is(err, redis.Nil) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var task map[string]string
+		if err := json.Unmarshal([]byte(raw), &task); err != nil {
+			slog.Error("Dropping unreadable legacy email task", "error", err, "raw_data", raw)
+			continue
+		}
+		if _, err := queue.Enqueue(ctx, SendEmailJob, task); err != nil {
+			// Put it back for the next attempt
+			client.LPush(ctx, LegacyEmailQueueKey, raw)
+			return err
+		}
+		moved++
+	}
+
+	if moved > 0 {
+		slog.Info("Migrated legacy email tasks", "count", moved)
+	}
+	return nil
+}
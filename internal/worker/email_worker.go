@@ -2,40 +2,54 @@ package worker
 
 import (
 	"context"
-	"encoding/json"
+	"game-store-api/internal/jobs"
+	"game-store-api/internal/mailer"
 	"log/slog"
 	"time"
-
-	"github.com/redis/go-redis/v9"
 )
 
-func StartEmailWorker(redisClient *redis.Client) {
-	slog.Info("Email Worker started")
-
-	for {
-		ctx := context.Background()
-		result, err := redisClient.BLPop(ctx, 0, "send_email_queue").Result()
+// StartEmailWorker starts concurrency workers that run email jobs from queue
+// until ctx is cancelled, rendering each task with templates and delivering
+// it through m. Wait on the returned pool to let in-flight emails finish.
+func StartEmailWorker(ctx context.Context, queue *jobs.Queue, concurrency int, m mailer.Mailer, templates *mailer.Templates) *jobs.Pool {
+	slog.Info("Email Worker started", "queue", queue.Name(), "concurrency", concurrency)
 
-		if err != nil {
-			slog.Error("Worker failed to pop task", "error", err)
-			continue
-		}
-
-		taskJSON := result[1]
+	return queue.Start(ctx, concurrency, map[string]jobs.Handler{
+		SendEmailJob: EmailHandler(m, templates),
+	})
+}
 
+// EmailHandler is the job handler for SendEmailJob. Tasks that can't be
+// decoded or rendered fail permanently; delivery errors are retried.
+func EmailHandler(m mailer.Mailer, templates *mailer.Templates) jobs.Handler {
+	return func(ctx context.Context, job *jobs.Job) error {
 		var task map[string]string
-		if err := json.Unmarshal([]byte(taskJSON), &task); err != nil {
-			slog.Error("Worker failed to parse task", "error", err, "raw_data", taskJSON)
-			continue
+		if err := job.Decode(&task); err != nil {
+			return jobs.Permanent(err)
 		}
 		slog.Info("Processing email",
 			"email", task["email"],
 			"type", task["type"],
+			"attempt", job.Attempts,
 		)
 
-		// Simulate work
-		time.Sleep(2 * time.Second)
+		if err := SendEmail(ctx, m, templates, task); err != nil {
+			return err
+		}
 
 		slog.Info("Email sent successfully", "email", task["email"])
+		return nil
 	}
 }
+
+// SendEmail renders a single task and delivers it.
+func SendEmail(ctx context.Context, m mailer.Mailer, templates *mailer.Templates, task map[string]string) error {
+	msg, err := templates.Render(task)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	return m.Send(ctx, msg)
+}
@@ -0,0 +1,142 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"game-store-api/internal/jobs"
+	"game-store-api/internal/models"
+	"log/slog"
+)
+
+// EmailEnqueuer queues an email task for the email worker.
+type EmailEnqueuer interface {
+	Enqueue(task map[string]string) error
+}
+
+// AccountEmails sends the emails that need an account's signed tokens.
+type AccountEmails interface {
+	SendWelcomeEmail(userID uint) error
+	SendVerificationEmail(userID uint) error
+}
+
+// WishlistAlerts emails the users who wish for a product about news on it.
+type WishlistAlerts interface {
+	SendBackInStockAlerts(eventID, productID uint) error
+	SendPriceDropAlerts(eventID uint, change models.PriceChangedEvent) error
+}
+
+// PaymentSettler settles payments left pending with the payment service.
+type PaymentSettler interface {
+	SettlePayment(paymentID uint) error
+}
+
+// orderEmails maps each order event to the email it sends the customer.
+// Fulfilment is internal, so it sends none.
+var orderEmails = map[string]string{
+	models.EventOrderPaid:      "order_confirmation",
+	models.EventOrderFulfilled: "",
+	models.EventOrderShipped:   "order_shipped",
+	models.EventOrderDelivered: "order_delivered",
+	models.EventOrderCancelled: "order_cancelled",
+	models.EventOrderRefunded:  "order_refunded",
+}
+
+// EventHandlers returns the job handlers for the events the outbox relay
+// publishes.
+func EventHandlers(emails EmailEnqueuer, accounts AccountEmails, wishlists WishlistAlerts, payments PaymentSettler) map[string]jobs.Handler {
+	handlers := map[string]jobs.Handler{
+		models.EventUserRegistered: func(ctx context.Context, job *jobs.Job) error {
+			var data models.UserRegisteredEvent
+			if _, err := decodeEvent(job, &data); err != nil {
+				return err
+			}
+			if err := accounts.SendWelcomeEmail(data.UserID); err != nil {
+				return err
+			}
+			return accounts.SendVerificationEmail(data.UserID)
+		},
+
+		models.EventStockChanged: func(ctx context.Context, job *jobs.Job) error {
+			var data models.StockChangedEvent
+			event, err := decodeEvent(job, &data)
+			if err != nil {
+				return err
+			}
+			slog.Debug("Stock changed", "event_id", event.EventID, "product_id", data.ProductID, "stock", data.Stock)
+			if data.PreviousStock > 0 || data.Stock <= 0 {
+				return nil
+			}
+			return wishlists.SendBackInStockAlerts(event.EventID, data.ProductID)
+		},
+
+		models.EventPriceChanged: func(ctx context.Context, job *jobs.Job) error {
+			var data models.PriceChangedEvent
+			event, err := decodeEvent(job, &data)
+			if err != nil {
+				return err
+			}
+			return wishlists.SendPriceDropAlerts(event.EventID, data)
+		},
+
+		models.EventPaymentPending: func(ctx context.Context, job *jobs.Job) error {
+			var data models.PaymentPendingEvent
+			if _, err := decodeEvent(job, &data); err != nil {
+				return err
+			}
+			return payments.SettlePayment(data.PaymentID)
+		},
+	}
+
+	for eventType, emailType := range orderEmails {
+		handlers[eventType] = orderEmailHandler(emails, emailType)
+	}
+	return handlers
+}
+
+func orderEmailHandler(emails EmailEnqueuer, emailType string) jobs.Handler {
+	return func(ctx context.Context, job *jobs.Job) error {
+		var data models.OrderEvent
+		if _, err := decodeEvent(job, &data); err != nil {
+			return err
+		}
+		if emailType == "" {
+			return nil
+		}
+		// The customer already saw a declined checkout fail
+		if data.Status == models.OrderStatusCancelled && data.PreviousStatus == models.OrderStatusPending {
+			return nil
+		}
+		return sendOrderEmail(emails, emailType, data)
+	}
+}
+
+// decodeEvent unpacks a published event and its data. Events that can't be
+// decoded never will be, so the error is permanent.
+func decodeEvent(job *jobs.Job, data interface{}) (*Event, error) {
+	var event Event
+	if err := job.Decode(&event); err != nil {
+		return nil, jobs.Permanent(fmt.Errorf("decode event: %w", err))
+	}
+	if err := json.Unmarshal(event.Data, data); err != nil {
+		return nil, jobs.Permanent(fmt.Errorf("decode %s event data: %w", event.Type, err))
+	}
+	return &event, nil
+}
+
+func sendOrderEmail(emails EmailEnqueuer, emailType string, data models.OrderEvent) error {
+	if emails == nil {
+		slog.Warn("Email queue unavailable, email not sent", "type", emailType, "order_id", data.OrderID)
+		return nil
+	}
+	return emails.Enqueue(map[string]string{
+		"type":            emailType,
+		"email":           data.Email,
+		"user_id":         fmt.Sprintf("%d", data.UserID),
+		"order_id":        fmt.Sprintf("%d", data.OrderID),
+		"total":           data.Total.Format(data.Currency),
+		"currency":        data.Currency,
+		"carrier":         data.Carrier,
+		"tracking_number": data.TrackingNumber,
+	})
+}
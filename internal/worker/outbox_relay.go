@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"game-store-api/internal/jobs"
+	"game-store-api/internal/repository"
+	"log/slog"
+	"time"
+)
+
+// Event is the job payload the outbox relay publishes for each outbox event.
+// Delivery is at least once, so handlers may see the same EventID twice.
+type Event struct {
+	EventID    uint            `json:"event_id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+const (
+	relayBatchSize = 100
+	// Published events are kept this long for debugging, then deleted.
+	relayRetention     = 7 * 24 * time.Hour
+	relayPruneInterval = time.Hour
+)
+
+// OutboxRelay publishes committed outbox events to a job queue, one job per
+// event with the event type as the job type.
+type OutboxRelay struct {
+	repo  repository.OutboxRepository
+	queue *jobs.Queue
+}
+
+func NewOutboxRelay(repo repository.OutboxRepository, queue *jobs.Queue) *OutboxRelay {
+	return &OutboxRelay{repo: repo, queue: queue}
+}
+
+// PublishPending publishes up to one batch of unpublished events in the order
+// they were written and reports how many went out. It stops at the first
+// event that can't be published, so later events never overtake it.
+func (r *OutboxRelay) PublishPending(ctx context.Context) (int, error) {
+	events, err := r.repo.GetPendingEvents(relayBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, event := range events {
+		_, err := r.queue.Enqueue(ctx, event.Type, Event{
+			EventID:    event.ID,
+			Type:       event.Type,
+			OccurredAt: event.CreatedAt,
+			Data:       json.RawMessage(event.Payload),
+		})
+		if err != nil {
+			if markErr := r.repo.MarkEventFailed(event.ID, err.Error()); markErr != nil {
+				slog.Error("Failed to record outbox publish failure", "event_id", event.ID, "error", markErr)
+			}
+			return published, err
+		}
+
+		// If this fails the event is published again on the next run
+		if err := r.repo.MarkEventPublished(event.ID, time.Now()); err != nil {
+			return published, err
+		}
+		published++
+	}
+	return published, nil
+}
+
+// Run publishes events every interval until ctx is cancelled.
+func (r *OutboxRelay) Run(ctx context.Context, interval time.Duration) {
+	slog.Info("Outbox relay started", "queue", r.queue.Name())
+
+	var lastPrune time.Time
+	for ctx.Err() == nil {
+		published, err := r.PublishPending(ctx)
+		if err != nil {
+			slog.Error("Failed to publish outbox events", "error", err)
+		}
+
+		if time.Since(lastPrune) > relayPruneInterval {
+			if _, err := r.repo.DeletePublishedEvents(time.Now().Add(-relayRetention)); err != nil {
+				slog.Error("Failed to prune published outbox events", "error", err)
+			}
+			lastPrune = time.Now()
+		}
+
+		// A full batch means there are probably more waiting
+		if err == nil && published == relayBatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(interval):
+		}
+	}
+}
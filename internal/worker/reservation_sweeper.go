@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"context"
+	"game-store-api/internal/repository"
+	"log/slog"
+	"time"
+)
+
+// ReservationSweeper deletes cart reservations that have expired. Expired
+// reservations already stop counting against stock; sweeping them only
+// keeps the table small.
+type ReservationSweeper struct {
+	repo repository.ReservationRepository
+}
+
+func NewReservationSweeper(repo repository.ReservationRepository) *ReservationSweeper {
+	return &ReservationSweeper{repo: repo}
+}
+
+// Sweep deletes the reservations expired by now and reports how many went.
+func (s *ReservationSweeper) Sweep(now time.Time) (int64, error) {
+	return s.repo.DeleteExpired(now)
+}
+
+// Run sweeps every interval until ctx is cancelled.
+func (s *ReservationSweeper) Run(ctx context.Context, interval time.Duration) {
+	slog.Info("Reservation sweeper started", "interval", interval)
+
+	for ctx.Err() == nil {
+		swept, err := s.Sweep(time.Now())
+		if err != nil {
+			slog.Error("Failed to expire cart reservations", "error", err)
+		} else if swept > 0 {
+			slog.Info("Expired cart reservations", "count", swept)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(interval):
+		}
+	}
+}
@@ -25,8 +25,7 @@ func main() {
 
 	req := &pb.PaymentRequest{
 		OrderId:        101,
-		Amount:         99.50,
-		Currency:       "USD",
+		Amount:         &pb.Money{AmountMinor: 9950, Currency: "USD"},
 		CredCardNumber: "1234-5678-9012-3456",
 	}
 
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	pb "go-grpc-payment/proto/payment"
+)
+
+var (
+	errTransactionNotFound = errors.New("transaction not found")
+	errRefundExceedsAmount = errors.New("refund exceeds the remaining balance")
+	errNotRefundable       = errors.New("payment can no longer be refunded")
+	errNotVoidable         = errors.New("only untouched captured payments can be voided")
+	errCurrencyMismatch    = errors.New("refund currency does not match the payment")
+)
+
+// ledgerEntry is the record of one captured payment. Amounts are in the
+// minor unit of Currency.
+type ledgerEntry struct {
+	TransactionID string
+	OrderID       int64
+	Amount        int64
+	Currency      string
+	Refunded      int64
+	Status        pb.PaymentStatus
+}
+
+// ledger is an in-memory store of payments. It is lost on restart, which is
+// fine for a simulator but would be a database in a real provider.
+type ledger struct {
+	mu      sync.Mutex
+	seq     int64
+	entries map[string]*ledgerEntry
+}
+
+func newLedger() *ledger {
+	return &ledger{entries: make(map[string]*ledgerEntry)}
+}
+
+func (l *ledger) capture(orderID, amount int64, currency string) ledgerEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	entry := &ledgerEntry{
+		TransactionID: fmt.Sprintf("TXN-%d-%d", orderID, l.seq),
+		OrderID:       orderID,
+		Amount:        amount,
+		Currency:      currency,
+		Status:        pb.PaymentStatus_PAYMENT_STATUS_CAPTURED,
+	}
+	l.entries[entry.TransactionID] = entry
+	return *entry
+}
+
+// refund returns amount (or the whole remaining balance when amount is zero)
+// to the customer. An empty currency means the payment's own currency.
+func (l *ledger) refund(transactionID string, amount int64, currency string) (ledgerEntry, int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[transactionID]
+	if !ok {
+		return ledgerEntry{}, 0, errTransactionNotFound
+	}
+	if entry.Status != pb.PaymentStatus_PAYMENT_STATUS_CAPTURED &&
+		entry.Status != pb.PaymentStatus_PAYMENT_STATUS_PARTIALLY_REFUNDED {
+		return *entry, 0, errNotRefundable
+	}
+	if currency != "" && currency != entry.Currency {
+		return *entry, 0, errCurrencyMismatch
+	}
+
+	remaining := entry.Amount - entry.Refunded
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount < 0 || amount > remaining {
+		return *entry, 0, errRefundExceedsAmount
+	}
+
+	entry.Refunded += amount
+	if entry.Refunded == entry.Amount {
+		entry.Status = pb.PaymentStatus_PAYMENT_STATUS_REFUNDED
+	} else {
+		entry.Status = pb.PaymentStatus_PAYMENT_STATUS_PARTIALLY_REFUNDED
+	}
+	return *entry, amount, nil
+}
+
+// void cancels a payment that has not been refunded in any part.
+func (l *ledger) void(transactionID string) (ledgerEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[transactionID]
+	if !ok {
+		return ledgerEntry{}, errTransactionNotFound
+	}
+	if entry.Status != pb.PaymentStatus_PAYMENT_STATUS_CAPTURED {
+		return *entry, errNotVoidable
+	}
+
+	entry.Status = pb.PaymentStatus_PAYMENT_STATUS_VOIDED
+	return *entry, nil
+}
+
+func (l *ledger) get(transactionID string) (ledgerEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[transactionID]
+	if !ok {
+		return ledgerEntry{}, errTransactionNotFound
+	}
+	return *entry, nil
+}
@@ -2,26 +2,60 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log"
 	"net"
 
 	pb "go-grpc-payment/proto/payment"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const PORT = ":50051"
 
 type server struct {
 	pb.UnimplementedPaymentServiceServer
+	ledger *ledger
+}
+
+// transactionLimits caps a single charge, in minor units of each supported currency.
+var transactionLimits = map[string]int64{
+	"USD": 100000, // $1,000.00
+	"EUR": 100000, // €1,000.00
+	"GBP": 100000, // £1,000.00
+	"JPY": 150000, // ¥150,000 (zero-decimal currency)
 }
 
 func (s *server) ProcessPayment(ctx context.Context, req *pb.PaymentRequest) (*pb.PaymentResponse, error) {
-	log.Printf("Received payment request for Order ID: %d, Amount: %.2f", req.OrderId, req.Amount)
+	amount := req.GetAmount()
+	log.Printf("Received payment request for Order ID: %d, Amount: %d %s", req.OrderId, amount.GetAmountMinor(), amount.GetCurrency())
+
+	if req.OrderId <= 0 {
+		return &pb.PaymentResponse{
+			Success: false,
+			Message: "Invalid order ID",
+		}, nil
+	}
+
+	if amount.GetAmountMinor() <= 0 {
+		return &pb.PaymentResponse{
+			Success: false,
+			Message: "Amount must be positive",
+		}, nil
+	}
+
+	limit, ok := transactionLimits[amount.GetCurrency()]
+	if !ok {
+		return &pb.PaymentResponse{
+			Success: false,
+			Message: "Unsupported currency",
+		}, nil
+	}
 
 	// Simulation logic
-	if req.Amount > 1000 {
+	if amount.GetAmountMinor() > limit {
 		return &pb.PaymentResponse{
 			Success:       false,
 			Message:       "Transaction limit exceeded",
@@ -29,10 +63,82 @@ func (s *server) ProcessPayment(ctx context.Context, req *pb.PaymentRequest) (*p
 		}, nil
 	}
 
+	entry := s.ledger.capture(req.OrderId, amount.GetAmountMinor(), amount.GetCurrency())
 	return &pb.PaymentResponse{
 		Success:       true,
 		Message:       "Payment processed successfully",
-		TransactionId: fmt.Sprintf("TXN-%d", req.OrderId),
+		TransactionId: entry.TransactionID,
+	}, nil
+}
+
+func (s *server) RefundPayment(ctx context.Context, req *pb.RefundRequest) (*pb.RefundResponse, error) {
+	amount := req.GetAmount()
+	log.Printf("Received refund request for Order ID: %d, Transaction: %s, Amount: %d %s",
+		req.OrderId, req.TransactionId, amount.GetAmountMinor(), amount.GetCurrency())
+
+	// Zero refunds the whole remaining balance, so only negatives are invalid.
+	if amount.GetAmountMinor() < 0 {
+		return &pb.RefundResponse{
+			Success: false,
+			Message: "Refund amount must not be negative",
+		}, nil
+	}
+
+	entry, refunded, err := s.ledger.refund(req.TransactionId, amount.GetAmountMinor(), amount.GetCurrency())
+	if errors.Is(err, errTransactionNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return &pb.RefundResponse{
+			Success: false,
+			Message: err.Error(),
+			Status:  entry.Status,
+		}, nil
+	}
+
+	return &pb.RefundResponse{
+		Success:  true,
+		Message:  "Payment refunded successfully",
+		RefundId: "RFD-" + entry.TransactionID,
+		Status:   entry.Status,
+		Refunded: &pb.Money{AmountMinor: refunded, Currency: entry.Currency},
+	}, nil
+}
+
+func (s *server) CancelPayment(ctx context.Context, req *pb.CancelRequest) (*pb.CancelResponse, error) {
+	log.Printf("Received cancel request for Order ID: %d, Transaction: %s", req.OrderId, req.TransactionId)
+
+	entry, err := s.ledger.void(req.TransactionId)
+	if errors.Is(err, errTransactionNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return &pb.CancelResponse{
+			Success: false,
+			Message: err.Error(),
+			Status:  entry.Status,
+		}, nil
+	}
+
+	return &pb.CancelResponse{
+		Success: true,
+		Message: "Payment voided successfully",
+		Status:  entry.Status,
+	}, nil
+}
+
+func (s *server) GetPayment(ctx context.Context, req *pb.GetPaymentRequest) (*pb.PaymentDetails, error) {
+	entry, err := s.ledger.get(req.TransactionId)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	return &pb.PaymentDetails{
+		TransactionId: entry.TransactionID,
+		OrderId:       entry.OrderID,
+		Status:        entry.Status,
+		Amount:        &pb.Money{AmountMinor: entry.Amount, Currency: entry.Currency},
+		Refunded:      &pb.Money{AmountMinor: entry.Refunded, Currency: entry.Currency},
 	}, nil
 }
 
@@ -42,7 +148,7 @@ func main() {
 		log.Fatalf("failed to listen: %v", err)
 	}
 	grpcServer := grpc.NewServer()
-	pb.RegisterPaymentServiceServer(grpcServer, &server{})
+	pb.RegisterPaymentServiceServer(grpcServer, &server{ledger: newLedger()})
 
 	log.Printf("gRPC Payment Server listening on %s", PORT)
 	if err := grpcServer.Serve(lis); err != nil {
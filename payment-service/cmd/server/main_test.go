@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "go-grpc-payment/proto/payment"
+)
+
+func TestProcessPaymentRejectsNonPositiveAmounts(t *testing.T) {
+	s := &server{ledger: newLedger()}
+
+	for _, amount := range []int64{0, -500} {
+		res, err := s.ProcessPayment(context.Background(), &pb.PaymentRequest{
+			OrderId: 1,
+			Amount:  &pb.Money{AmountMinor: amount, Currency: "USD"},
+		})
+		if err != nil || res.Success {
+			t.Fatalf("amount %d: got response %v, error %v; want a decline", amount, res, err)
+		}
+	}
+	if len(s.ledger.entries) != 0 {
+		t.Fatalf("ledger has %d entries, want none", len(s.ledger.entries))
+	}
+
+	res, err := s.ProcessPayment(context.Background(), &pb.PaymentRequest{
+		OrderId: 1,
+		Amount:  &pb.Money{AmountMinor: 5999, Currency: "USD"},
+	})
+	if err != nil || !res.Success {
+		t.Fatalf("valid payment failed: %v, %v", res, err)
+	}
+}
+
+func TestRefundPaymentAmounts(t *testing.T) {
+	s := &server{ledger: newLedger()}
+	payment, err := s.ProcessPayment(context.Background(), &pb.PaymentRequest{
+		OrderId: 1,
+		Amount:  &pb.Money{AmountMinor: 5999, Currency: "USD"},
+	})
+	if err != nil || !payment.Success {
+		t.Fatalf("payment failed: %v, %v", payment, err)
+	}
+
+	refund, err := s.RefundPayment(context.Background(), &pb.RefundRequest{
+		OrderId:       1,
+		TransactionId: payment.TransactionId,
+		Amount:        &pb.Money{AmountMinor: -1, Currency: "USD"},
+	})
+	if err != nil || refund.Success {
+		t.Fatalf("negative refund: got response %v, error %v; want a decline", refund, err)
+	}
+
+	// Nothing was taken off the balance
+	entry, _ := s.ledger.get(payment.TransactionId)
+	if entry.Refunded != 0 {
+		t.Fatalf("refunded %d after a declined refund, want 0", entry.Refunded)
+	}
+
+	// Zero refunds what is left
+	refund, err = s.RefundPayment(context.Background(), &pb.RefundRequest{
+		OrderId:       1,
+		TransactionId: payment.TransactionId,
+		Amount:        &pb.Money{AmountMinor: 0, Currency: "USD"},
+	})
+	if err != nil || !refund.Success {
+		t.Fatalf("full refund failed: %v, %v", refund, err)
+	}
+	if refund.Refunded.GetAmountMinor() != 5999 {
+		t.Fatalf("refunded %d, want 5999", refund.Refunded.GetAmountMinor())
+	}
+}
@@ -1,8 +1,22 @@
 const API_URL = '/api/v1';
 let currentCart = [];
+let cartSummary = null; // subtotal, discounts and total from GET /cart
+let currentCurrency = localStorage.getItem('currency') || 'USD';
+let checkoutKey = null;
+
+// Amounts from the API are integers in the currency's minor unit (cents, pence, yen...)
+const CURRENCY_EXPONENTS = {USD: 2, EUR: 2, GBP: 2, JPY: 0};
+
+function formatMoney(amount, currency = 'USD') {
+    const exponent = CURRENCY_EXPONENTS[currency] ?? 2;
+    return new Intl.NumberFormat(undefined, {style: 'currency', currency})
+        .format(amount / Math.pow(10, exponent));
+}
 
 // --- Init on Page Load ---
 document.addEventListener('DOMContentLoaded', () => {
+    document.getElementById('currency-select').value = currentCurrency;
+    handleEmailLinks();
     checkAuth();
     loadProducts();
 
@@ -56,6 +70,7 @@ async function login() {
         if (!res.ok) throw new Error(data.error);
 
         localStorage.setItem('token', data.token);
+        localStorage.setItem('refresh_token', data.refresh_token);
         localStorage.setItem('email', email);
 
         // Simple role check (in prod, parse the JWT)
@@ -81,22 +96,150 @@ async function register() {
         });
         const data = await res.json();
         if (!res.ok) throw new Error(data.error);
-        showToast("Account created! Please login.", "success");
+        showToast("Account created! Check your email to verify it, then login.", "success");
+    } catch (err) {
+        showToast(err.message, "error");
+    }
+}
+
+async function forgotPassword() {
+    const email = document.getElementById('login-email').value;
+    if (!email) return showToast("Enter your email first", "error");
+
+    try {
+        const res = await fetch(`${API_URL}/auth/forgot-password`, {
+            method: 'POST',
+            headers: {'Content-Type': 'application/json'},
+            body: JSON.stringify({email})
+        });
+        const data = await res.json();
+        if (!res.ok) throw new Error(data.error);
+        showToast("Check your email for a reset link.", "success");
     } catch (err) {
         showToast(err.message, "error");
     }
 }
 
-function logout() {
+// handleEmailLinks completes the verification and password reset links sent
+// by email (/?verify_token=... and /?reset_token=...).
+async function handleEmailLinks() {
+    const params = new URLSearchParams(window.location.search);
+    const verifyToken = params.get('verify_token');
+    const resetToken = params.get('reset_token');
+    if (!verifyToken && !resetToken) return;
+
+    window.history.replaceState({}, '', window.location.pathname);
+
+    try {
+        let res;
+        if (verifyToken) {
+            res = await fetch(`${API_URL}/auth/verify-email`, {
+                method: 'POST',
+                headers: {'Content-Type': 'application/json'},
+                body: JSON.stringify({token: verifyToken})
+            });
+        } else {
+            const password = prompt("Choose a new password");
+            if (!password) return;
+            res = await fetch(`${API_URL}/auth/reset-password`, {
+                method: 'POST',
+                headers: {'Content-Type': 'application/json'},
+                body: JSON.stringify({token: resetToken, password})
+            });
+        }
+        const data = await res.json();
+        if (!res.ok) throw new Error(data.error);
+        showToast(data.message, "success");
+    } catch (err) {
+        showToast(err.message, "error");
+    }
+}
+
+function setCurrency(currency) {
+    currentCurrency = currency;
+    localStorage.setItem('currency', currency);
+    loadProducts();
+    fetchCart();
+}
+
+async function logout() {
+    try {
+        await authFetch(`${API_URL}/auth/logout`, {
+            method: 'POST',
+            headers: {'Content-Type': 'application/json'},
+            body: JSON.stringify({refresh_token: localStorage.getItem('refresh_token')})
+        });
+    } catch (e) {
+        console.error(e);
+    }
+    clearSession();
+}
+
+function clearSession() {
+    const currency = localStorage.getItem('currency');
     localStorage.clear();
+    if (currency) localStorage.setItem('currency', currency);
     window.location.reload();
 }
 
+// authFetch sends the current access token. Access tokens are short-lived, so
+// on a 401 it trades the refresh token for a new pair once and retries.
+async function authFetch(url, options = {}) {
+    const send = () => fetch(url, {
+        ...options,
+        headers: {...options.headers, 'Authorization': `Bearer ${localStorage.getItem('token')}`}
+    });
+
+    const res = await send();
+    if (res.status !== 401 || !(await refreshSession())) return res;
+    return send();
+}
+
+let refreshing = null;
+
+async function refreshSession() {
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (!refreshToken) return false;
+
+    // Concurrent 401s share one refresh, since each refresh token works once
+    if (!refreshing) {
+        refreshing = fetch(`${API_URL}/auth/refresh`, {
+            method: 'POST',
+            headers: {'Content-Type': 'application/json'},
+            body: JSON.stringify({refresh_token: refreshToken})
+        }).then(async res => {
+            if (!res.ok) return false;
+            const data = await res.json();
+            localStorage.setItem('token', data.token);
+            localStorage.setItem('refresh_token', data.refresh_token);
+            return true;
+        }).catch(() => false).finally(() => {
+            refreshing = null;
+        });
+    }
+
+    const ok = await refreshing;
+    if (!ok) clearSession();
+    return ok;
+}
+
 // --- Product Functions ---
+function termNames(terms) {
+    return (terms || []).map(t => t.name).join(', ');
+}
+
+function ratingText(p) {
+    return p.review_count ? `★ ${p.rating_average.toFixed(1)} (${p.review_count})` : '';
+}
+
+function coverImage(p) {
+    return (p.images || []).find(img => img.kind === 'cover');
+}
+
 async function loadProducts() {
     try {
-        const res = await fetch(`${API_URL}/products`);
-        const products = await res.json();
+        const res = await fetch(`${API_URL}/products?limit=100&currency=${currentCurrency}`);
+        const {data: products} = await res.json();
         const container = document.getElementById('product-list');
         container.innerHTML = '';
 
@@ -106,31 +249,37 @@ async function loadProducts() {
         }
 
         products.forEach(p => {
-            const price = (p.price / 100).toFixed(2);
+            const price = formatMoney(p.price, p.currency);
             // Random gradients for visuals
             const colors = ['from-purple-500 to-indigo-500', 'from-green-500 to-teal-500', 'from-red-500 to-orange-500'];
             const bg = colors[p.ID % colors.length];
+            const cover = coverImage(p);
+            const visual = cover
+                ? `<img src="${cover.thumbnail_url}" alt="${p.name}" class="absolute inset-0 w-full h-full object-cover transform group-hover:scale-110 transition duration-500">`
+                : `<span class="text-6xl transform group-hover:scale-110 transition duration-500">🎮</span>`;
 
             const html = `
             <div class="bg-gray-800 rounded-xl overflow-hidden shadow-lg card-hover border border-gray-700 flex flex-col group relative">
                 <!-- Clickable Area for Details -->
                 <div class="cursor-pointer" onclick="window.openProduct(${p.ID})">
                     <div class="h-48 bg-gradient-to-br ${bg} flex items-center justify-center relative overflow-hidden">
-                        <span class="text-6xl transform group-hover:scale-110 transition duration-500">🎮</span>
-                        <div class="absolute bottom-2 right-2 bg-black bg-opacity-50 px-2 py-1 rounded text-xs text-gray-300">Stock: ${p.stock}</div>
+                        ${visual}
+                        <div class="absolute bottom-2 right-2 bg-black bg-opacity-50 px-2 py-1 rounded text-xs text-gray-300">Available: ${p.available}</div>
                     </div>
                     <div class="p-5 pb-0">
                         <h3 class="text-xl font-bold text-white mb-1 hover:text-blue-400 transition">${p.name}</h3>
+                        <p class="text-xs text-blue-300 mb-1">${termNames(p.platforms)}</p>
+                        <p class="text-xs text-yellow-400 mb-1">${ratingText(p)}</p>
                         <p class="text-gray-400 text-sm line-clamp-2">${p.description || 'Awesome gameplay awaits.'}</p>
                     </div>
                 </div>
 
                 <!-- Footer (Buttons) -->
                 <div class="p-5 mt-auto flex justify-between items-center pt-4">
-                    <span class="text-2xl font-bold text-green-400">$${price}</span>
-                    <button onclick="addToCart(${p.ID})" 
-                        class="${p.stock > 0 ? 'bg-blue-600 hover:bg-blue-500' : 'bg-gray-600 cursor-not-allowed'} text-white px-4 py-2 rounded-lg font-bold transition shadow-md z-10">
-                        ${p.stock > 0 ? 'Add' : 'Sold Out'}
+                    <span class="text-2xl font-bold text-green-400">${price}</span>
+                    <button onclick="${p.available > 0 ? 'addToCart' : 'addToWishlist'}(${p.ID})" 
+                        class="${p.available > 0 ? 'bg-blue-600 hover:bg-blue-500' : 'bg-gray-600 hover:bg-gray-500'} text-white px-4 py-2 rounded-lg font-bold transition shadow-md z-10">
+                        ${p.available > 0 ? 'Add' : 'Notify Me'}
                     </button>
                 </div>
             </div>`;
@@ -149,11 +298,12 @@ async function fetchCart() {
     if (!token) return;
 
     try {
-        const res = await fetch(`${API_URL}/cart`, {
+        const res = await authFetch(`${API_URL}/cart?currency=${currentCurrency}`, {
             headers: {'Authorization': `Bearer ${token}`}
         });
         if (res.ok) {
-            currentCart = await res.json();
+            cartSummary = await res.json();
+            currentCart = cartSummary.items;
             updateCartUI();
         }
     } catch (e) {
@@ -166,7 +316,7 @@ async function addToCart(id) {
     if (!token) return showToast("Please login to shop", "error");
 
     try {
-        const res = await fetch(`${API_URL}/cart`, {
+        const res = await authFetch(`${API_URL}/cart?currency=${currentCurrency}`, {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
@@ -184,6 +334,30 @@ async function addToCart(id) {
     }
 }
 
+// Sold-out products go on the wishlist, which emails the user once they
+// are back in stock or cheaper.
+async function addToWishlist(id) {
+    const token = localStorage.getItem('token');
+    if (!token) return showToast("Please login to get notified", "error");
+
+    try {
+        const res = await authFetch(`${API_URL}/wishlist`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+                'Authorization': `Bearer ${token}`
+            },
+            body: JSON.stringify({product_id: id})
+        });
+
+        if (!res.ok) throw new Error((await res.json()).error);
+
+        showToast("We'll email you when it's back", "success");
+    } catch (err) {
+        showToast(err.message, "error");
+    }
+}
+
 function updateCartUI() {
     const badge = document.getElementById('cart-badge');
     const list = document.getElementById('cart-items');
@@ -191,18 +365,17 @@ function updateCartUI() {
     const btn = document.getElementById('checkout-btn');
 
     // Update Badge Count
-    const totalItems = currentCart.reduce((sum, item) => sum + item.quantity, 0);
+    const totalItems = cartSummary?.item_count ?? 0;
     badge.innerText = totalItems;
     badge.classList.toggle('hidden', totalItems === 0);
 
-    // Calculate Total Price
-    let totalCents = 0;
+    renderDiscounts();
 
     // Render List
     if (currentCart.length === 0) {
         list.innerHTML = '<div class="text-center text-gray-500 mt-10">Your cart is empty.</div>';
         btn.disabled = true;
-        totalEl.innerText = "$0.00";
+        totalEl.innerText = formatMoney(0, cartSummary?.currency);
         return;
     }
 
@@ -210,14 +383,17 @@ function updateCartUI() {
     list.innerHTML = '';
 
     currentCart.forEach(item => {
+        // Every amount comes priced from the server
+        const warnings = (item.warnings || [])
+            .map(w => `<div class="text-xs text-yellow-400">⚠ ${w.message}</div>`).join('');
         // Ensure product data exists
         if (item.product) {
-            totalCents += item.product.price * item.quantity;
             list.innerHTML += `
             <div class="flex justify-between items-center bg-gray-700 p-3 rounded-lg border border-gray-600 transition hover:bg-gray-600">
                 <div class="flex-grow">
                     <div class="font-bold text-sm text-white">${item.product.name}</div>
-                    <div class="text-xs text-gray-400">$${(item.product.price / 100).toFixed(2)} each</div>
+                    <div class="text-xs text-gray-400">${formatMoney(item.unit_price, cartSummary.currency)} each</div>
+                    ${warnings}
                 </div>
                 
                 <div class="flex items-center gap-3">
@@ -228,7 +404,7 @@ function updateCartUI() {
                         <button onclick="window.changeQuantity(${item.product_id}, 1)" class="px-2 py-1 text-gray-300 hover:text-white hover:bg-gray-600 rounded-r">+</button>
                     </div>
 
-                    <span class="font-mono font-bold text-green-400 w-16 text-right">$${(item.product.price * item.quantity / 100).toFixed(2)}</span>
+                    <span class="font-mono font-bold text-green-400 w-16 text-right">${formatMoney(item.line_subtotal, cartSummary.currency)}</span>
                     
                     <!-- Full Remove (Trash) -->
                     <button onclick="window.removeFromCart(${item.product_id})" class="text-red-400 hover:text-red-200 p-1">✕</button>
@@ -237,7 +413,86 @@ function updateCartUI() {
         }
     });
 
-    totalEl.innerText = "$" + (totalCents / 100).toFixed(2);
+    totalEl.innerText = formatMoney(cartSummary.total, cartSummary.currency);
+}
+
+function renderDiscounts() {
+    const el = document.getElementById('cart-discounts');
+    if (!cartSummary) {
+        el.innerHTML = '';
+        return;
+    }
+
+    el.innerHTML = `
+        <div class="flex justify-between text-gray-300">
+            <span>Subtotal</span>
+            <span class="font-mono">${formatMoney(cartSummary.subtotal, cartSummary.currency)}</span>
+        </div>`;
+    el.innerHTML += cartSummary.discounts.map(d => `
+        <div class="flex justify-between text-green-400">
+            <span>${d.code} &middot; ${d.description}
+                <button onclick="window.removeCoupon()" class="text-red-400 hover:text-red-200 ml-1">✕</button>
+            </span>
+            <span class="font-mono">-${formatMoney(d.amount, cartSummary.currency)}</span>
+        </div>`).join('');
+    if (cartSummary.coupon_error) {
+        el.innerHTML += `
+        <div class="flex justify-between text-yellow-400">
+            <span>${cartSummary.coupon_error}</span>
+            <button onclick="window.removeCoupon()" class="text-red-400 hover:text-red-200 ml-1">✕</button>
+        </div>`;
+    }
+    if (cartSummary.tax > 0) {
+        el.innerHTML += `
+        <div class="flex justify-between text-gray-300">
+            <span>Tax (${Math.round(cartSummary.tax_rate * 1000) / 10}%)</span>
+            <span class="font-mono">${formatMoney(cartSummary.tax, cartSummary.currency)}</span>
+        </div>`;
+    }
+}
+
+async function applyCoupon(event) {
+    event.preventDefault();
+    const token = localStorage.getItem('token');
+    if (!token) return showToast("Please login to shop", "error");
+
+    const input = document.getElementById('coupon-code');
+    try {
+        const res = await authFetch(`${API_URL}/cart/coupon?currency=${currentCurrency}`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+                'Authorization': `Bearer ${token}`
+            },
+            body: JSON.stringify({code: input.value})
+        });
+        const data = await res.json();
+        if (!res.ok) throw new Error(data.error);
+
+        input.value = '';
+        cartSummary = data;
+        currentCart = data.items;
+        updateCartUI();
+        showToast("Coupon applied", "success");
+    } catch (err) {
+        showToast(err.message, "error");
+    }
+}
+
+async function removeCoupon() {
+    const token = localStorage.getItem('token');
+    if (!token) return;
+
+    try {
+        const res = await authFetch(`${API_URL}/cart/coupon`, {
+            method: 'DELETE',
+            headers: {'Authorization': `Bearer ${token}`}
+        });
+        if (!res.ok) throw new Error("Failed to remove coupon");
+        fetchCart();
+    } catch (err) {
+        showToast(err.message, "error");
+    }
 }
 
 async function checkout() {
@@ -246,18 +501,27 @@ async function checkout() {
 
     if (!confirm("Confirm purchase?")) return;
 
+    // One key per purchase: retries and double clicks reuse it, so the
+    // server charges at most once. It's dropped once the order is placed.
+    if (!checkoutKey) checkoutKey = crypto.randomUUID();
+
+    const btn = document.getElementById('checkout-btn');
+    btn.disabled = true;
+
     try {
-        const res = await fetch(`${API_URL}/cart/checkout`, {
+        const res = await authFetch(`${API_URL}/cart/checkout?currency=${currentCurrency}`, {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
-                'Authorization': `Bearer ${token}`
+                'Authorization': `Bearer ${token}`,
+                'Idempotency-Key': checkoutKey
             }
         });
 
         const data = await res.json();
         if (!res.ok) throw new Error(data.error);
 
+        checkoutKey = null;
         showToast(`🎉 Success! Order #${data.order_id} placed.`, "success");
 
         // Refresh everything
@@ -266,6 +530,8 @@ async function checkout() {
         loadProducts(); // Update stock on main page
     } catch (err) {
         showToast(err.message, "error");
+    } finally {
+        btn.disabled = currentCart.length === 0;
     }
 }
 
@@ -300,7 +566,7 @@ async function addProduct() {
     };
 
     try {
-        const res = await fetch(`${API_URL}/products`, {
+        const res = await authFetch(`${API_URL}/products`, {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
@@ -323,7 +589,7 @@ async function removeFromCart(productID) {
     if (!token) return;
 
     try {
-        const res = await fetch(`${API_URL}/cart/${productID}`, {
+        const res = await authFetch(`${API_URL}/cart/${productID}`, {
             method: 'DELETE',
             headers: {
                 "Authorization": `Bearer ${token}`
@@ -343,7 +609,7 @@ async function changeQuantity(productID, delta) {
     if (!token) return;
 
     try {
-        const res = await fetch(`${API_URL}/cart/`, {
+        const res = await authFetch(`${API_URL}/cart/`, {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
@@ -378,22 +644,31 @@ function showToast(message, type = "success") {
 
 async function openProduct(id) {
     try {
-        const res = await fetch(`${API_URL}/products/${id}`);
+        const res = await fetch(`${API_URL}/products/${id}?currency=${currentCurrency}`);
         if (!res.ok) throw new Error("Failed to load product");
         const p = await res.json();
 
         // Populate Data
         document.getElementById('modal-title').innerText = p.name;
         document.getElementById('modal-desc').innerText = p.description;
-        document.getElementById('modal-stock').innerText = `Stock: ${p.stock}`;
+        document.getElementById('modal-stock').innerText = `Available: ${p.available}`;
         document.getElementById('modal-sku').innerText = `SKU: ${p.sku}`;
-        document.getElementById('modal-price').innerText = `$${(p.price / 100).toFixed(2)}`;
+        document.getElementById('modal-meta').innerText = [
+            termNames(p.categories),
+            termNames(p.platforms),
+            p.developer,
+            termNames(p.publishers),
+            p.release_date ? `Released ${p.release_date.slice(0, 10)}` : '',
+            p.age_rating,
+            ratingText(p),
+        ].filter(Boolean).join(' · ');
+        document.getElementById('modal-price').innerText = formatMoney(p.price, p.currency);
 
         // Update Button Logic
         const btn = document.getElementById('modal-add-btn');
         btn.onclick = () => addToCart(p.ID); // Reuse existing cart logic
 
-        if (p.stock > 0) {
+        if (p.available > 0) {
             btn.disabled = false;
             btn.innerText = "Add to Cart";
             btn.classList.remove('opacity-50', 'cursor-not-allowed');
@@ -409,6 +684,10 @@ async function openProduct(id) {
         const gradientEl = document.getElementById('modal-gradient');
         // Reset classes
         gradientEl.className = `h-64 md:h-auto bg-gradient-to-br ${bgClass} flex items-center justify-center`;
+        const cover = coverImage(p);
+        gradientEl.innerHTML = cover
+            ? `<img src="${cover.url}" alt="${p.name}" class="w-full h-full object-cover">`
+            : '<span class="text-9xl">🎮</span>';
 
         // Show Modal
         document.getElementById('product-modal').classList.remove('hidden');
@@ -428,12 +707,17 @@ function closeProductModal() {
 window.login = login;
 window.register = register;
 window.logout = logout;
+window.setCurrency = setCurrency;
+window.forgotPassword = forgotPassword;
 window.addToCart = addToCart;
+window.addToWishlist = addToWishlist;
 window.checkout = checkout;
 window.toggleCart = toggleCart;
 window.toggleAdminPanel = toggleAdminPanel;
 window.addProduct = addProduct;
 window.removeFromCart = removeFromCart;
+window.applyCoupon = applyCoupon;
+window.removeCoupon = removeCoupon;
 window.changeQuantity = changeQuantity;
 window.openProduct = openProduct;
 window.closeProductModal = closeProductModal;
\ No newline at end of file